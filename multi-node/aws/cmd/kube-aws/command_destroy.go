@@ -1,12 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/cluster"
-	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
 )
 
 var (
@@ -18,26 +20,45 @@ var (
 		SilenceUsage: true,
 	}
 	destroyOpts = struct {
-		awsDebug bool
+		awsDebug, force bool
 	}{}
 )
 
 func init() {
 	cmdRoot.AddCommand(cmdDestroy)
 	cmdDestroy.Flags().BoolVar(&destroyOpts.awsDebug, "aws-debug", false, "Log debug information from aws-sdk-go library")
+	cmdDestroy.Flags().BoolVar(&destroyOpts.force, "force", false, "Don't ask for confirmation before destroying the cluster")
 }
 
 func runCmdDestroy(cmd *cobra.Command, args []string) error {
-	cfg, err := config.ClusterFromFile(configPath)
+	cfg, err := clusterFromConfigFile()
 	if err != nil {
 		return fmt.Errorf("Error parsing config: %v", err)
 	}
 
+	if !destroyOpts.force {
+		fmt.Printf("This will destroy all AWS resources of cluster %s.\n", cfg.ClusterName)
+		fmt.Printf("Type the cluster name to confirm: ")
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("Error reading confirmation: %v", err)
+		}
+		if strings.TrimSpace(answer) != cfg.ClusterName {
+			return fmt.Errorf("Confirmation did not match cluster name %s, aborting", cfg.ClusterName)
+		}
+	}
+
+	fmt.Println("Destroying CloudFormation stack. This will take several minutes")
+
 	c := cluster.New(cfg, destroyOpts.awsDebug)
 	if err := c.Destroy(); err != nil {
+		if err == cluster.ErrStackNotFound {
+			fmt.Printf("CloudFormation stack %s does not exist. Nothing to destroy\n", cfg.CloudFormationStackName())
+			return nil
+		}
 		return fmt.Errorf("Failed destroying cluster: %v", err)
 	}
 
-	fmt.Println("CloudFormation stack is being destroyed. This will take several minutes")
+	fmt.Println("CloudFormation stack has been destroyed")
 	return nil
 }
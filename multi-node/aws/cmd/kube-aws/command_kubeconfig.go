@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdKubeConfig = &cobra.Command{
+		Use:          "kubeconfig",
+		Short:        "Write a kubeconfig for the cluster's admin user",
+		Long:         `Writes a kubeconfig that reaches the Kubernetes API at externalDNSName, authenticating with the admin TLS assets in ./credentials.`,
+		RunE:         runCmdKubeConfig,
+		SilenceUsage: true,
+	}
+
+	kubeConfigOpts = struct {
+		output, apiEndpoint string
+	}{}
+)
+
+func init() {
+	cmdRoot.AddCommand(cmdKubeConfig)
+	cmdKubeConfig.Flags().StringVar(&kubeConfigOpts.output, "output", "", "Path to write the kubeconfig to. Defaults to kubeconfig next to the TLS assets directory")
+	cmdKubeConfig.Flags().StringVar(&kubeConfigOpts.apiEndpoint, "api-endpoint", "", "URL of the Kubernetes API, e.g. the API load balancer reported by \"kube-aws status\". Defaults to https://<externalDNSName>")
+}
+
+func runCmdKubeConfig(cmd *cobra.Command, args []string) error {
+	conf, err := clusterFromConfigFile()
+	if err != nil {
+		return fmt.Errorf("Failed to read cluster config: %v", err)
+	}
+
+	path, warnings, err := conf.WriteKubeConfig(config.KubeConfigOptions{
+		TLSAssetsDir: stackTemplateOptions.TLSAssetsDir,
+		Path:         kubeConfigOpts.output,
+		APIEndpoint:  kubeConfigOpts.apiEndpoint,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to write kubeconfig: %v", err)
+	}
+	printWarnings(warnings)
+
+	fmt.Printf("Kubeconfig written to %s\n", path)
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/cluster"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdPreflight = &cobra.Command{
+		Use:          "preflight",
+		Short:        "Check the AWS credentials are allowed the calls kube-aws makes",
+		Long:         `Makes the read-only EC2, Route53, CloudFormation and KMS calls kube-aws relies on and lists every IAM action the credentials in use are denied. Unlike validate it doesn't check the cluster's resources, only that the caller is permitted to work with them, so it can vet a role in CI before a deploy.`,
+		RunE:         runCmdPreflight,
+		SilenceUsage: true,
+	}
+
+	preflightOpts = struct {
+		awsDebug bool
+	}{}
+)
+
+func init() {
+	cmdRoot.AddCommand(cmdPreflight)
+	cmdPreflight.Flags().BoolVar(
+		&preflightOpts.awsDebug,
+		"aws-debug",
+		false,
+		"Log debug information from aws-sdk-go library",
+	)
+}
+
+func runCmdPreflight(cmd *cobra.Command, args []string) error {
+	cfg, err := clusterFromConfigFile()
+	if err != nil {
+		return fmt.Errorf("Unable to load cluster config: %v", err)
+	}
+
+	fmt.Printf("Checking AWS permissions...\n")
+	if err := cluster.New(cfg, preflightOpts.awsDebug).CheckPermissions(); err != nil {
+		return err
+	}
+	fmt.Printf("AWS permissions OK!\n")
+	return nil
+}
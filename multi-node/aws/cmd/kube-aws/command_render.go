@@ -1,11 +1,9 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"text/template"
 
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
 	"github.com/spf13/cobra"
@@ -21,22 +19,44 @@ var (
 	}
 )
 
+var (
+	cmdRenderStack = &cobra.Command{
+		Use:          "stack",
+		Short:        "Render the stack template and userdata without creating a stack",
+		Long:         `Renders the stack template, userdata and TLS assets to an output directory without making any AWS API calls. TLS assets are embedded in the stack template as digests rather than KMS ciphertext, so the output is deterministic and suitable for review, but cannot be deployed.`,
+		RunE:         runCmdRenderStack,
+		SilenceUsage: true,
+	}
+
+	renderStackOpts = struct {
+		outputDir string
+	}{}
+)
+
 func init() {
 	cmdRoot.AddCommand(cmdRender)
+	cmdRender.AddCommand(cmdRenderStack)
+	cmdRenderStack.Flags().StringVar(&renderStackOpts.outputDir, "output-dir", "rendered", "Directory to write the rendered assets to")
 }
 
 func runCmdRender(cmd *cobra.Command, args []string) error {
 	// Read the config from file.
-	cluster, err := config.ClusterFromFile(configPath)
+	cluster, err := clusterFromConfigFile()
 	if err != nil {
 		return fmt.Errorf("Failed to read cluster config: %v", err)
 	}
+	printWarnings(cluster.Warnings())
 
 	// Generate default TLS assets.
 	assets, err := cluster.NewTLSAssets()
 	if err != nil {
 		return fmt.Errorf("Error generating default assets: %v", err)
 	}
+	tlsWarnings, err := cluster.TLSAssetsWarnings(assets)
+	if err != nil {
+		return err
+	}
+	printWarnings(tlsWarnings)
 	if err := os.Mkdir("credentials", 0700); err != nil {
 		return err
 	}
@@ -44,18 +64,10 @@ func runCmdRender(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error create assets: %v", err)
 	}
 
-	// Create a Config and attempt to render a kubeconfig for it.
-	cfg, err := cluster.Config()
-	if err != nil {
-		return fmt.Errorf("Failed to create config: %v", err)
-	}
-	tmpl, err := template.New("kubeconfig.yaml").Parse(string(config.KubeConfigTemplate))
-	if err != nil {
-		return fmt.Errorf("Failed to parse default kubeconfig template: %v", err)
-	}
-	var kubeconfig bytes.Buffer
-	if err := tmpl.Execute(&kubeconfig, cfg); err != nil {
-		return fmt.Errorf("Failed to render kubeconfig: %v", err)
+	// The apiserver certificate was just generated for externalDNSName, so
+	// there is nothing to warn about
+	if _, _, err := cluster.WriteKubeConfig(config.KubeConfigOptions{TLSAssetsDir: "credentials"}); err != nil {
+		return fmt.Errorf("Failed to write kubeconfig: %v", err)
 	}
 
 	// Write all assets to disk.
@@ -71,8 +83,8 @@ func runCmdRender(cmd *cobra.Command, args []string) error {
 		{"credentials/.gitignore", []byte("*"), 0644},
 		{"userdata/cloud-config-controller", config.CloudConfigController, 0644},
 		{"userdata/cloud-config-worker", config.CloudConfigWorker, 0644},
+		{"userdata/cloud-config-etcd", config.CloudConfigEtcd, 0644},
 		{"stack-template.json", config.StackTemplateTemplate, 0644},
-		{"kubeconfig", kubeconfig.Bytes(), 0600},
 	}
 	for _, file := range files {
 		if err := ioutil.WriteFile(file.name, file.data, file.mode); err != nil {
@@ -92,3 +104,27 @@ Next steps:
 	fmt.Printf(successMsg, configPath)
 	return nil
 }
+
+func runCmdRenderStack(cmd *cobra.Command, args []string) error {
+	cluster, err := clusterFromConfigFile()
+	if err != nil {
+		return fmt.Errorf("Failed to read cluster config: %v", err)
+	}
+	printWarnings(cluster.Warnings())
+
+	if err := printTLSAssetsWarnings(cluster, stackTemplateOptions.TLSAssetsDir); err != nil {
+		return err
+	}
+
+	assets, err := cluster.RenderAssets(stackTemplateOptions)
+	if err != nil {
+		return fmt.Errorf("Failed to render assets: %v", err)
+	}
+
+	if err := assets.WriteToDir(renderStackOpts.outputDir); err != nil {
+		return fmt.Errorf("Error writing rendered assets to %s: %v", renderStackOpts.outputDir, err)
+	}
+
+	fmt.Printf("Rendered assets written to %s\n", renderStackOpts.outputDir)
+	return nil
+}
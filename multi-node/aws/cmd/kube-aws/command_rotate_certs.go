@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/cluster"
+	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
+)
+
+var (
+	cmdRotateCerts = &cobra.Command{
+		Use:          "rotate-certs",
+		Short:        "Regenerate the TLS assets of an existing Kubernetes cluster",
+		Long:         `Regenerates the keys and certificates in ./credentials, signed by the existing CA unless --rotate-ca is given, and updates the cluster's stack with them. The new assets are only written to ./credentials once the stack update has completed. Changing the TLS assets changes the userdata of every node, so the controller is replaced and workers are replaced by a rolling update.`,
+		RunE:         runCmdRotateCerts,
+		SilenceUsage: true,
+	}
+
+	rotateCertsOpts = struct {
+		awsDebug, rotateCA, force bool
+	}{}
+)
+
+func init() {
+	cmdRoot.AddCommand(cmdRotateCerts)
+	cmdRotateCerts.Flags().BoolVar(&rotateCertsOpts.awsDebug, "aws-debug", false, "Log debug information from aws-sdk-go library")
+	cmdRotateCerts.Flags().BoolVar(&rotateCertsOpts.rotateCA, "rotate-ca", false, "Also regenerate the CA. Every client of the cluster must be given the new CA certificate")
+	cmdRotateCerts.Flags().BoolVar(&rotateCertsOpts.force, "force", false, "Don't ask for confirmation before rotating the certificates")
+}
+
+func runCmdRotateCerts(cmd *cobra.Command, args []string) error {
+	conf, err := clusterFromConfigFile()
+	if err != nil {
+		return fmt.Errorf("Failed to read cluster config: %v", err)
+	}
+	printWarnings(conf.Warnings())
+
+	current, err := config.ReadTLSAssets(stackTemplateOptions.TLSAssetsDir)
+	if err != nil {
+		return fmt.Errorf("Failed to read TLS assets: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "WARNING: rotating certificates changes the userdata of every node of cluster %s. The controller will be replaced and workers will be replaced by a rolling update.\n", conf.ClusterName)
+	fmt.Fprintf(os.Stderr, "WARNING: service account tokens are signed with the API server key, which is regenerated. Delete the existing service account token secrets once the update completes so they are reissued.\n")
+	if rotateCertsOpts.rotateCA {
+		fmt.Fprintf(os.Stderr, "WARNING: the CA will be rotated too. Every kubeconfig for this cluster must be given the new CA certificate.\n")
+	}
+	if !rotateCertsOpts.force {
+		fmt.Printf("Type the cluster name to confirm: ")
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("Error reading confirmation: %v", err)
+		}
+		if strings.TrimSpace(answer) != conf.ClusterName {
+			return fmt.Errorf("Confirmation did not match cluster name %s, aborting", conf.ClusterName)
+		}
+	}
+
+	renewed, err := conf.RenewTLSAssets(current, rotateCertsOpts.rotateCA)
+	if err != nil {
+		return fmt.Errorf("Error generating TLS assets: %v", err)
+	}
+	tlsWarnings, err := conf.TLSAssetsWarnings(renewed)
+	if err != nil {
+		return err
+	}
+	printWarnings(tlsWarnings)
+
+	before, err := current.Fingerprints()
+	if err != nil {
+		return err
+	}
+	after, err := renewed.Fingerprints()
+	if err != nil {
+		return err
+	}
+
+	// Render from a scratch copy so ./credentials still matches the running
+	// cluster if the update fails.
+	tmpDir, err := ioutil.TempDir("", "kube-aws-credentials")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := renewed.WriteToDir(tmpDir); err != nil {
+		return fmt.Errorf("Error writing TLS assets: %v", err)
+	}
+
+	opts := stackTemplateOptions
+	opts.TLSAssetsDir = tmpDir
+
+	if err := conf.ValidateUserData(opts); err != nil {
+		return err
+	}
+
+	data, err := conf.RenderStackTemplate(opts)
+	if err != nil {
+		return fmt.Errorf("Failed to render stack template: %v", err)
+	}
+
+	fmt.Printf("Updating AWS resources. This may take several minutes.\n")
+	if _, err := cluster.New(conf, rotateCertsOpts.awsDebug).Update(string(data)); err != nil {
+		return fmt.Errorf("Error updating cluster: %v", err)
+	}
+
+	if err := renewed.WriteToDir(stackTemplateOptions.TLSAssetsDir); err != nil {
+		return fmt.Errorf("Stack updated but failed to write TLS assets to %s: %v", stackTemplateOptions.TLSAssetsDir, err)
+	}
+
+	fmt.Printf("Certificates rotated. New TLS assets written to %s\n", stackTemplateOptions.TLSAssetsDir)
+	fmt.Printf("SHA-256 fingerprints of the certificates:\n")
+	for i := range before {
+		if before[i].SHA256 == after[i].SHA256 {
+			fmt.Printf("  %s.pem unchanged %s\n", before[i].Name, before[i].SHA256)
+			continue
+		}
+		fmt.Printf("  %s.pem before    %s\n", before[i].Name, before[i].SHA256)
+		fmt.Printf("  %s.pem after     %s\n", after[i].Name, after[i].SHA256)
+	}
+	return nil
+}
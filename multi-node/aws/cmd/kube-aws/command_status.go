@@ -1,10 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/cluster"
-	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
 	"github.com/spf13/cobra"
 )
 
@@ -12,26 +12,48 @@ var (
 	cmdStatus = &cobra.Command{
 		Use:          "status",
 		Short:        "Describe an existing Kubernetes cluster",
-		Long:         ``,
+		Long:         `Describes an existing Kubernetes cluster and reports whether it is ready: the status of its CloudFormation stack, how many controllers behind the API load balancer are in service and whether externalDNSName resolves to the load balancer.`,
 		RunE:         runCmdStatus,
 		SilenceUsage: true,
 	}
+
+	statusOpts = struct {
+		json bool
+	}{}
 )
 
 func init() {
 	cmdRoot.AddCommand(cmdStatus)
+	cmdStatus.Flags().BoolVar(&statusOpts.json, "json", false, "Print the cluster status as JSON")
 }
 
 func runCmdStatus(cmd *cobra.Command, args []string) error {
-	conf, err := config.ClusterFromFile(configPath)
+	conf, err := clusterFromConfigFile()
 	if err != nil {
 		return fmt.Errorf("Failed to read cluster config: %v", err)
 	}
-	info, err := cluster.New(conf, false).Info()
+	c := cluster.New(conf, false)
+
+	status, err := c.Status()
+	if err != nil {
+		return fmt.Errorf("Failed fetching cluster status: %v", err)
+	}
+
+	if statusOpts.json {
+		out, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Failed to encode cluster status: %v", err)
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	info, err := c.Info()
 	if err != nil {
 		return fmt.Errorf("Failed fetching cluster info: %v", err)
 	}
 
 	fmt.Print(info.String())
+	fmt.Print(status.String())
 	return nil
 }
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/cluster"
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
@@ -19,22 +26,30 @@ var (
 	}
 
 	upOpts = struct {
-		awsDebug, export, update bool
+		awsDebug, export, waitForNodes, importKeyPair bool
+		waitForNodesTimeout                           time.Duration
 	}{}
 )
 
 func init() {
 	cmdRoot.AddCommand(cmdUp)
 	cmdUp.Flags().BoolVar(&upOpts.export, "export", false, "Don't create cluster, instead export cloudformation stack file")
-	//	cmdUp.Flags().BoolVar(&upOpts.update, "update", false, "update existing cluster with new cloudformation stack")
 	cmdUp.Flags().BoolVar(&upOpts.awsDebug, "aws-debug", false, "Log debug information from aws-sdk-go library")
+	cmdUp.Flags().BoolVar(&upOpts.importKeyPair, "import-key-pair", false, "Import sshPublicKeyPath as keyName if no key pair of that name exists, instead of failing")
+	cmdUp.Flags().BoolVar(&upOpts.waitForNodes, "wait-for-nodes", false, "After creating the cluster, wait until workerCount worker nodes are Ready")
+	cmdUp.Flags().DurationVar(&upOpts.waitForNodesTimeout, "wait-for-nodes-timeout", 15*time.Minute, "How long --wait-for-nodes waits for the worker nodes")
 }
 
 func runCmdUp(cmd *cobra.Command, args []string) error {
-	conf, err := config.ClusterFromFile(configPath)
+	conf, err := clusterFromConfigFile()
 	if err != nil {
 		return fmt.Errorf("Failed to read cluster config: %v", err)
 	}
+	printWarnings(conf.Warnings())
+
+	if err := printTLSAssetsWarnings(conf, stackTemplateOptions.TLSAssetsDir); err != nil {
+		return err
+	}
 
 	if err := conf.ValidateUserData(stackTemplateOptions); err != nil {
 		return err
@@ -57,23 +72,47 @@ func runCmdUp(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	cluster := cluster.New(conf, upOpts.awsDebug)
-	if upOpts.update {
-		report, err := cluster.Update(string(data))
-		if err != nil {
-			return fmt.Errorf("Error updating cluster: %v", err)
-		}
-		if report != "" {
-			fmt.Printf("Update stack: %s\n", report)
+	tlsAssets, err := config.ReadTLSAssets(stackTemplateOptions.TLSAssetsDir)
+	if err != nil {
+		return fmt.Errorf("Failed to read TLS assets: %v", err)
+	}
+	fingerprints, err := tlsAssets.Fingerprints()
+	if err != nil {
+		return err
+	}
+
+	c := cluster.New(conf, upOpts.awsDebug)
+	c.ImportKeyPair = upOpts.importKeyPair
+	fmt.Printf("Creating AWS resources. This should take around 5 minutes.\n")
+	ctx, stop := interruptContext("Interrupted, no longer waiting for the stack to be created.")
+	importedKeyPair, err := c.Create(ctx, string(data))
+	interrupted := ctx.Err() != nil
+	stop()
+	if importedKeyPair {
+		fmt.Printf("Imported %s as key pair %s, which is not part of the stack and is kept when the cluster is destroyed.\n", conf.SSHPublicKeyPath, conf.KeyName)
+	}
+	if err != nil {
+		if canceledErr, ok := err.(*cluster.StackCreateCanceledError); ok {
+			return offerDestroyCanceledStack(c, conf, canceledErr)
 		}
-	} else {
-		fmt.Printf("Creating AWS resources. This should take around 5 minutes.\n")
-		if err := cluster.Create(string(data)); err != nil {
-			return fmt.Errorf("Error creating cluster: %v", err)
+		if interrupted {
+			if importedKeyPair {
+				return fmt.Errorf("Interrupted before the stack was created. No AWS resources other than key pair %s were created", conf.KeyName)
+			}
+			return fmt.Errorf("Interrupted before the stack was created. No AWS resources were created")
 		}
+		return fmt.Errorf("Error creating cluster: %v", err)
 	}
 
-	info, err := cluster.Info()
+	if err := tlsAssets.WriteFingerprints(stackTemplateOptions.TLSAssetsDir); err != nil {
+		return fmt.Errorf("Failed to record TLS certificate fingerprints: %v", err)
+	}
+	fmt.Printf("The cluster was deployed with these TLS certificates, recorded in %s:\n", filepath.Join(stackTemplateOptions.TLSAssetsDir, config.TLSFingerprintsFile))
+	for _, fingerprint := range fingerprints {
+		fmt.Printf("  %s\n", fingerprint)
+	}
+
+	info, err := c.Info()
 	if err != nil {
 		return fmt.Errorf("Failed fetching cluster info: %v", err)
 	}
@@ -87,5 +126,56 @@ You should be able to access the Kubernetes API once the containers finish downl
 `
 	fmt.Printf(successMsg, info.String())
 
+	if upOpts.waitForNodes {
+		fmt.Printf("Waiting up to %s for %d worker nodes to be Ready.\n", upOpts.waitForNodesTimeout, conf.WorkerCount)
+		ctx, stop := interruptContext("Interrupted, no longer waiting for the worker nodes.")
+		defer stop()
+		if err := c.WaitForNodes(ctx, stackTemplateOptions.TLSAssetsDir, upOpts.waitForNodesTimeout); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("Interrupted before the worker nodes were Ready. The cluster was created, check on them with kubectl get nodes")
+			}
+			return fmt.Errorf("Worker nodes not Ready: %v", err)
+		}
+		fmt.Printf("All %d worker nodes are Ready.\n", conf.WorkerCount)
+	}
+
 	return nil
 }
+
+// interruptContext returns a context canceled by the first interrupt, which
+// prints msg. After that interrupt or stop, the next one kills kube-aws.
+func interruptContext(msg string) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupts:
+			signal.Stop(interrupts)
+			fmt.Fprintf(os.Stderr, "\n%s\n", msg)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(interrupts)
+		cancel()
+	}
+}
+
+// offerDestroyCanceledStack asks whether to delete the stack whose creation
+// was interrupted, which CloudFormation otherwise keeps creating
+func offerDestroyCanceledStack(c *cluster.Cluster, conf *config.Cluster, canceledErr *cluster.StackCreateCanceledError) error {
+	fmt.Printf("CloudFormation is still creating stack %s.\n", conf.CloudFormationStackName())
+	fmt.Printf("Type the cluster name to destroy it, or anything else to leave it: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil || strings.TrimSpace(answer) != conf.ClusterName {
+		return fmt.Errorf("Error creating cluster: %v. The stack was left in place, destroy it with \"kube-aws destroy\"", canceledErr)
+	}
+
+	fmt.Println("Destroying CloudFormation stack. This will take several minutes")
+	if err := c.Destroy(); err != nil {
+		return fmt.Errorf("Failed destroying interrupted cluster: %v", err)
+	}
+	return fmt.Errorf("Cluster creation interrupted. The stack has been destroyed")
+}
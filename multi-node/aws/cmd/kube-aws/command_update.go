@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/cluster"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdUpdate = &cobra.Command{
+		Use:          "update",
+		Short:        "Update an existing Kubernetes cluster",
+		Long:         ``,
+		RunE:         runCmdUpdate,
+		SilenceUsage: true,
+	}
+
+	updateOpts = struct {
+		awsDebug bool
+	}{}
+)
+
+func init() {
+	cmdRoot.AddCommand(cmdUpdate)
+	cmdUpdate.Flags().BoolVar(&updateOpts.awsDebug, "aws-debug", false, "Log debug information from aws-sdk-go library")
+}
+
+func runCmdUpdate(cmd *cobra.Command, args []string) error {
+	conf, err := clusterFromConfigFile()
+	if err != nil {
+		return fmt.Errorf("Failed to read cluster config: %v", err)
+	}
+	printWarnings(conf.Warnings())
+
+	if err := printTLSAssetsWarnings(conf, stackTemplateOptions.TLSAssetsDir); err != nil {
+		return err
+	}
+
+	if err := conf.ValidateUserData(stackTemplateOptions); err != nil {
+		return err
+	}
+
+	data, err := conf.RenderStackTemplate(stackTemplateOptions)
+	if err != nil {
+		return fmt.Errorf("Failed to render stack template: %v", err)
+	}
+
+	cluster := cluster.New(conf, updateOpts.awsDebug)
+
+	fmt.Printf("Updating AWS resources. This may take several minutes.\n")
+	report, err := cluster.Update(string(data))
+	if err != nil {
+		return fmt.Errorf("Error updating cluster: %v", err)
+	}
+	if report == "" {
+		fmt.Printf("No updates are to be performed. The cluster is already up to date.\n")
+		return nil
+	}
+
+	fmt.Printf("Update stack: %s\n", report)
+	return nil
+}
@@ -1,11 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/cluster"
-	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
 	"github.com/spf13/cobra"
 )
 
@@ -13,16 +13,22 @@ var (
 	cmdValidate = &cobra.Command{
 		Use:          "validate",
 		Short:        "Validate cluster assets",
-		Long:         ``,
+		Long:         `Validates the userdata, the cluster's AWS resources and the stack template. With --output=json every check is run and its result written to stdout as a JSON array of {check, passed, message} objects, for CI gates and dashboards. The command fails if any check did.`,
 		RunE:         runCmdValidate,
 		SilenceUsage: true,
 	}
 
 	validateOpts = struct {
 		awsDebug bool
+		output   string
 	}{}
 )
 
+const (
+	validateOutputText = "text"
+	validateOutputJSON = "json"
+)
+
 func init() {
 	cmdRoot.AddCommand(cmdValidate)
 	cmdValidate.Flags().BoolVar(
@@ -31,13 +37,31 @@ func init() {
 		false,
 		"Log debug information from aws-sdk-go library",
 	)
+	cmdValidate.Flags().StringVar(
+		&validateOpts.output,
+		"output",
+		validateOutputText,
+		"Output format of the validation results, text or json",
+	)
 }
 
 func runCmdValidate(cmd *cobra.Command, args []string) error {
-	cfg, err := config.ClusterFromFile(configPath)
+	switch validateOpts.output {
+	case validateOutputText:
+	case validateOutputJSON:
+		return runCmdValidateJSON()
+	default:
+		return fmt.Errorf("--output must be %q or %q, got %q", validateOutputText, validateOutputJSON, validateOpts.output)
+	}
+
+	cfg, err := clusterFromConfigFile()
 	if err != nil {
 		return fmt.Errorf("Unable to load cluster config: %v", err)
 	}
+	printWarnings(cfg.Warnings())
+	if err := printTLSAssetsWarnings(cfg, stackTemplateOptions.TLSAssetsDir); err != nil {
+		return err
+	}
 
 	fmt.Printf("Validating UserData...\n")
 	if err := cfg.ValidateUserData(stackTemplateOptions); err != nil {
@@ -45,13 +69,20 @@ func runCmdValidate(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("UserData is valid.\n\n")
 
+	cluster := cluster.New(cfg, validateOpts.awsDebug)
+
+	fmt.Printf("Validating AWS resources...\n")
+	if err := cluster.ValidateAWSResources(); err != nil {
+		return err
+	}
+	fmt.Printf("AWS resources are valid.\n\n")
+
 	fmt.Printf("Validating stack template...\n")
 	data, err := cfg.RenderStackTemplate(stackTemplateOptions)
 	if err != nil {
 		return fmt.Errorf("Failed to render stack template: %v", err)
 	}
 
-	cluster := cluster.New(cfg, validateOpts.awsDebug)
 	report, err := cluster.ValidateStack(string(data))
 	if report != "" {
 		fmt.Fprintf(os.Stderr, "Validation Report: %s\n", report)
@@ -65,3 +96,44 @@ func runCmdValidate(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Validation OK!\n")
 	return nil
 }
+
+// runCmdValidateJSON runs every check, even after one fails, and writes the
+// results to stdout as JSON
+func runCmdValidateJSON() error {
+	var results []cluster.ValidationResult
+
+	cfg, err := clusterFromConfigFile()
+	results = append(results, cluster.NewValidationResult("config", err))
+	if err == nil {
+		printWarnings(cfg.Warnings())
+		results = append(results, cluster.NewValidationResult("userdata", cfg.ValidateUserData(stackTemplateOptions)))
+
+		c := cluster.New(cfg, validateOpts.awsDebug)
+		results = append(results, c.AWSResourceValidationResults()...)
+
+		data, err := cfg.RenderStackTemplate(stackTemplateOptions)
+		if err == nil {
+			_, err = c.ValidateStack(string(data))
+		} else {
+			err = fmt.Errorf("Failed to render stack template: %v", err)
+		}
+		results = append(results, cluster.NewValidationResult("stack-template", err))
+	}
+
+	out, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", out)
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d validation checks failed", failed, len(results))
+	}
+	return nil
+}
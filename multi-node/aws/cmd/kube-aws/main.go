@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
 	"github.com/spf13/cobra"
@@ -20,10 +23,80 @@ const configPath = "cluster.yaml"
 var stackTemplateOptions = config.StackTemplateOptions{
 	TLSAssetsDir:          "credentials",
 	ControllerTmplFile:    "userdata/cloud-config-controller",
+	EtcdTmplFile:          "userdata/cloud-config-etcd",
 	WorkerTmplFile:        "userdata/cloud-config-worker",
 	StackTemplateTmplFile: "stack-template.json",
 }
 
+// overrideFlags collects --set key=value flags, a later flag for a key
+// taking precedence over an earlier one
+type overrideFlags map[string]string
+
+func (o overrideFlags) String() string {
+	settings := make([]string, 0, len(o))
+	for key, value := range o {
+		settings = append(settings, key+"="+value)
+	}
+	sort.Strings(settings)
+	return strings.Join(settings, ",")
+}
+
+func (o overrideFlags) Set(setting string) error {
+	i := strings.Index(setting, "=")
+	if i < 1 {
+		return fmt.Errorf("%q is not of the form key=value", setting)
+	}
+	o[setting[:i]] = setting[i+1:]
+	return nil
+}
+
+func (o overrideFlags) Type() string {
+	return "key=value"
+}
+
+var configOverrides = overrideFlags{}
+
+func init() {
+	cmdRoot.PersistentFlags().Var(
+		configOverrides,
+		"set",
+		"Override a cluster.yaml key, taking precedence over KUBE_AWS_ environment variables and the file. Lists and maps are given in YAML, e.g. --set sshAccessCIDRs=[10.0.0.0/8]. May be repeated",
+	)
+}
+
+// clusterFromConfigFile reads the cluster config from cluster.yaml with the
+// overrides of the environment and of --set applied, in that order
+func clusterFromConfigFile() (*config.Cluster, error) {
+	envOverrides, err := config.OverridesFromEnv(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	return config.ClusterFromFileWithOverrides(configPath, config.MergeOverrides(envOverrides, configOverrides))
+}
+
+// printWarnings prints the warnings about the cluster config or its TLS
+// assets returned by the config package
+func printWarnings(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", warning)
+	}
+}
+
+// printTLSAssetsWarnings prints the warnings about the TLS assets in
+// tlsAssetsDir, which the stack is rendered from
+func printTLSAssetsWarnings(conf *config.Cluster, tlsAssetsDir string) error {
+	assets, err := config.ReadTLSAssets(tlsAssetsDir)
+	if err != nil {
+		return fmt.Errorf("Failed to read TLS assets: %v", err)
+	}
+	warnings, err := conf.TLSAssetsWarnings(assets)
+	if err != nil {
+		return err
+	}
+	printWarnings(warnings)
+	return nil
+}
+
 func main() {
 	if err := cmdRoot.Execute(); err != nil {
 		os.Exit(2)
@@ -2,8 +2,21 @@ package cluster
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -13,7 +26,11 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
 
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/config"
 )
@@ -22,8 +39,10 @@ import (
 var VERSION = "UNKNOWN"
 
 type Info struct {
-	Name         string
-	ControllerIP string
+	Name               string
+	ControllerIP       string
+	APIEndpoint        string
+	APIEndpointDNSName string
 }
 
 func (c *Info) String() string {
@@ -33,6 +52,10 @@ func (c *Info) String() string {
 
 	fmt.Fprintf(w, "Cluster Name:\t%s\n", c.Name)
 	fmt.Fprintf(w, "Controller IP:\t%s\n", c.ControllerIP)
+	if c.APIEndpoint != "" {
+		fmt.Fprintf(w, "API Endpoint:\t%s\n", c.APIEndpoint)
+	}
+	fmt.Fprintf(w, "API Endpoint DNS Name:\t%s\n", c.APIEndpointDNSName)
 
 	w.Flush()
 	return buf.String()
@@ -56,11 +79,21 @@ func New(cfg *config.Cluster, awsDebug bool) *Cluster {
 type Cluster struct {
 	config.Cluster
 	session *session.Session
+	// ImportKeyPair has Create import sshPublicKeyPath as keyName when no
+	// such key pair exists, rather than failing validation
+	ImportKeyPair bool
 }
 
 func (c *Cluster) ValidateStack(stackBody string) (string, error) {
+	templateBody, templateURL, cleanup, err := c.stackTemplateLocation(s3.New(c.session), stackBody)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
 	validateInput := cloudformation.ValidateTemplateInput{
-		TemplateBody: &stackBody,
+		TemplateBody: templateBody,
+		TemplateURL:  templateURL,
 	}
 
 	cfSvc := cloudformation.New(c.session)
@@ -72,10 +105,71 @@ func (c *Cluster) ValidateStack(stackBody string) (string, error) {
 	return validationReport.String(), nil
 }
 
+// Templates larger than this must be uploaded to S3 and passed to cloudformation by URL
+const stackTemplateBodySizeLimit = 51200
+
+type s3Service interface {
+	PutObject(*s3.PutObjectInput) (*s3.PutObjectOutput, error)
+	DeleteObject(*s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
+}
+
+// stackTemplateLocation returns either the inline template body or, when the
+// body exceeds the inline limit, the URL of a copy uploaded to s3Bucket. The
+// returned cleanup func removes the uploaded copy once cloudformation has read it.
+func (c *Cluster) stackTemplateLocation(s3Svc s3Service, stackBody string) (*string, *string, func(), error) {
+	if len(stackBody) <= stackTemplateBodySizeLimit {
+		return aws.String(stackBody), nil, func() {}, nil
+	}
+
+	if c.S3Bucket == "" {
+		return nil, nil, nil, fmt.Errorf(
+			"stack template is %d bytes, which exceeds the cloudformation limit of %d bytes. set s3Bucket to upload it to S3 instead",
+			len(stackBody),
+			stackTemplateBodySizeLimit,
+		)
+	}
+
+	// Keyed by content so repeated runs with an unchanged template reuse the same object
+	key := fmt.Sprintf("%s/stack-template-%x.json", c.CloudFormationStackName(), sha256.Sum256([]byte(stackBody)))
+	_, err := s3Svc.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(c.S3Bucket),
+		Key:         aws.String(key),
+		Body:        strings.NewReader(stackBody),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error uploading stack template to s3 bucket %s: %v", c.S3Bucket, err)
+	}
+
+	cleanup := func() {
+		s3Svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(c.S3Bucket),
+			Key:    aws.String(key),
+		})
+	}
+
+	templateURL := fmt.Sprintf("https://s3.amazonaws.com/%s/%s", c.S3Bucket, key)
+	switch {
+	case c.Partition() == "aws-cn":
+		//China regions only have the s3.<region> style of endpoint
+		templateURL = fmt.Sprintf("https://s3.%s.%s/%s/%s", c.Region, c.DNSSuffix(), c.S3Bucket, key)
+	case c.Region != "us-east-1":
+		templateURL = fmt.Sprintf("https://s3-%s.amazonaws.com/%s/%s", c.Region, c.S3Bucket, key)
+	}
+
+	return nil, aws.String(templateURL), cleanup, nil
+}
+
 type ec2Service interface {
 	DescribeVpcs(*ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error)
 	DescribeSubnets(*ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error)
 	DescribeKeyPairs(*ec2.DescribeKeyPairsInput) (*ec2.DescribeKeyPairsOutput, error)
+	ImportKeyPair(*ec2.ImportKeyPairInput) (*ec2.ImportKeyPairOutput, error)
+	DescribeAvailabilityZones(*ec2.DescribeAvailabilityZonesInput) (*ec2.DescribeAvailabilityZonesOutput, error)
+	DescribeImages(*ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error)
+	DescribeSecurityGroups(*ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error)
+	DescribeNetworkInterfaces(*ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error)
+	DescribeRouteTables(*ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error)
 }
 
 func (c *Cluster) validateExistingVPCState(ec2Svc ec2Service) error {
@@ -87,9 +181,16 @@ func (c *Cluster) validateExistingVPCState(ec2Svc ec2Service) error {
 	describeVpcsInput := ec2.DescribeVpcsInput{
 		VpcIds: []*string{aws.String(c.VPCID)},
 	}
-	vpcOutput, err := ec2Svc.DescribeVpcs(&describeVpcsInput)
+	var vpcOutput *ec2.DescribeVpcsOutput
+	err := withRetry(func() (err error) {
+		vpcOutput, err = ec2Svc.DescribeVpcs(&describeVpcsInput)
+		return
+	})
 	if err != nil {
-		return fmt.Errorf("error describing existing vpc: %v", err)
+		if isNotFoundError(err) {
+			return fmt.Errorf("could not find vpc %s in region %s", c.VPCID, c.Region)
+		}
+		return describeError("ec2:DescribeVpcs", "existing vpc", err)
 	}
 	if len(vpcOutput.Vpcs) == 0 {
 		return fmt.Errorf("could not find vpc %s in region %s", c.VPCID, c.Region)
@@ -110,88 +211,624 @@ func (c *Cluster) validateExistingVPCState(ec2Svc ec2Service) error {
 		)
 	}
 
-	describeSubnetsInput := ec2.DescribeSubnetsInput{
+	if c.RouteTableID != "" {
+		var routeTablesOutput *ec2.DescribeRouteTablesOutput
+		err := withRetry(func() (err error) {
+			routeTablesOutput, err = ec2Svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+				RouteTableIds: []*string{aws.String(c.RouteTableID)},
+			})
+			return
+		})
+		if err != nil {
+			if isNotFoundError(err) {
+				return fmt.Errorf("could not find route table %s in vpc %s", c.RouteTableID, c.VPCID)
+			}
+			return describeError("ec2:DescribeRouteTables", "route table "+c.RouteTableID, err)
+		}
+		if len(routeTablesOutput.RouteTables) == 0 {
+			return fmt.Errorf("could not find route table %s in vpc %s", c.RouteTableID, c.VPCID)
+		}
+		if routeTableVPCID := aws.StringValue(routeTablesOutput.RouteTables[0].VpcId); routeTableVPCID != c.VPCID {
+			return fmt.Errorf(
+				"route table %s belongs to vpc %s, not to the configured vpc %s",
+				c.RouteTableID,
+				routeTableVPCID,
+				c.VPCID,
+			)
+		}
+	}
+
+	if len(c.SubnetIDs) > 0 {
+		//The subnets are reused rather than created, so they can't conflict
+		//with the existing subnets of the vpc
+		if err := c.validateExistingSubnets(ec2Svc); err != nil {
+			return err
+		}
+	} else {
+		//DescribeSubnets is not paginated: every subnet of the vpc, whichever route
+		//table it is associated with, is returned in a single response
+		describeSubnetsInput := ec2.DescribeSubnetsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("vpc-id"),
+					Values: []*string{existingVPC.VpcId},
+				},
+			},
+		}
+
+		var subnetOutput *ec2.DescribeSubnetsOutput
+		err := withRetry(func() (err error) {
+			subnetOutput, err = ec2Svc.DescribeSubnets(&describeSubnetsInput)
+			return
+		})
+		if err != nil {
+			return describeError("ec2:DescribeSubnets", "subnets for vpc", err)
+		}
+
+		subnetCIDRS := make([]string, len(subnetOutput.Subnets))
+		for i, existingSubnet := range subnetOutput.Subnets {
+			subnetCIDRS[i] = *existingSubnet.CidrBlock
+		}
+
+		if err := c.ValidateExistingVPC(*existingVPC.CidrBlock, subnetCIDRS); err != nil {
+			return fmt.Errorf("error validating existing VPC: %v", err)
+		}
+	}
+
+	//Every controller and etcd IP must be free for the instances to claim
+	reservedIPs := map[string]string{}
+	for _, ip := range c.ControllerIPs() {
+		reservedIPs[ip] = "controller"
+	}
+	for _, ip := range c.EtcdIPs() {
+		reservedIPs[ip] = "etcd"
+	}
+	ips := make([]string, 0, len(reservedIPs))
+	for ip := range reservedIPs {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	describeNetworkInterfacesInput := ec2.DescribeNetworkInterfacesInput{
 		Filters: []*ec2.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []*string{existingVPC.VpcId},
 			},
+			{
+				Name:   aws.String("addresses.private-ip-address"),
+				Values: aws.StringSlice(ips),
+			},
 		},
 	}
+	var networkInterfaceOutput *ec2.DescribeNetworkInterfacesOutput
+	err = withRetry(func() (err error) {
+		networkInterfaceOutput, err = ec2Svc.DescribeNetworkInterfaces(&describeNetworkInterfacesInput)
+		return
+	})
+	if err != nil {
+		return describeError("ec2:DescribeNetworkInterfaces", "network interfaces for vpc", err)
+	}
+	for _, networkInterface := range networkInterfaceOutput.NetworkInterfaces {
+		for _, address := range networkInterface.PrivateIpAddresses {
+			ip := aws.StringValue(address.PrivateIpAddress)
+			if role, ok := reservedIPs[ip]; ok {
+				return fmt.Errorf(
+					"%s IP %s is already in use by network interface %s in vpc %s",
+					role,
+					ip,
+					aws.StringValue(networkInterface.NetworkInterfaceId),
+					c.VPCID,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// PermissionError is returned when the AWS credentials in use are denied an
+// API call kube-aws needs, as opposed to the resource being missing or
+// misconfigured
+type PermissionError struct {
+	// Action is the IAM action that was denied, e.g. ec2:DescribeVpcs
+	Action string
+	Err    error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("permission denied: the AWS credentials in use are not allowed %s, grant it to the IAM user or role running kube-aws: %v", e.Action, e.Err)
+}
+
+// isAccessDeniedError reports whether err is AWS refusing the call for lack
+// of permissions. EC2 and the other services use different codes.
+func isAccessDeniedError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case "UnauthorizedOperation", "AccessDenied", "AccessDeniedException":
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFoundError reports whether err is EC2 reporting that a requested
+// resource id does not exist
+func isNotFoundError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return strings.HasSuffix(awsErr.Code(), ".NotFound")
+	}
+	return false
+}
+
+// describeError wraps the error of a describe call, turning an access denied
+// error into a PermissionError naming action
+func describeError(action, description string, err error) error {
+	if isAccessDeniedError(err) {
+		return &PermissionError{Action: action, Err: err}
+	}
+	return fmt.Errorf("error describing %s: %v", description, err)
+}
+
+// Transient AWS errors are retried up to retryMaxAttempts calls in total,
+// backing off exponentially from retryBaseDelay with jitter. retrySleep is
+// replaced in tests.
+var (
+	retryMaxAttempts = 5
+	retryBaseDelay   = 1 * time.Second
+	retrySleep       = sleepContext
+)
+
+// sleepContext sleeps for d, returning ctx's error early if it is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isRetryableError reports whether err is AWS throttling the call or failing
+// on its side, which is worth retrying. Not found and access denied errors
+// aren't.
+func isRetryableError(err error) bool {
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() >= 500 {
+		return true
+	}
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case "Throttling", "ThrottlingException", "ThrottledException", "RequestThrottled",
+			"RequestLimitExceeded", "TooManyRequestsException", "PriorRequestNotComplete",
+			"InternalError", "InternalFailure", "ServiceUnavailable", "Unavailable":
+			return true
+		}
+	}
+	return false
+}
+
+// withRetry calls call until it succeeds, fails with an error that isn't
+// retryable, or has been attempted retryMaxAttempts times, returning the
+// last error
+func withRetry(call func() error) error {
+	return withRetryContext(context.Background(), call)
+}
+
+// withRetryContext is withRetry, giving up with ctx's error if it is done
+// while backing off
+func withRetryContext(ctx context.Context, call func() error) error {
+	var err error
+	for attempt := 0; attempt < retryMaxAttempts; attempt++ {
+		if attempt > 0 {
+			//Equal jitter: between half and all of the doubled delay
+			delay := retryBaseDelay << uint(attempt-1)
+			if sleepErr := retrySleep(ctx, delay/2+time.Duration(rand.Int63n(int64(delay/2)+1))); sleepErr != nil {
+				return sleepErr
+			}
+		}
+		if err = call(); err == nil || !isRetryableError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// validateExistingSubnets checks that each subnet of subnetIds exists in the
+// vpc with the availability zone and CIDR its entry in subnets declares, which
+// the controller and etcd IPs were placed by
+func (c *Cluster) validateExistingSubnets(ec2Svc ec2Service) error {
+	var subnetsOutput *ec2.DescribeSubnetsOutput
+	err := withRetry(func() (err error) {
+		subnetsOutput, err = ec2Svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+			SubnetIds: aws.StringSlice(c.SubnetIDs),
+		})
+		return
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && isNotFoundError(err) {
+			return fmt.Errorf("subnetIds: %s", awsErr.Message())
+		}
+		return describeError("ec2:DescribeSubnets", "subnetIds", err)
+	}
+
+	found := map[string]*ec2.Subnet{}
+	for _, subnet := range subnetsOutput.Subnets {
+		found[aws.StringValue(subnet.SubnetId)] = subnet
+	}
+	for i, subnetID := range c.SubnetIDs {
+		subnet, ok := found[subnetID]
+		if !ok {
+			return fmt.Errorf("subnetIds: subnet %s does not exist in region %s", subnetID, c.Region)
+		}
+		if vpcID := aws.StringValue(subnet.VpcId); vpcID != c.VPCID {
+			return fmt.Errorf("subnetIds: subnet %s is in vpc %s, not in vpcId %s", subnetID, vpcID, c.VPCID)
+		}
+		declared := c.Subnets[i]
+		if az := aws.StringValue(subnet.AvailabilityZone); az != declared.AvailabilityZone {
+			return fmt.Errorf(
+				"subnetIds: subnet %s is in availability zone %s, but subnet #%d declares availabilityZone %s",
+				subnetID,
+				az,
+				i,
+				declared.AvailabilityZone,
+			)
+		}
+		if cidr := aws.StringValue(subnet.CidrBlock); cidr != declared.InstanceCIDR {
+			return fmt.Errorf(
+				"subnetIds: subnet %s has cidr %s, but subnet #%d declares instanceCIDR %s",
+				subnetID,
+				cidr,
+				i,
+				declared.InstanceCIDR,
+			)
+		}
+	}
+
+	return nil
+}
+
+// ValidationResult is the outcome of a single check, for reporting to
+// automation rather than to a terminal
+type ValidationResult struct {
+	Check   string `json:"check"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
 
-	subnetOutput, err := ec2Svc.DescribeSubnets(&describeSubnetsInput)
+// NewValidationResult is the result of check failing with err, or passing if
+// err is nil
+func NewValidationResult(check string, err error) ValidationResult {
 	if err != nil {
-		return fmt.Errorf("error describing subnets for vpc: %v", err)
+		return ValidationResult{Check: check, Message: err.Error()}
 	}
+	return ValidationResult{Check: check, Passed: true}
+}
 
-	subnetCIDRS := make([]string, len(subnetOutput.Subnets))
-	for i, existingSubnet := range subnetOutput.Subnets {
-		subnetCIDRS[i] = *existingSubnet.CidrBlock
+// validateAll runs every check against the AWS account, returning the
+// result of each and the errors of those that failed
+func (c *Cluster) validateAll(ec2Svc ec2Service, r53Svc r53Service, kmsSvc kmsService, iamSvc iamService) ([]ValidationResult, ValidationErrors) {
+	checks := []struct {
+		name     string
+		validate func() error
+	}{
+		{"kms-key", func() error { return c.validateKMSKey(kmsSvc) }},
+		{"dns", func() error { return c.validateDNSConfig(r53Svc) }},
+		{"key-pair", func() error { return c.validateKeyPair(ec2Svc) }},
+		{"availability-zones", func() error { return c.validateAvailabilityZones(ec2Svc) }},
+		{"ami", func() error { return c.validateAMI(ec2Svc) }},
+		{"existing-vpc", func() error { return c.validateExistingVPCState(ec2Svc) }},
+		{"security-groups", func() error { return c.validateSecurityGroups(ec2Svc) }},
+		{"api-elb-subnets", func() error { return c.validateAPIELBSubnets(ec2Svc) }},
+		{"flannel-route-table", func() error { return c.validateFlannelRouteTable(ec2Svc) }},
+		{"iam-instance-profiles", func() error { return c.validateIAMInstanceProfiles(iamSvc) }},
 	}
 
-	if err := c.ValidateExistingVPC(*existingVPC.CidrBlock, subnetCIDRS); err != nil {
-		return fmt.Errorf("error validating existing VPC: %v", err)
+	results := make([]ValidationResult, len(checks))
+	var errs ValidationErrors
+	for i, check := range checks {
+		err := check.validate()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		results[i] = NewValidationResult(check.name, err)
 	}
+	return results, errs
+}
 
+// ValidateAll checks the cluster config against the AWS account without
+// creating anything, reporting every failed check rather than only the first.
+func (c *Cluster) ValidateAll(ec2Svc ec2Service, r53Svc r53Service, kmsSvc kmsService, iamSvc iamService) error {
+	if _, errs := c.validateAll(ec2Svc, r53Svc, kmsSvc, iamSvc); len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
-func (c *Cluster) Create(stackBody string) error {
-	r53Svc := route53.New(c.session)
-	if err := c.validateDNSConfig(r53Svc); err != nil {
-		return err
+// ValidationResults runs the checks of ValidateAll, returning the result of
+// every check whether it passed or not
+func (c *Cluster) ValidationResults(ec2Svc ec2Service, r53Svc r53Service, kmsSvc kmsService, iamSvc iamService) []ValidationResult {
+	results, _ := c.validateAll(ec2Svc, r53Svc, kmsSvc, iamSvc)
+	return results
+}
+
+// ValidationErrors is returned when one or more independent validations fail
+type ValidationErrors []error
+
+func (e ValidationErrors) Error() string {
+	errMsgs := make([]string, len(e))
+	for i, err := range e {
+		errMsgs[i] = err.Error()
 	}
+	return fmt.Sprintf("%d validation error(s):\n%s", len(e), strings.Join(errMsgs, "\n"))
+}
 
-	ec2Svc := ec2.New(c.session)
-	if err := c.validateKeyPair(ec2Svc); err != nil {
-		return err
+// ValidateAWSResources runs ValidateAll against the cluster's AWS account
+func (c *Cluster) ValidateAWSResources() error {
+	return c.ValidateAll(ec2.New(c.session), route53.New(c.session, c.route53Config()), kms.New(c.session), iam.New(c.session))
+}
+
+// AWSResourceValidationResults runs ValidationResults against the cluster's
+// AWS account
+func (c *Cluster) AWSResourceValidationResults() []ValidationResult {
+	return c.ValidationResults(ec2.New(c.session), route53.New(c.session, c.route53Config()), kms.New(c.session), iam.New(c.session))
+}
+
+// CheckPermissions runs checkPermissions against the cluster's AWS account
+func (c *Cluster) CheckPermissions() error {
+	return c.checkPermissions(ec2.New(c.session), route53.New(c.session, c.route53Config()), cloudformation.New(c.session), kms.New(c.session))
+}
+
+// permissionCheck is a read-only call that is denied when the credentials in
+// use lack action
+type permissionCheck struct {
+	action string
+	call   func() error
+}
+
+// Errors for credentials that are missing, invalid or expired rather than
+// lacking a permission, so no check can be trusted
+var credentialsErrorCodes = map[string]bool{
+	"NoCredentialProviders":       true,
+	"AuthFailure":                 true,
+	"InvalidClientTokenId":        true,
+	"UnrecognizedClientException": true,
+	"SignatureDoesNotMatch":       true,
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"RequestError":                true,
+}
+
+// checkPermissions makes the describe calls kube-aws relies on and reports
+// every one the credentials in use are denied. It doesn't validate the
+// resources: a call failing for any other reason, e.g. because a resource
+// doesn't exist, was still authorized and is left to ValidateAll.
+func (c *Cluster) checkPermissions(ec2Svc ec2Service, r53Svc r53Service, cfSvc cloudformationService, kmsSvc kmsService) error {
+	checks := []permissionCheck{
+		{"ec2:DescribeVpcs", func() error {
+			_, err := ec2Svc.DescribeVpcs(&ec2.DescribeVpcsInput{})
+			return err
+		}},
+		{"ec2:DescribeSubnets", func() error {
+			_, err := ec2Svc.DescribeSubnets(&ec2.DescribeSubnetsInput{})
+			return err
+		}},
+		{"ec2:DescribeRouteTables", func() error {
+			_, err := ec2Svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{})
+			return err
+		}},
+		{"ec2:DescribeNetworkInterfaces", func() error {
+			_, err := ec2Svc.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{})
+			return err
+		}},
+		{"ec2:DescribeSecurityGroups", func() error {
+			_, err := ec2Svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{})
+			return err
+		}},
+		{"ec2:DescribeKeyPairs", func() error {
+			_, err := ec2Svc.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
+				KeyNames: []*string{aws.String(c.KeyName)},
+			})
+			return err
+		}},
+		{"ec2:DescribeAvailabilityZones", func() error {
+			_, err := ec2Svc.DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesInput{})
+			return err
+		}},
+		{"ec2:DescribeImages", func() error {
+			_, err := ec2Svc.DescribeImages(&ec2.DescribeImagesInput{
+				Owners: []*string{aws.String("self")},
+			})
+			return err
+		}},
+		{"cloudformation:DescribeStacks", func() error {
+			_, err := cfSvc.DescribeStacks(&cloudformation.DescribeStacksInput{
+				StackName: aws.String(c.CloudFormationStackName()),
+			})
+			return err
+		}},
+		{"kms:DescribeKey", func() error {
+			_, err := kmsSvc.DescribeKey(&kms.DescribeKeyInput{
+				KeyId: aws.String(c.KMSKeyARN),
+			})
+			return err
+		}},
+	}
+	if c.CreateRecordSet {
+		checks = append(checks, permissionCheck{"route53:ListHostedZonesByName", func() error {
+			_, err := r53Svc.ListHostedZonesByName(&route53.ListHostedZonesByNameInput{
+				DNSName:  aws.String(c.HostedZone),
+				MaxItems: aws.String("1"),
+			})
+			return err
+		}})
 	}
 
-	if err := c.validateExistingVPCState(ec2Svc); err != nil {
-		return err
+	var missing MissingPermissionsError
+	for _, check := range checks {
+		err := check.call()
+		if err == nil {
+			continue
+		}
+		if isAccessDeniedError(err) {
+			missing = append(missing, check.action)
+			continue
+		}
+		if awsErr, ok := err.(awserr.Error); !ok || credentialsErrorCodes[awsErr.Code()] {
+			return fmt.Errorf("error checking %s, the AWS credentials could not be used: %v", check.action, err)
+		}
+	}
+
+	if len(missing) > 0 {
+		return missing
+	}
+	return nil
+}
+
+// MissingPermissionsError lists the IAM actions the credentials in use were denied
+type MissingPermissionsError []string
+
+func (e MissingPermissionsError) Error() string {
+	return fmt.Sprintf("the AWS credentials in use are missing %d permission(s) kube-aws needs:\n%s", len(e), strings.Join(e, "\n"))
+}
+
+// StackCreateCanceledError is returned by Create when its context is
+// canceled after the stack was created. The stack is left as it was,
+// partially created, and keeps being created by CloudFormation.
+type StackCreateCanceledError struct {
+	StackID string
+	Err     error
+}
+
+func (e *StackCreateCanceledError) Error() string {
+	return fmt.Sprintf("stopped waiting for stack %s to be created: %v", e.StackID, e.Err)
+}
+
+// Create creates the stack and waits for it to be created. Canceling ctx
+// stops polling the stack, returning a StackCreateCanceledError once the
+// stack exists. With ImportKeyPair a missing key pair is imported once
+// validation has passed; importedKeyPair reports whether it was, as the key
+// pair is left in place if creating the stack then fails.
+func (c *Cluster) Create(ctx context.Context, stackBody string) (importedKeyPair bool, err error) {
+	if err := c.CheckPermissions(); err != nil {
+		return false, err
+	}
+
+	if err := c.ValidateAWSResources(); err != nil {
+		return false, err
+	}
+
+	if c.ImportKeyPair {
+		if importedKeyPair, err = c.importMissingKeyPair(ec2.New(c.session)); err != nil {
+			return false, err
+		}
 	}
 
 	cfSvc := cloudformation.New(c.session)
-	resp, err := c.createStack(cfSvc, stackBody)
+	resp, err := c.createStack(ctx, cfSvc, s3.New(c.session), stackBody)
 	if err != nil {
-		return err
+		return importedKeyPair, err
+	}
+
+	timeout := time.Duration(c.StackCreationTimeout) * time.Minute
+	if err := c.waitForStackCreate(ctx, cfSvc, resp.StackId, timeout, os.Stdout); err != nil {
+		if ctx.Err() != nil {
+			return importedKeyPair, &StackCreateCanceledError{StackID: aws.StringValue(resp.StackId), Err: ctx.Err()}
+		}
+		return importedKeyPair, err
 	}
+	return importedKeyPair, nil
+}
 
+// waitForStackCreate polls the stack until creation finishes, ctx is canceled
+// or timeout has passed, writing each newly-seen resource status transition
+// to out as it happens.
+func (c *Cluster) waitForStackCreate(ctx context.Context, cfSvc cloudformationService, stackID *string, timeout time.Duration, out io.Writer) error {
 	req := cloudformation.DescribeStacksInput{
-		StackName: resp.StackId,
+		StackName: stackID,
+	}
+	seenEvents := map[string]bool{}
+
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var lastEvents []*cloudformation.StackEvent
+	stopped := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		errMsg := fmt.Sprintf("Timed out after %s waiting for the stack to be created", timeout)
+		if failed := stackEventErrMsgs(lastEvents); len(failed) > 0 {
+			errMsg = errMsg + "\n\nPrinting the most recent failed stack events:\n" + strings.Join(failed, "\n")
+		}
+		if pending := pendingStackResourceMsgs(lastEvents); len(pending) > 0 {
+			errMsg = errMsg + "\n\nPrinting the resources still being created:\n" + strings.Join(pending, "\n")
+		}
+		return errors.New(errMsg)
 	}
 
 	for {
-		resp, err := cfSvc.DescribeStacks(&req)
+		if pollCtx.Err() != nil {
+			return stopped()
+		}
+
+		var resp *cloudformation.DescribeStacksOutput
+		err := withRetryContext(pollCtx, func() (err error) {
+			resp, err = cfSvc.DescribeStacks(&req)
+			return
+		})
+		if pollCtx.Err() != nil {
+			return stopped()
+		}
 		if err != nil {
 			return err
 		}
 		if len(resp.Stacks) == 0 {
 			return fmt.Errorf("stack not found")
 		}
+
+		var stackEventsOutput *cloudformation.DescribeStackEventsOutput
+		err = withRetryContext(pollCtx, func() (err error) {
+			stackEventsOutput, err = cfSvc.DescribeStackEvents(
+				&cloudformation.DescribeStackEventsInput{
+					StackName: resp.Stacks[0].StackName,
+				})
+			return
+		})
+		if pollCtx.Err() != nil {
+			return stopped()
+		}
+		if err != nil {
+			return err
+		}
+		printNewStackEvents(out, stackEventsOutput.StackEvents, seenEvents)
+		lastEvents = stackEventsOutput.StackEvents
+
 		statusString := aws.StringValue(resp.Stacks[0].StackStatus)
 		switch statusString {
 		case cloudformation.ResourceStatusCreateComplete:
 			return nil
-		case cloudformation.ResourceStatusCreateFailed:
+		case cloudformation.ResourceStatusCreateFailed,
+			cloudformation.StackStatusRollbackInProgress,
+			cloudformation.StackStatusRollbackComplete,
+			cloudformation.StackStatusRollbackFailed:
 			errMsg := fmt.Sprintf(
 				"Stack creation failed: %s : %s",
 				statusString,
 				aws.StringValue(resp.Stacks[0].StackStatusReason),
 			)
 			errMsg = errMsg + "\n\nPrinting the most recent failed stack events:\n"
-
-			stackEventsOutput, err := cfSvc.DescribeStackEvents(
-				&cloudformation.DescribeStackEventsInput{
-					StackName: resp.Stacks[0].StackName,
-				})
-			if err != nil {
-				return err
-			}
 			errMsg = errMsg + strings.Join(stackEventErrMsgs(stackEventsOutput.StackEvents), "\n")
+			if !c.RollbackOnFailure {
+				errMsg = errMsg + "\n\nrollbackOnFailure is not set, so the resources created so far were left in place for inspection. Delete them with \"kube-aws destroy\" before trying again."
+			}
 			return errors.New(errMsg)
 		case cloudformation.ResourceStatusCreateInProgress:
-			time.Sleep(3 * time.Second)
+			select {
+			case <-pollCtx.Done():
+				return stopped()
+			case <-time.After(3 * time.Second):
+			}
 			continue
 		default:
 			return fmt.Errorf("unexpected stack status: %s", statusString)
@@ -199,11 +836,51 @@ func (c *Cluster) Create(stackBody string) error {
 	}
 }
 
+// printNewStackEvents writes events not already recorded in seen, oldest first.
+// DescribeStackEvents returns events in reverse chronological order.
+func printNewStackEvents(out io.Writer, events []*cloudformation.StackEvent, seen map[string]bool) {
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		eventID := aws.StringValue(event.EventId)
+		if seen[eventID] {
+			continue
+		}
+		seen[eventID] = true
+
+		timestamp := ""
+		if event.Timestamp != nil {
+			timestamp = event.Timestamp.Format(time.RFC3339)
+		}
+		fmt.Fprintln(out, strings.TrimSpace(
+			strings.Join([]string{
+				timestamp,
+				aws.StringValue(event.ResourceStatus),
+				aws.StringValue(event.ResourceType),
+				aws.StringValue(event.LogicalResourceId),
+				aws.StringValue(event.ResourceStatusReason),
+			}, " ")))
+	}
+}
+
 type cloudformationService interface {
 	CreateStack(*cloudformation.CreateStackInput) (*cloudformation.CreateStackOutput, error)
+	UpdateStack(*cloudformation.UpdateStackInput) (*cloudformation.UpdateStackOutput, error)
+	DescribeStacks(*cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error)
+	DescribeStackEvents(*cloudformation.DescribeStackEventsInput) (*cloudformation.DescribeStackEventsOutput, error)
+	DescribeStackResource(*cloudformation.DescribeStackResourceInput) (*cloudformation.DescribeStackResourceOutput, error)
+	DeleteStack(*cloudformation.DeleteStackInput) (*cloudformation.DeleteStackOutput, error)
 }
 
-func (c *Cluster) createStack(cfSvc cloudformationService, stackBody string) (*cloudformation.CreateStackOutput, error) {
+func (c *Cluster) createStack(ctx context.Context, cfSvc cloudformationService, s3Svc s3Service, stackBody string) (*cloudformation.CreateStackOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	templateBody, templateURL, cleanup, err := c.stackTemplateLocation(s3Svc, stackBody)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
 
 	var tags []*cloudformation.Tag
 	for k, v := range c.StackTags {
@@ -212,27 +889,58 @@ func (c *Cluster) createStack(cfSvc cloudformationService, stackBody string) (*c
 		tags = append(tags, &cloudformation.Tag{Key: &key, Value: &value})
 	}
 
+	onFailure := cloudformation.OnFailureDoNothing
+	if c.RollbackOnFailure {
+		onFailure = cloudformation.OnFailureRollback
+	}
+
 	creq := &cloudformation.CreateStackInput{
-		StackName:    aws.String(c.ClusterName),
-		OnFailure:    aws.String(cloudformation.OnFailureDoNothing),
-		Capabilities: []*string{aws.String(cloudformation.CapabilityCapabilityIam)},
-		TemplateBody: &stackBody,
-		Tags:         tags,
+		StackName:        aws.String(c.CloudFormationStackName()),
+		OnFailure:        aws.String(onFailure),
+		Capabilities:     []*string{aws.String(cloudformation.CapabilityCapabilityIam)},
+		TemplateBody:     templateBody,
+		TemplateURL:      templateURL,
+		Tags:             tags,
+		TimeoutInMinutes: aws.Int64(int64(c.StackCreationTimeout)),
+	}
+
+	//Uploading a large template to S3 may have outlasted the context
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
+	// CreateStack isn't idempotent: AWS may have accepted a call that failed
+	// with a 5xx, and retrying it would fail with AlreadyExistsException on
+	// a stack that is being created. The SDK's own retries are left to it.
 	return cfSvc.CreateStack(creq)
 }
 
 func (c *Cluster) Update(stackBody string) (string, error) {
 	cfSvc := cloudformation.New(c.session)
+	return c.updateStack(cfSvc, s3.New(c.session), stackBody)
+}
+
+// updateStack applies stackBody to the existing stack and waits for the update
+// to settle. An update that changes nothing is reported as an empty report.
+func (c *Cluster) updateStack(cfSvc cloudformationService, s3Svc s3Service, stackBody string) (string, error) {
+	templateBody, templateURL, cleanup, err := c.stackTemplateLocation(s3Svc, stackBody)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
 	input := &cloudformation.UpdateStackInput{
 		Capabilities: []*string{aws.String(cloudformation.CapabilityCapabilityIam)},
-		StackName:    aws.String(c.ClusterName),
-		TemplateBody: &stackBody,
+		StackName:    aws.String(c.CloudFormationStackName()),
+		TemplateBody: templateBody,
+		TemplateURL:  templateURL,
 	}
 
 	updateOutput, err := cfSvc.UpdateStack(input)
 	if err != nil {
+		if isNoUpdatesError(err) {
+			return "", nil
+		}
 		return "", fmt.Errorf("error updating cloudformation stack: %v", err)
 	}
 	req := cloudformation.DescribeStacksInput{
@@ -248,12 +956,29 @@ func (c *Cluster) Update(stackBody string) (string, error) {
 		}
 		statusString := aws.StringValue(resp.Stacks[0].StackStatus)
 		switch statusString {
-		case cloudformation.ResourceStatusUpdateComplete:
+		case cloudformation.StackStatusUpdateComplete:
 			return updateOutput.String(), nil
-		case cloudformation.ResourceStatusUpdateFailed, cloudformation.StackStatusUpdateRollbackComplete, cloudformation.StackStatusUpdateRollbackFailed:
-			errMsg := fmt.Sprintf("Stack status: %s : %s", statusString, aws.StringValue(resp.Stacks[0].StackStatusReason))
+		case cloudformation.StackStatusUpdateRollbackComplete, cloudformation.StackStatusUpdateRollbackFailed:
+			errMsg := fmt.Sprintf(
+				"Stack update failed: %s : %s",
+				statusString,
+				aws.StringValue(resp.Stacks[0].StackStatusReason),
+			)
+			errMsg = errMsg + "\n\nPrinting the most recent failed stack events:\n"
+
+			stackEventsOutput, err := cfSvc.DescribeStackEvents(
+				&cloudformation.DescribeStackEventsInput{
+					StackName: resp.Stacks[0].StackName,
+				})
+			if err != nil {
+				return "", err
+			}
+			errMsg = errMsg + strings.Join(stackEventErrMsgs(stackEventsOutput.StackEvents), "\n")
 			return "", errors.New(errMsg)
-		case cloudformation.ResourceStatusUpdateInProgress:
+		case cloudformation.StackStatusUpdateInProgress,
+			cloudformation.StackStatusUpdateCompleteCleanupInProgress,
+			cloudformation.StackStatusUpdateRollbackInProgress,
+			cloudformation.StackStatusUpdateRollbackCompleteCleanupInProgress:
 			time.Sleep(3 * time.Second)
 			continue
 		default:
@@ -262,35 +987,489 @@ func (c *Cluster) Update(stackBody string) (string, error) {
 	}
 }
 
-func (c *Cluster) Info() (*Info, error) {
-	cfSvc := cloudformation.New(c.session)
-	resp, err := cfSvc.DescribeStackResource(
-		&cloudformation.DescribeStackResourceInput{
-			LogicalResourceId: aws.String("EIPController"),
-			StackName:         aws.String(c.ClusterName),
-		},
-	)
-	if err != nil {
-		errmsg := "unable to get public IP of controller instance:\n" + err.Error()
-		return nil, fmt.Errorf(errmsg)
+// CloudFormation rejects updates which would not change any resources
+// with a ValidationError rather than a distinct error code.
+func isNoUpdatesError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == "ValidationError" &&
+			strings.Contains(awsErr.Message(), "No updates are to be performed")
 	}
+	return false
+}
 
+func (c *Cluster) Info() (*Info, error) {
 	var info Info
-	info.ControllerIP = *resp.StackResourceDetail.PhysicalResourceId
 	info.Name = c.ClusterName
-	return &info, nil
-}
 
-func (c *Cluster) Destroy() error {
 	cfSvc := cloudformation.New(c.session)
-	dreq := &cloudformation.DeleteStackInput{
-		StackName: aws.String(c.ClusterName),
-	}
-	_, err := cfSvc.DeleteStack(dreq)
-	return err
+	if c.APIEndpointInternal || c.NATMode != "" {
+		//Internal clusters and clusters in private subnets have no EIP, the
+		//controller is only reachable at its private IP
+		info.ControllerIP = c.ControllerIP
+	} else {
+		resp, err := cfSvc.DescribeStackResource(
+			&cloudformation.DescribeStackResourceInput{
+				LogicalResourceId: aws.String("EIPController"),
+				StackName:         aws.String(c.CloudFormationStackName()),
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get public IP of controller instance:\n%v", err)
+		}
+		info.ControllerIP = *resp.StackResourceDetail.PhysicalResourceId
+	}
+
+	outputs, err := c.stackOutputs(cfSvc)
+	if err != nil {
+		return nil, err
+	}
+	info.APIEndpoint = outputs["APIEndpoint"]
+	info.APIEndpointDNSName = outputs["APIEndpointDNSName"]
+
+	return &info, nil
+}
+
+// StackOutputs returns the outputs of the cluster's stack, keyed by output name
+func (c *Cluster) StackOutputs() (map[string]string, error) {
+	return c.stackOutputs(cloudformation.New(c.session))
+}
+
+func (c *Cluster) stackOutputs(cfSvc cloudformationService) (map[string]string, error) {
+	stacksResp, err := cfSvc.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String(c.CloudFormationStackName()),
+	})
+	if err != nil {
+		if isStackNotFoundError(err) {
+			return nil, ErrStackNotFound
+		}
+		return nil, fmt.Errorf("unable to describe stack:\n%v", err)
+	}
+	if len(stacksResp.Stacks) == 0 {
+		return nil, ErrStackNotFound
+	}
+
+	outputs := map[string]string{}
+	for _, output := range stacksResp.Stacks[0].Outputs {
+		outputs[aws.StringValue(output.OutputKey)] = aws.StringValue(output.OutputValue)
+	}
+	return outputs, nil
+}
+
+// Status reports whether a created cluster is ready to serve the Kubernetes API
+type Status struct {
+	StackStatus              string `json:"stackStatus"`
+	APIELBInstances          int    `json:"apiELBInstances"`
+	APIELBInstancesInService int    `json:"apiELBInstancesInService"`
+	ExternalDNSName          string `json:"externalDNSName"`
+	ExternalDNSNameResolves  bool   `json:"externalDNSNameResolves"`
+}
+
+// Ready is true once the stack is complete, every controller behind the API
+// load balancer is in service and externalDNSName resolves to the load balancer.
+func (s *Status) Ready() bool {
+	stackComplete := s.StackStatus == cloudformation.StackStatusCreateComplete ||
+		s.StackStatus == cloudformation.StackStatusUpdateComplete
+	return stackComplete &&
+		s.APIELBInstances > 0 &&
+		s.APIELBInstancesInService == s.APIELBInstances &&
+		s.ExternalDNSNameResolves
+}
+
+func (s *Status) String() string {
+	buf := new(bytes.Buffer)
+	w := new(tabwriter.Writer)
+	w.Init(buf, 0, 8, 0, '\t', 0)
+
+	fmt.Fprintf(w, "Stack Status:\t%s\n", s.StackStatus)
+	fmt.Fprintf(w, "API Load Balancer Instances In Service:\t%d/%d\n", s.APIELBInstancesInService, s.APIELBInstances)
+	fmt.Fprintf(w, "%s Resolves To Load Balancer:\t%t\n", s.ExternalDNSName, s.ExternalDNSNameResolves)
+	fmt.Fprintf(w, "Ready:\t%t\n", s.Ready())
+
+	w.Flush()
+	return buf.String()
+}
+
+type elbService interface {
+	DescribeLoadBalancers(*elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error)
+	DescribeInstanceHealth(*elb.DescribeInstanceHealthInput) (*elb.DescribeInstanceHealthOutput, error)
+}
+
+func (c *Cluster) Status() (*Status, error) {
+	return c.status(cloudformation.New(c.session), elb.New(c.session), net.LookupIP)
+}
+
+func (c *Cluster) status(cfSvc cloudformationService, elbSvc elbService, lookupIP func(string) ([]net.IP, error)) (*Status, error) {
+	status := &Status{ExternalDNSName: c.ExternalDNSName}
+
+	stacksResp, err := cfSvc.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String(c.CloudFormationStackName()),
+	})
+	if err != nil {
+		if isStackNotFoundError(err) {
+			return nil, ErrStackNotFound
+		}
+		return nil, fmt.Errorf("unable to describe stack:\n%v", err)
+	}
+	if len(stacksResp.Stacks) == 0 {
+		return nil, ErrStackNotFound
+	}
+	status.StackStatus = aws.StringValue(stacksResp.Stacks[0].StackStatus)
+
+	resourceResp, err := cfSvc.DescribeStackResource(&cloudformation.DescribeStackResourceInput{
+		LogicalResourceId: aws.String("ElbAPIServer"),
+		StackName:         aws.String(c.CloudFormationStackName()),
+	})
+	if err != nil {
+		if isStackNotFoundError(err) {
+			//The stack has not created the load balancer yet
+			return status, nil
+		}
+		return nil, fmt.Errorf("unable to get API load balancer of stack:\n%v", err)
+	}
+	elbName := resourceResp.StackResourceDetail.PhysicalResourceId
+
+	healthResp, err := elbSvc.DescribeInstanceHealth(&elb.DescribeInstanceHealthInput{
+		LoadBalancerName: elbName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get health of API load balancer instances:\n%v", err)
+	}
+	status.APIELBInstances = len(healthResp.InstanceStates)
+	for _, instanceState := range healthResp.InstanceStates {
+		if aws.StringValue(instanceState.State) == "InService" {
+			status.APIELBInstancesInService++
+		}
+	}
+
+	elbResp, err := elbSvc.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{elbName},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe API load balancer:\n%v", err)
+	}
+	if len(elbResp.LoadBalancerDescriptions) == 0 {
+		return nil, fmt.Errorf("API load balancer %s not found", aws.StringValue(elbName))
+	}
+	status.ExternalDNSNameResolves = resolvesToSameAddress(
+		lookupIP,
+		c.ExternalDNSName,
+		aws.StringValue(elbResp.LoadBalancerDescriptions[0].DNSName),
+	)
+
+	return status, nil
+}
+
+// resolvesToSameAddress is true if name resolves to at least one of the
+// addresses of target. An ELB's addresses change over time, so comparing a
+// single lookup of each is the best available check.
+func resolvesToSameAddress(lookupIP func(string) ([]net.IP, error), name, target string) bool {
+	nameIPs, err := lookupIP(name)
+	if err != nil {
+		return false
+	}
+	targetIPs, err := lookupIP(target)
+	if err != nil {
+		return false
+	}
+
+	for _, nameIP := range nameIPs {
+		for _, targetIP := range targetIPs {
+			if nameIP.Equal(targetIP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeStatus is the part of a Kubernetes node WaitForNodes looks at
+type nodeStatus struct {
+	Name        string
+	Ready       bool
+	Schedulable bool
+}
+
+// NodesNotReadyError is returned by WaitForNodes when fewer than the expected
+// number of workers became Ready in time
+type NodesNotReadyError struct {
+	Expected int
+	Ready    []string
+	// NotReady are the workers that registered but are not Ready
+	NotReady []string
+}
+
+func (e *NodesNotReadyError) Error() string {
+	missing := e.Expected - len(e.Ready) - len(e.NotReady)
+	if missing < 0 {
+		missing = 0
+	}
+	return fmt.Sprintf(
+		"%d of %d worker nodes are Ready. registered but not Ready: [%s]. never registered: %d",
+		len(e.Ready),
+		e.Expected,
+		strings.Join(e.NotReady, ", "),
+		missing,
+	)
+}
+
+// WaitForNodes polls the API server, authenticating with the admin TLS assets
+// in tlsAssetsDir, until workerCount schedulable nodes are Ready. Controllers
+// register as unschedulable nodes and are not counted. The API is reached
+// through the load balancer so externalDNSName doesn't need to resolve yet.
+// Canceling ctx stops waiting with its error.
+func (c *Cluster) WaitForNodes(ctx context.Context, tlsAssetsDir string, timeout time.Duration) error {
+	outputs, err := c.StackOutputs()
+	if err != nil {
+		return err
+	}
+	listNodes, err := c.apiNodeLister(ctx, tlsAssetsDir, outputs["APIEndpointDNSName"])
+	if err != nil {
+		return err
+	}
+	return waitForReadyNodes(ctx, listNodes, c.WorkerCount, timeout, 10*time.Second)
+}
+
+// apiNodeLister lists the nodes of the cluster through the API server at host,
+// verifying its certificate against externalDNSName. Requests are canceled
+// with ctx.
+func (c *Cluster) apiNodeLister(ctx context.Context, tlsAssetsDir, host string) (func() ([]nodeStatus, error), error) {
+	caCert, err := ioutil.ReadFile(filepath.Join(tlsAssetsDir, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA certificate: %v", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("no certificate found in %s", filepath.Join(tlsAssetsDir, "ca.pem"))
+	}
+	adminCert, err := tls.LoadX509KeyPair(
+		filepath.Join(tlsAssetsDir, "admin.pem"),
+		filepath.Join(tlsAssetsDir, "admin-key.pem"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error reading admin TLS assets: %v", err)
+	}
+
+	if host == "" {
+		host = c.ExternalDNSName
+	}
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      caPool,
+				Certificates: []tls.Certificate{adminCert},
+				ServerName:   c.ExternalDNSName,
+			},
+		},
+	}
+	url := fmt.Sprintf("https://%s/api/v1/nodes", host)
+
+	return func() ([]nodeStatus, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("listing nodes returned %s: %s", resp.Status, body)
+		}
+		return parseNodeList(body)
+	}, nil
+}
+
+func parseNodeList(data []byte) ([]nodeStatus, error) {
+	var nodeList struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Unschedulable bool `json:"unschedulable"`
+			} `json:"spec"`
+			Status struct {
+				Conditions []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &nodeList); err != nil {
+		return nil, fmt.Errorf("error decoding node list: %v", err)
+	}
+
+	nodes := make([]nodeStatus, len(nodeList.Items))
+	for i, item := range nodeList.Items {
+		nodes[i] = nodeStatus{
+			Name:        item.Metadata.Name,
+			Schedulable: !item.Spec.Unschedulable,
+		}
+		for _, condition := range item.Status.Conditions {
+			if condition.Type == "Ready" {
+				nodes[i].Ready = condition.Status == "True"
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// waitForReadyNodes lists nodes every interval until expected schedulable
+// nodes are Ready. Errors listing nodes are retried, as the API server may
+// still be starting. It gives up with ctx's error once ctx is done.
+func waitForReadyNodes(ctx context.Context, listNodes func() ([]nodeStatus, error), expected int, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		nodes, err := listNodes()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		notReadyErr := &NodesNotReadyError{Expected: expected}
+		for _, node := range nodes {
+			if !node.Schedulable {
+				continue
+			}
+			if node.Ready {
+				notReadyErr.Ready = append(notReadyErr.Ready, node.Name)
+			} else {
+				notReadyErr.NotReady = append(notReadyErr.NotReady, node.Name)
+			}
+		}
+		if err == nil && len(notReadyErr.Ready) >= expected {
+			return nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			if err != nil {
+				return fmt.Errorf("timed out waiting for nodes, last error listing them: %v", err)
+			}
+			return notReadyErr
+		}
+		if err := sleepContext(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// ErrStackNotFound is returned when the cluster's cloudformation stack does not exist
+var ErrStackNotFound = errors.New("cloudformation stack does not exist")
+
+func (c *Cluster) Destroy() error {
+	cfSvc := cloudformation.New(c.session)
+	return c.destroyStack(cfSvc)
+}
+
+func (c *Cluster) destroyStack(cfSvc cloudformationService) error {
+	describeResp, err := cfSvc.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String(c.CloudFormationStackName()),
+	})
+	if err != nil {
+		if isStackNotFoundError(err) {
+			return ErrStackNotFound
+		}
+		return fmt.Errorf("error describing cloudformation stack: %v", err)
+	}
+	if len(describeResp.Stacks) == 0 {
+		return ErrStackNotFound
+	}
+
+	// Poll by stack id. Deleted stacks can no longer be described by name.
+	stackID := describeResp.Stacks[0].StackId
+
+	dreq := &cloudformation.DeleteStackInput{
+		StackName: aws.String(c.CloudFormationStackName()),
+	}
+	if _, err := cfSvc.DeleteStack(dreq); err != nil {
+		return fmt.Errorf("error deleting cloudformation stack: %v", err)
+	}
+
+	req := cloudformation.DescribeStacksInput{
+		StackName: stackID,
+	}
+	for {
+		resp, err := cfSvc.DescribeStacks(&req)
+		if err != nil {
+			if isStackNotFoundError(err) {
+				return nil
+			}
+			return err
+		}
+		if len(resp.Stacks) == 0 {
+			return nil
+		}
+		statusString := aws.StringValue(resp.Stacks[0].StackStatus)
+		switch statusString {
+		case cloudformation.StackStatusDeleteComplete:
+			return nil
+		case cloudformation.StackStatusDeleteFailed:
+			errMsg := fmt.Sprintf(
+				"Stack deletion failed: %s : %s",
+				statusString,
+				aws.StringValue(resp.Stacks[0].StackStatusReason),
+			)
+			errMsg = errMsg + "\n\nPrinting the resources which blocked deletion:\n"
+
+			stackEventsOutput, err := cfSvc.DescribeStackEvents(
+				&cloudformation.DescribeStackEventsInput{
+					StackName: stackID,
+				})
+			if err != nil {
+				return err
+			}
+			errMsg = errMsg + strings.Join(stackDeleteEventErrMsgs(stackEventsOutput.StackEvents), "\n")
+			return errors.New(errMsg)
+		case cloudformation.StackStatusDeleteInProgress:
+			time.Sleep(3 * time.Second)
+			continue
+		default:
+			return fmt.Errorf("unexpected stack status: %s", statusString)
+		}
+	}
+}
+
+func isStackNotFoundError(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == "ValidationError" &&
+			strings.Contains(awsErr.Message(), "does not exist")
+	}
+	return false
+}
+
+// EC2 key pair names are at most this many ASCII characters
+const maxKeyPairNameLength = 255
+
+// validateKeyPairName checks name against the constraints EC2 puts on key
+// pair names, so a name that can't exist is caught without an API call
+func validateKeyPairName(name string) error {
+	if name == "" {
+		return errors.New("keyName must be set")
+	}
+	if len(name) > maxKeyPairNameLength {
+		return fmt.Errorf("keyName must be at most %d characters, got %d", maxKeyPairNameLength, len(name))
+	}
+	for _, r := range name {
+		if r < ' ' || r > '~' {
+			return fmt.Errorf("keyName %q must only contain printable ASCII characters, got %q", name, r)
+		}
+	}
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("keyName %q must not start or end with a space", name)
+	}
+	return nil
 }
 
 func (c *Cluster) validateKeyPair(ec2Svc ec2Service) error {
+	if err := validateKeyPairName(c.KeyName); err != nil {
+		return err
+	}
+
 	_, err := ec2Svc.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
 		KeyNames: []*string{aws.String(c.KeyName)},
 	})
@@ -298,6 +1477,11 @@ func (c *Cluster) validateKeyPair(ec2Svc ec2Service) error {
 	if err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
 			if awsErr.Code() == "InvalidKeyPair.NotFound" {
+				if c.ImportKeyPair {
+					//Create imports it once every check has passed
+					_, err := c.readSSHPublicKey()
+					return err
+				}
 				return fmt.Errorf("Key %s does not exist.", c.KeyName)
 			}
 		}
@@ -306,9 +1490,426 @@ func (c *Cluster) validateKeyPair(ec2Svc ec2Service) error {
 	return nil
 }
 
+// readSSHPublicKey reads the public key at sshPublicKeyPath, in which a
+// leading ~ is the home directory
+func (c *Cluster) readSSHPublicKey() ([]byte, error) {
+	path := c.SSHPublicKeyPath
+	if path == "" {
+		return nil, fmt.Errorf("Key %s does not exist, and sshPublicKeyPath is not set to import it from.", c.KeyName)
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+	publicKey, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sshPublicKeyPath to import as key %s: %v", c.KeyName, err)
+	}
+	// EC2 checks the key format itself, but a private key must never leave
+	// this machine
+	if bytes.Contains(publicKey, []byte("PRIVATE KEY")) {
+		return nil, fmt.Errorf("sshPublicKeyPath %s is a private key. set it to the public key, usually the same path ending in .pub", c.SSHPublicKeyPath)
+	}
+	return publicKey, nil
+}
+
+// importMissingKeyPair imports the public key at sshPublicKeyPath as keyName
+// unless a key pair of that name exists, reporting whether it did
+func (c *Cluster) importMissingKeyPair(ec2Svc ec2Service) (bool, error) {
+	err := withRetry(func() error {
+		_, err := ec2Svc.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
+			KeyNames: []*string{aws.String(c.KeyName)},
+		})
+		return err
+	})
+	if err == nil {
+		return false, nil
+	}
+	if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "InvalidKeyPair.NotFound" {
+		return false, err
+	}
+
+	publicKey, err := c.readSSHPublicKey()
+	if err != nil {
+		return false, err
+	}
+	// Like CreateStack, ImportKeyPair isn't retried: after a 5xx the key may
+	// have been imported, and a retry would fail as a duplicate
+	_, err = ec2Svc.ImportKeyPair(&ec2.ImportKeyPairInput{
+		KeyName:           aws.String(c.KeyName),
+		PublicKeyMaterial: publicKey,
+	})
+	if err != nil {
+		return false, fmt.Errorf("error importing %s as key %s: %v", c.SSHPublicKeyPath, c.KeyName, err)
+	}
+	return true, nil
+}
+
+func (c *Cluster) validateAvailabilityZones(ec2Svc ec2Service) error {
+	azOutput, err := ec2Svc.DescribeAvailabilityZones(&ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return fmt.Errorf("error describing availability zones: %v", err)
+	}
+
+	validZones := []string{}
+	zoneStates := map[string]string{}
+	for _, az := range azOutput.AvailabilityZones {
+		zoneName := aws.StringValue(az.ZoneName)
+		validZones = append(validZones, zoneName)
+		zoneStates[zoneName] = aws.StringValue(az.State)
+	}
+
+	for _, subnet := range c.Subnets {
+		state, ok := zoneStates[subnet.AvailabilityZone]
+		if !ok {
+			return fmt.Errorf(
+				"availability zone %s does not exist in region %s. valid zones are: %s",
+				subnet.AvailabilityZone,
+				c.Region,
+				strings.Join(validZones, ", "),
+			)
+		}
+		if state != ec2.AvailabilityZoneStateAvailable {
+			return fmt.Errorf(
+				"availability zone %s in region %s is not available (state=%s)",
+				subnet.AvailabilityZone,
+				c.Region,
+				state,
+			)
+		}
+	}
+
+	return nil
+}
+
+func (c *Cluster) validateSecurityGroups(ec2Svc ec2Service) error {
+	sgIDs := append(append([]string{}, c.ControllerSecurityGroupIds...), c.WorkerSecurityGroupIds...)
+	if len(sgIDs) == 0 {
+		return nil
+	}
+
+	sgOutput, err := ec2Svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		GroupIds: aws.StringSlice(sgIDs),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "InvalidGroup.NotFound" {
+			return fmt.Errorf("security group not found: %s", awsErr.Message())
+		}
+		return fmt.Errorf("error describing security groups %v: %v", sgIDs, err)
+	}
+
+	found := map[string]*ec2.SecurityGroup{}
+	for _, sg := range sgOutput.SecurityGroups {
+		found[aws.StringValue(sg.GroupId)] = sg
+	}
+
+	for _, sgID := range sgIDs {
+		sg, ok := found[sgID]
+		if !ok {
+			return fmt.Errorf("security group %s does not exist", sgID)
+		}
+		if vpcID := aws.StringValue(sg.VpcId); vpcID != c.VPCID {
+			return fmt.Errorf(
+				"security group %s belongs to vpc %s, but the cluster is deployed to vpc %s",
+				sgID,
+				vpcID,
+				c.VPCID,
+			)
+		}
+	}
+
+	return nil
+}
+
+// AWS account which publishes the official CoreOS AMIs
+const coreOSAMIOwnerID = "595879546273"
+
+// validateAPIELBSubnets checks that the subnets given for the API load
+// balancer are in vpcId and, unless it is internal, can reach the internet
+func (c *Cluster) validateAPIELBSubnets(ec2Svc ec2Service) error {
+	if len(c.APIELBSubnetIds) == 0 {
+		return nil
+	}
+
+	subnetsOutput, err := ec2Svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(c.APIELBSubnetIds),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "InvalidSubnetID.NotFound" {
+			return fmt.Errorf("apiELBSubnetIds: %s", awsErr.Message())
+		}
+		return fmt.Errorf("error describing apiELBSubnetIds: %v", err)
+	}
+
+	found := map[string]*ec2.Subnet{}
+	for _, subnet := range subnetsOutput.Subnets {
+		found[aws.StringValue(subnet.SubnetId)] = subnet
+	}
+	for _, subnetID := range c.APIELBSubnetIds {
+		subnet, ok := found[subnetID]
+		if !ok {
+			return fmt.Errorf("apiELBSubnetIds: subnet %s does not exist in region %s", subnetID, c.Region)
+		}
+		if vpcID := aws.StringValue(subnet.VpcId); vpcID != c.VPCID {
+			return fmt.Errorf("apiELBSubnetIds: subnet %s is in vpc %s, not in vpcId %s", subnetID, vpcID, c.VPCID)
+		}
+		if c.APIEndpointInternal {
+			continue
+		}
+
+		routeTable, err := subnetRouteTable(ec2Svc, c.VPCID, subnetID)
+		if err != nil {
+			return err
+		}
+		if !routesToInternetGateway(routeTable) {
+			return fmt.Errorf(
+				"apiELBSubnetIds: subnet %s is not public, its route table %s has no route to an internet gateway. An internet-facing load balancer needs public subnets",
+				subnetID,
+				aws.StringValue(routeTable.RouteTableId),
+			)
+		}
+	}
+
+	return nil
+}
+
+// validateFlannelRouteTable checks that the existing route table the aws-vpc
+// flannel backend writes node routes to is shared by the nodes' subnets and
+// has room for a route per node. A route table kube-aws creates starts empty.
+func (c *Cluster) validateFlannelRouteTable(ec2Svc ec2Service) error {
+	if c.FlannelRoutes() == 0 || c.VPCID == "" {
+		return nil
+	}
+
+	var routeTable *ec2.RouteTable
+	switch {
+	case len(c.SubnetIDs) > 0:
+		for _, subnetID := range c.SubnetIDs {
+			subnetTable, err := subnetRouteTable(ec2Svc, c.VPCID, subnetID)
+			if err != nil {
+				return err
+			}
+			if routeTable != nil && aws.StringValue(subnetTable.RouteTableId) != aws.StringValue(routeTable.RouteTableId) {
+				return fmt.Errorf(
+					"flannelBackend %s needs every node subnet to share a route table, but subnet %s uses %s and subnet %s uses %s",
+					c.FlannelBackend,
+					c.SubnetIDs[0],
+					aws.StringValue(routeTable.RouteTableId),
+					subnetID,
+					aws.StringValue(subnetTable.RouteTableId),
+				)
+			}
+			routeTable = subnetTable
+		}
+	case c.RouteTableID != "":
+		routeTablesOutput, err := ec2Svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+			RouteTableIds: []*string{aws.String(c.RouteTableID)},
+		})
+		if err != nil {
+			if isNotFoundError(err) {
+				return fmt.Errorf("could not find route table %s in vpc %s", c.RouteTableID, c.VPCID)
+			}
+			return describeError("ec2:DescribeRouteTables", "route table "+c.RouteTableID, err)
+		}
+		if len(routeTablesOutput.RouteTables) == 0 {
+			return fmt.Errorf("could not find route table %s in vpc %s", c.RouteTableID, c.VPCID)
+		}
+		routeTable = routeTablesOutput.RouteTables[0]
+	default:
+		return nil
+	}
+
+	return c.ValidateFlannelRouteTable(aws.StringValue(routeTable.RouteTableId), len(routeTable.Routes))
+}
+
+// subnetRouteTable returns the route table explicitly associated with a
+// subnet, or the main route table of its VPC which applies otherwise
+func subnetRouteTable(ec2Svc ec2Service, vpcID, subnetID string) (*ec2.RouteTable, error) {
+	for _, filter := range []*ec2.Filter{
+		{
+			Name:   aws.String("association.subnet-id"),
+			Values: []*string{aws.String(subnetID)},
+		},
+		{
+			Name:   aws.String("association.main"),
+			Values: []*string{aws.String("true")},
+		},
+	} {
+		routeTablesOutput, err := ec2Svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("vpc-id"),
+					Values: []*string{aws.String(vpcID)},
+				},
+				filter,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error describing route tables of vpc %s: %v", vpcID, err)
+		}
+		if len(routeTablesOutput.RouteTables) > 0 {
+			return routeTablesOutput.RouteTables[0], nil
+		}
+	}
+	return nil, fmt.Errorf("could not find the route table of subnet %s in vpc %s", subnetID, vpcID)
+}
+
+func routesToInternetGateway(routeTable *ec2.RouteTable) bool {
+	for _, route := range routeTable.Routes {
+		if strings.HasPrefix(aws.StringValue(route.GatewayId), "igw-") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cluster) validateAMI(ec2Svc ec2Service) error {
+	if c.AmiId == "" {
+		//The official CoreOS AMI for the release channel will be used
+		return nil
+	}
+
+	imagesOutput, err := ec2Svc.DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(c.AmiId)},
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if strings.HasPrefix(awsErr.Code(), "InvalidAMIID.") {
+				return fmt.Errorf("AMI %s does not exist in region %s", c.AmiId, c.Region)
+			}
+		}
+		return fmt.Errorf("error describing AMI %s: %v", c.AmiId, err)
+	}
+	if len(imagesOutput.Images) == 0 {
+		return fmt.Errorf("AMI %s does not exist in region %s", c.AmiId, c.Region)
+	}
+
+	image := imagesOutput.Images[0]
+	if state := aws.StringValue(image.State); state != ec2.ImageStateAvailable {
+		return fmt.Errorf("AMI %s is not available (state=%s)", c.AmiId, state)
+	}
+
+	if aws.StringValue(image.OwnerId) != coreOSAMIOwnerID {
+		fmt.Fprintf(
+			os.Stderr,
+			"WARNING: AMI %s is not owned by CoreOS (owner=%s). kube-aws is only tested against the official CoreOS AMIs\n",
+			c.AmiId,
+			aws.StringValue(image.OwnerId),
+		)
+	}
+
+	return nil
+}
+
+type kmsService interface {
+	DescribeKey(*kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error)
+}
+
+func (c *Cluster) validateKMSKey(kmsSvc kmsService) error {
+	keyOutput, err := kmsSvc.DescribeKey(&kms.DescribeKeyInput{
+		KeyId: aws.String(c.KMSKeyARN),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NotFoundException" {
+			return fmt.Errorf("KMS key %s does not exist in region %s", c.KMSKeyARN, c.Region)
+		}
+		return fmt.Errorf("error describing KMS key %s: %v", c.KMSKeyARN, err)
+	}
+
+	key := keyOutput.KeyMetadata
+	if state := aws.StringValue(key.KeyState); state != kms.KeyStateEnabled {
+		return fmt.Errorf("KMS key %s is not enabled (state=%s)", c.KMSKeyARN, state)
+	}
+
+	//arn:<partition>:kms:<region>:<account>:key/<key-id>
+	arnParts := strings.Split(aws.StringValue(key.Arn), ":")
+	if len(arnParts) < 4 || arnParts[1] != c.Partition() || arnParts[3] != c.Region {
+		return fmt.Errorf(
+			"KMS key %s is not in the cluster's region %s. KMS keys can only be used in their own region",
+			aws.StringValue(key.Arn),
+			c.Region,
+		)
+	}
+
+	return nil
+}
+
+type iamService interface {
+	GetInstanceProfile(*iam.GetInstanceProfileInput) (*iam.GetInstanceProfileOutput, error)
+}
+
+// validateIAMInstanceProfiles checks that existing instance profiles given
+// instead of the ones kube-aws creates exist and carry a role
+func (c *Cluster) validateIAMInstanceProfiles(iamSvc iamService) error {
+	for _, profile := range []string{c.ControllerIAMInstanceProfile, c.WorkerIAMInstanceProfile} {
+		if profile == "" {
+			continue
+		}
+		profileOutput, err := iamSvc.GetInstanceProfile(&iam.GetInstanceProfileInput{
+			InstanceProfileName: aws.String(config.IAMInstanceProfileName(profile)),
+		})
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoSuchEntity" {
+				return fmt.Errorf("IAM instance profile %s does not exist", profile)
+			}
+			return fmt.Errorf("error getting IAM instance profile %s: %v", profile, err)
+		}
+		if len(profileOutput.InstanceProfile.Roles) == 0 {
+			return fmt.Errorf("IAM instance profile %s has no role, so its instances would have no AWS permissions", profile)
+		}
+	}
+	return nil
+}
+
+// Route 53 is a global service with a single endpoint per partition. The SDK
+// only knows the one of the aws partition, so the others are set explicitly.
+var route53PartitionEndpoints = map[string]struct {
+	endpoint, signingRegion string
+}{
+	"aws-us-gov": {"https://route53.us-gov.amazonaws.com", "us-gov-west-1"},
+	"aws-cn":     {"https://route53.amazonaws.com.cn", "cn-northwest-1"},
+}
+
+func (c *Cluster) route53Config() *aws.Config {
+	awsConfig := aws.NewConfig()
+	if partitionEndpoint, ok := route53PartitionEndpoints[c.Partition()]; ok {
+		awsConfig = awsConfig.
+			WithEndpoint(partitionEndpoint.endpoint).
+			WithRegion(partitionEndpoint.signingRegion)
+	}
+	return awsConfig
+}
+
 type r53Service interface {
 	ListHostedZonesByName(*route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error)
 	ListResourceRecordSets(*route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error)
+	GetHostedZone(*route53.GetHostedZoneInput) (*route53.GetHostedZoneOutput, error)
+}
+
+// A private hosted zone only resolves inside the VPCs it is associated with
+func validatePrivateZoneVPC(r53 r53Service, zone *route53.HostedZone, vpcID, region string) error {
+	var zoneResp *route53.GetHostedZoneOutput
+	err := withRetry(func() (err error) {
+		zoneResp, err = r53.GetHostedZone(&route53.GetHostedZoneInput{
+			Id: zone.Id,
+		})
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("Error getting HostedZone %s: %v", aws.StringValue(zone.Name), err)
+	}
+
+	for _, vpc := range zoneResp.VPCs {
+		if aws.StringValue(vpc.VPCId) == vpcID && aws.StringValue(vpc.VPCRegion) == region {
+			return nil
+		}
+	}
+
+	return fmt.Errorf(
+		"Private HostedZone %s is not associated with vpc %s in %s. Associate it with the VPC so the cluster can resolve its records",
+		aws.StringValue(zone.Name),
+		vpcID,
+		region,
+	)
 }
 
 func (c *Cluster) validateDNSConfig(r53 r53Service) error {
@@ -316,49 +1917,161 @@ func (c *Cluster) validateDNSConfig(r53 r53Service) error {
 		return nil
 	}
 
-	zonesResp, err := r53.ListHostedZonesByName(&route53.ListHostedZonesByNameInput{
-		DNSName: aws.String(c.HostedZone),
+	var zonesResp *route53.ListHostedZonesByNameOutput
+	err := withRetry(func() (err error) {
+		zonesResp, err = r53.ListHostedZonesByName(&route53.ListHostedZonesByNameInput{
+			DNSName: aws.String(c.HostedZone),
+		})
+		return
 	})
 	if err != nil {
 		return fmt.Errorf("Error validating HostedZone: %s", err)
 	}
 
-	zones := zonesResp.HostedZones
-	if len(zones) == 0 || (*zones[0].Name != c.HostedZone) {
+	//Public and private zones may share a name, so pick the one of the configured
+	//kind and id, which the stack creates the record in
+	var zone *route53.HostedZone
+	for _, z := range zonesResp.HostedZones {
+		if aws.StringValue(z.Name) != c.HostedZone {
+			break
+		}
+		private := z.Config != nil && aws.BoolValue(z.Config.PrivateZone)
+		id := strings.TrimPrefix(aws.StringValue(z.Id), "/hostedzone/")
+		if private == c.HostedZonePrivate && (c.HostedZoneID == "" || id == c.HostedZoneID) {
+			zone = z
+			break
+		}
+	}
+	if zone == nil {
+		zoneKind := "Public"
+		if c.HostedZonePrivate {
+			zoneKind = "Private"
+		}
+		if c.HostedZoneID != "" {
+			return fmt.Errorf(
+				"%s HostedZone %s with id %s does not exist.  You'll need to create it manually",
+				zoneKind,
+				c.HostedZone,
+				c.HostedZoneID,
+			)
+		}
 		return fmt.Errorf(
-			"HostedZone %s does not exist.  You'll need to create it manually",
+			"%s HostedZone %s does not exist.  You'll need to create it manually",
+			zoneKind,
 			c.HostedZone,
 		)
 	}
 
-	recordSetsResp, err := r53.ListResourceRecordSets(
-		&route53.ListResourceRecordSetsInput{
-			HostedZoneId: zones[0].Id,
-		},
-	)
+	if c.HostedZonePrivate {
+		if err := validatePrivateZoneVPC(r53, zone, c.VPCID, c.Region); err != nil {
+			return err
+		}
+	}
+
+	listInput := &route53.ListResourceRecordSetsInput{
+		HostedZoneId: zone.Id,
+	}
+	for {
+		var recordSetsResp *route53.ListResourceRecordSetsOutput
+		err := withRetry(func() (err error) {
+			recordSetsResp, err = r53.ListResourceRecordSets(listInput)
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("Error listing RecordSets of HostedZone %s: %v", c.HostedZone, err)
+		}
 
-	if len(recordSetsResp.ResourceRecordSets) > 0 {
 		for _, recordSet := range recordSetsResp.ResourceRecordSets {
-			if *recordSet.Name == config.WithTrailingDot(c.ExternalDNSName) {
+			if conflict := recordSetConflict(recordSet, c.ExternalDNSName); conflict != "" {
 				return fmt.Errorf(
-					"RecordSet for \"%s\" already exists in Hosted Zone \"%s.\"",
-					c.ExternalDNSName,
+					"%s RecordSet \"%s\" in Hosted Zone \"%s\" conflicts with externalDNSName \"%s\": %s",
+					aws.StringValue(recordSet.Type),
+					unescapeRecordName(aws.StringValue(recordSet.Name)),
 					c.HostedZone,
+					c.ExternalDNSName,
+					conflict,
 				)
 			}
 		}
+
+		if !aws.BoolValue(recordSetsResp.IsTruncated) {
+			return nil
+		}
+		listInput.StartRecordName = recordSetsResp.NextRecordName
+		listInput.StartRecordType = recordSetsResp.NextRecordType
+		listInput.StartRecordIdentifier = recordSetsResp.NextRecordIdentifier
 	}
+}
 
-	return nil
+// Route53 returns '*' in record names escaped in octal
+func unescapeRecordName(name string) string {
+	return strings.Replace(name, "\\052", "*", -1)
+}
+
+// recordSetConflict describes how recordSet collides with dnsName, or returns
+// "" if it doesn't
+func recordSetConflict(recordSet *route53.ResourceRecordSet, dnsName string) string {
+	name := unescapeRecordName(aws.StringValue(recordSet.Name))
+	dnsName = config.WithTrailingDot(dnsName)
+
+	if name == dnsName {
+		return "a record for this name already exists"
+	}
+
+	//A wildcard covers every name below its parent domain
+	if strings.HasPrefix(name, "*.") && strings.HasSuffix(dnsName, name[1:]) {
+		return "the wildcard record already resolves this name"
+	}
+
+	return ""
 }
 
 func stackEventErrMsgs(events []*cloudformation.StackEvent) []string {
+	return failedStackEventMsgs(
+		events,
+		cloudformation.ResourceStatusCreateFailed,
+		cloudformation.ResourceStatusUpdateFailed,
+	)
+}
+
+// pendingStackResourceMsgs describes the resources whose latest event, events
+// being in reverse chronological order, is that their creation is in progress
+func pendingStackResourceMsgs(events []*cloudformation.StackEvent) []string {
+	var msgs []string
+	seen := map[string]bool{}
+	for _, event := range events {
+		logicalID := aws.StringValue(event.LogicalResourceId)
+		if seen[logicalID] {
+			continue
+		}
+		seen[logicalID] = true
+		if aws.StringValue(event.ResourceStatus) == cloudformation.ResourceStatusCreateInProgress {
+			msgs = append(msgs, strings.TrimSpace(strings.Join([]string{
+				aws.StringValue(event.ResourceStatus),
+				aws.StringValue(event.ResourceType),
+				logicalID,
+			}, " ")))
+		}
+	}
+	return msgs
+}
+
+func stackDeleteEventErrMsgs(events []*cloudformation.StackEvent) []string {
+	return failedStackEventMsgs(events, cloudformation.ResourceStatusDeleteFailed)
+}
+
+func failedStackEventMsgs(events []*cloudformation.StackEvent, failedStatuses ...string) []string {
 	var errMsgs []string
 
 	for _, event := range events {
-		if aws.StringValue(event.ResourceStatus) == cloudformation.ResourceStatusCreateFailed {
+		for _, failedStatus := range failedStatuses {
+			if aws.StringValue(event.ResourceStatus) != failedStatus {
+				continue
+			}
 			// Only show actual failures, not cancelled dependent resources.
-			if aws.StringValue(event.ResourceStatusReason) != "Resource creation cancelled" {
+			switch aws.StringValue(event.ResourceStatusReason) {
+			case "Resource creation cancelled", "Resource update cancelled":
+			default:
 				errMsgs = append(errMsgs,
 					strings.TrimSpace(
 						strings.Join([]string{
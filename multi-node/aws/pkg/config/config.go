@@ -5,11 +5,20 @@ package config
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"unicode/utf8"
@@ -29,33 +38,65 @@ const (
 
 func newDefaultCluster() *Cluster {
 	return &Cluster{
-		ClusterName:              "kubernetes",
-		ReleaseChannel:           "alpha",
-		VPCCIDR:                  "10.0.0.0/16",
-		ControllerIP:             "10.0.0.50",
-		PodCIDR:                  "10.2.0.0/16",
+		ClusterName:          "kubernetes",
+		ReleaseChannel:       "alpha",
+		RebootStrategy:       "off",
+		VPCCIDR:              "10.0.0.0/16",
+		ControllerIP:         "10.0.0.50",
+		ControllerCount:      1,
+		EtcdCount:            1,
+		EtcdInstanceType:     "m3.medium",
+		EtcdRootVolumeSize:   30,
+		EtcdDataVolumeSize:   30,
+		NATInstanceType:      "t2.micro",
+		APIELBIdleTimeout:    1800,
+		StackCreationTimeout: 60,
+		PodCIDR:              "10.2.0.0/16",
+		NetworkPlugin:        networkPluginFlannel,
+		FlannelBackend:       flannelBackendVXLAN,
+		KubeProxyMode:        kubeProxyModeIPTables,
+		InstanceTenancy:      instanceTenancyDefault,
+		AdmissionControl:     []string{"NamespaceLifecycle", "LimitRanger", "SecurityContextDeny", "ServiceAccount", "ResourceQuota"},
+		SSHAccessCIDRs:       []string{"0.0.0.0/0"},
+		APIAccessCIDRs:       []string{"0.0.0.0/0"},
+		AuditLog: AuditLog{
+			Path:       "/var/log/kube-apiserver/audit.log",
+			MaxAge:     30,
+			MaxBackups: 10,
+			MaxSize:    100,
+		},
 		ServiceCIDR:              "10.3.0.0/24",
 		DNSServiceIP:             "10.3.0.10",
+		DNSDomain:                "cluster.local",
 		K8sVer:                   "v1.2.4_coreos.1",
 		HyperkubeImageRepo:       "quay.io/coreos/hyperkube",
 		ControllerInstanceType:   "m3.medium",
 		ControllerRootVolumeSize: 30,
+		ControllerRootVolumeType: "standard",
 		WorkerCount:              1,
 		WorkerInstanceType:       "m3.medium",
 		WorkerRootVolumeSize:     30,
+		WorkerRootVolumeType:     "standard",
 		CreateRecordSet:          false,
+		ManageRecordSet:          true,
 		RecordSetTTL:             300,
 		Subnets:                  []Subnet{},
 	}
 }
 
 func ClusterFromFile(filename string) (*Cluster, error) {
+	return ClusterFromFileWithOverrides(filename, nil)
+}
+
+// ClusterFromFileWithOverrides is ClusterFromFile with overrides, keyed by
+// cluster.yaml key, taking precedence over the file
+func ClusterFromFileWithOverrides(filename string, overrides map[string]string) (*Cluster, error) {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	c, err := ClusterFromBytes(data)
+	c, err := ClusterFromBytesWithOverrides(data, overrides)
 	if err != nil {
 		return nil, fmt.Errorf("file %s: %v", filename, err)
 	}
@@ -65,20 +106,76 @@ func ClusterFromFile(filename string) (*Cluster, error) {
 
 // ClusterFromBytes Necessary for unit tests, which store configs as hardcoded strings
 func ClusterFromBytes(data []byte) (*Cluster, error) {
+	return ClusterFromBytesWithOverrides(data, nil)
+}
+
+// ClusterFromBytesWithOverrides is ClusterFromBytes with overrides applied
+// after parsing, so they are defaulted and validated like the rest of the config
+func ClusterFromBytesWithOverrides(data []byte, overrides map[string]string) (*Cluster, error) {
 	c := newDefaultCluster()
+	// The access CIDRs are only warned about when set, not for the defaults
+	defaults := *c
+	c.SSHAccessCIDRs, c.APIAccessCIDRs = nil, nil
 	if err := yaml.Unmarshal(data, c); err != nil {
 		return nil, fmt.Errorf("failed to parse cluster: %v", err)
 	}
+	if err := c.applyOverrides(overrides); err != nil {
+		return nil, err
+	}
+	if c.sshAccessCIDRsSet = c.SSHAccessCIDRs != nil; !c.sshAccessCIDRsSet {
+		c.SSHAccessCIDRs = defaults.SSHAccessCIDRs
+	}
+	if c.apiAccessCIDRsSet = c.APIAccessCIDRs != nil; !c.apiAccessCIDRsSet {
+		c.APIAccessCIDRs = defaults.APIAccessCIDRs
+	}
+
+	// Unknown keys are most likely misspelled settings, whose defaults would
+	// otherwise be used without notice
+	unknown, err := unknownKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cluster: %v", err)
+	}
+	if len(unknown) > 0 {
+		if !c.AllowUnknownKeys {
+			return nil, fmt.Errorf(
+				"unknown keys, which may be misspelled: %s. set allowUnknownKeys to ignore keys this version of kube-aws doesn't know",
+				strings.Join(unknown, ", "),
+			)
+		}
+		c.unknownKeys = unknown
+	}
 
 	// HostedZone needs to end with a '.', amazon will not append it for you.
 	// as it will with RecordSets
 	c.HostedZone = WithTrailingDot(c.HostedZone)
+	// Route53 returns zone IDs prefixed with /hostedzone/, the console without
+	c.HostedZoneID = strings.TrimPrefix(c.HostedZoneID, hostedZoneIDPrefix)
+
+	// externalDNSName is kept without the trailing dot, which doesn't belong in
+	// the certificate SAN or API URLs. Compare it with WithTrailingDot.
+	c.ExternalDNSName = strings.TrimSuffix(c.ExternalDNSName, ".")
+
+	// The log group is named after the stack unless logGroupName is set
+	if c.CloudWatchLogging.LogGroupName == "" {
+		c.CloudWatchLogging.LogGroupName = "/kube-aws/" + c.CloudFormationStackName()
+	}
 
 	// If the user specified no subnets, we assume that a single AZ configuration with the default instanceCIDR is demanded
-	if len(c.Subnets) == 0 && c.InstanceCIDR == "" {
+	if len(c.Subnets) == 0 && len(c.SubnetIDs) == 0 && c.InstanceCIDR == "" {
 		c.InstanceCIDR = "10.0.0.0/24"
 	}
 
+	// Settings a worker pool leaves unset are taken from the worker* settings,
+	// and workerCount becomes the number of workers across the pools
+	if len(c.WorkerPools) > 0 {
+		workerCount := 0
+		for i := range c.WorkerPools {
+			c.WorkerPools[i].setDefaults(c)
+			workerCount += c.WorkerPools[i].Count
+		}
+		c.WorkerCount = workerCount
+	}
+
 	if err := c.valid(); err != nil {
 		return nil, fmt.Errorf("invalid cluster: %v", err)
 	}
@@ -89,6 +186,7 @@ func ClusterFromBytes(data []byte) (*Cluster, error) {
 			{
 				AvailabilityZone: c.AvailabilityZone,
 				InstanceCIDR:     c.InstanceCIDR,
+				PublicCIDR:       c.PublicCIDR,
 			},
 		}
 	}
@@ -96,449 +194,2964 @@ func ClusterFromBytes(data []byte) (*Cluster, error) {
 }
 
 type Cluster struct {
-	ClusterName              string            `yaml:"clusterName"`
-	ExternalDNSName          string            `yaml:"externalDNSName"`
-	KeyName                  string            `yaml:"keyName"`
-	Region                   string            `yaml:"region"`
-	AvailabilityZone         string            `yaml:"availabilityZone"`
-	ReleaseChannel           string            `yaml:"releaseChannel"`
-	ControllerInstanceType   string            `yaml:"controllerInstanceType"`
-	ControllerRootVolumeSize int               `yaml:"controllerRootVolumeSize"`
-	WorkerCount              int               `yaml:"workerCount"`
-	WorkerInstanceType       string            `yaml:"workerInstanceType"`
-	WorkerRootVolumeSize     int               `yaml:"workerRootVolumeSize"`
-	WorkerSpotPrice          string            `yaml:"workerSpotPrice"`
-	VPCID                    string            `yaml:"vpcId"`
-	RouteTableID             string            `yaml:"routeTableId"`
-	VPCCIDR                  string            `yaml:"vpcCIDR"`
-	InstanceCIDR             string            `yaml:"instanceCIDR"`
-	ControllerIP             string            `yaml:"controllerIP"`
-	PodCIDR                  string            `yaml:"podCIDR"`
-	ServiceCIDR              string            `yaml:"serviceCIDR"`
-	DNSServiceIP             string            `yaml:"dnsServiceIP"`
-	K8sVer                   string            `yaml:"kubernetesVersion"`
-	HyperkubeImageRepo       string            `yaml:"hyperkubeImageRepo"`
-	KMSKeyARN                string            `yaml:"kmsKeyArn"`
-	CreateRecordSet          bool              `yaml:"createRecordSet"`
-	RecordSetTTL             int               `yaml:"recordSetTTL"`
-	HostedZone               string            `yaml:"hostedZone"`
-	StackTags                map[string]string `yaml:"stackTags"`
-	UseCalico                bool              `yaml:"useCalico"`
-	Subnets                  []Subnet          `yaml:"subnets"`
+	ClusterName                   string               `yaml:"clusterName"`
+	StackName                     string               `yaml:"stackName"`
+	ExternalDNSName               string               `yaml:"externalDNSName"`
+	APIEndpointInternal           bool                 `yaml:"apiEndpointInternal"`
+	APIELBIdleTimeout             int                  `yaml:"apiELBIdleTimeout"`
+	APIELBACMCertARN              string               `yaml:"apiELBACMCertArn"`
+	KeyName                       string               `yaml:"keyName"`
+	SSHPublicKeyPath              string               `yaml:"sshPublicKeyPath"`
+	Region                        string               `yaml:"region"`
+	AvailabilityZone              string               `yaml:"availabilityZone"`
+	ReleaseChannel                string               `yaml:"releaseChannel"`
+	CoreOSChannel                 string               `yaml:"coreosChannel"`
+	RebootStrategy                string               `yaml:"rebootStrategy"`
+	AmiId                         string               `yaml:"amiId"`
+	ControllerInstanceType        string               `yaml:"controllerInstanceType"`
+	ControllerRootVolumeSize      int                  `yaml:"controllerRootVolumeSize"`
+	ControllerRootVolumeType      string               `yaml:"controllerRootVolumeType"`
+	ControllerRootVolumeIOPS      int                  `yaml:"controllerRootVolumeIOPS"`
+	WorkerCount                   int                  `yaml:"workerCount"`
+	WorkerInstanceType            string               `yaml:"workerInstanceType"`
+	WorkerRootVolumeSize          int                  `yaml:"workerRootVolumeSize"`
+	WorkerRootVolumeType          string               `yaml:"workerRootVolumeType"`
+	WorkerRootVolumeIOPS          int                  `yaml:"workerRootVolumeIOPS"`
+	WorkerSpotPrice               string               `yaml:"workerSpotPrice"`
+	WorkerAutoScaling             *WorkerAutoScaling   `yaml:"workerAutoScaling"`
+	WorkerClusterAutoscaler       *ClusterAutoscaler   `yaml:"workerClusterAutoscaler"`
+	UseLaunchTemplate             bool                 `yaml:"useLaunchTemplate"`
+	WorkerInstanceTypes           []string             `yaml:"workerInstanceTypes"`
+	WorkerOnDemandBaseCapacity    int                  `yaml:"workerOnDemandBaseCapacity"`
+	WorkerOnDemandPercentage      *int                 `yaml:"workerOnDemandPercentageAboveBaseCapacity"`
+	ControllerSpotPrice           string               `yaml:"controllerSpotPrice"`
+	VPCID                         string               `yaml:"vpcId"`
+	InstanceTenancy               string               `yaml:"instanceTenancy"`
+	ControllerSecurityGroupIds    []string             `yaml:"controllerSecurityGroupIds"`
+	WorkerSecurityGroupIds        []string             `yaml:"workerSecurityGroupIds"`
+	APIELBSubnetIds               []string             `yaml:"apiELBSubnetIds"`
+	ExtraWorkerSecurityGroupRules []SecurityGroupRule  `yaml:"extraWorkerSecurityGroupRules"`
+	SSHAccessCIDRs                []string             `yaml:"sshAccessCIDRs"`
+	APIAccessCIDRs                []string             `yaml:"apiAccessCIDRs"`
+	APIServerAdditionalSANs       []string             `yaml:"apiServerAdditionalSANs"`
+	WorkerNodeLabels              map[string]string    `yaml:"workerNodeLabels"`
+	WorkerNodeTaints              []Taint              `yaml:"workerNodeTaints"`
+	WorkerPools                   []WorkerPool         `yaml:"workerPools"`
+	MaxPods                       int                  `yaml:"maxPods"`
+	KubeReserved                  map[string]string    `yaml:"kubeReserved"`
+	SystemReserved                map[string]string    `yaml:"systemReserved"`
+	RouteTableID                  string               `yaml:"routeTableId"`
+	SubnetIDs                     []string             `yaml:"subnetIds"`
+	VPCCIDR                       string               `yaml:"vpcCIDR"`
+	VPCDomainName                 string               `yaml:"vpcDomainName"`
+	VPCPeering                    *VPCPeering          `yaml:"vpcPeering"`
+	InstanceCIDR                  string               `yaml:"instanceCIDR"`
+	PublicCIDR                    string               `yaml:"publicCIDR"`
+	NATMode                       string               `yaml:"natMode"`
+	NATInstanceType               string               `yaml:"natInstanceType"`
+	ControllerIP                  string               `yaml:"controllerIP"`
+	ControllerCount               int                  `yaml:"controllerCount"`
+	EtcdCount                     int                  `yaml:"etcdCount"`
+	EtcdIP                        string               `yaml:"etcdIP"`
+	EtcdInstanceType              string               `yaml:"etcdInstanceType"`
+	EtcdRootVolumeSize            int                  `yaml:"etcdRootVolumeSize"`
+	EtcdDataVolumeSize            int                  `yaml:"etcdDataVolumeSize"`
+	PodCIDR                       string               `yaml:"podCIDR"`
+	ServiceCIDR                   string               `yaml:"serviceCIDR"`
+	DNSServiceIP                  string               `yaml:"dnsServiceIP"`
+	DNSDomain                     string               `yaml:"dnsDomain"`
+	K8sVer                        string               `yaml:"kubernetesVersion"`
+	HyperkubeImageRepo            string               `yaml:"hyperkubeImageRepo"`
+	ImageRepository               string               `yaml:"imageRepository"`
+	DockerConfigJSON              string               `yaml:"dockerConfigJSON"`
+	KMSKeyARN                     string               `yaml:"kmsKeyArn"`
+	TLSCertDurationDays           int                  `yaml:"tlsCertDurationDays"`
+	CACertFile                    string               `yaml:"caCertFile"`
+	CAKeyFile                     string               `yaml:"caKeyFile"`
+	CreateRecordSet               bool                 `yaml:"createRecordSet"`
+	ManageRecordSet               bool                 `yaml:"manageRecordSet"`
+	RecordSetTTL                  int                  `yaml:"recordSetTTL"`
+	RecordSetAlias                bool                 `yaml:"recordSetAlias"`
+	HostedZone                    string               `yaml:"hostedZone"`
+	HostedZonePrivate             bool                 `yaml:"hostedZonePrivate"`
+	HostedZoneID                  string               `yaml:"hostedZoneId"`
+	StackTags                     map[string]string    `yaml:"stackTags"`
+	RollbackOnFailure             bool                 `yaml:"rollbackOnFailure"`
+	StackCreationTimeout          int                  `yaml:"stackCreationTimeout"`
+	S3Bucket                      string               `yaml:"s3Bucket"`
+	UseCalico                     bool                 `yaml:"useCalico"`
+	RBACEnabled                   bool                 `yaml:"rbacEnabled"`
+	AdmissionControl              []string             `yaml:"admissionControl"`
+	AuditLog                      AuditLog             `yaml:"auditLog"`
+	CloudWatchLogging             CloudWatchLogging    `yaml:"cloudWatchLogging"`
+	MetadataOptions               MetadataOptions      `yaml:"metadataOptions"`
+	APIServerFlags                []string             `yaml:"apiServerFlags"`
+	ControllerManagerFlags        []string             `yaml:"controllerManagerFlags"`
+	SchedulerFlags                []string             `yaml:"schedulerFlags"`
+	WorkerCustomFiles             []CustomFile         `yaml:"workerCustomFiles"`
+	WorkerSystemdUnits            []CustomSystemdUnit  `yaml:"workerSystemdUnits"`
+	ControllerIAMInstanceProfile  string               `yaml:"controllerIAMInstanceProfile"`
+	WorkerIAMInstanceProfile      string               `yaml:"workerIAMInstanceProfile"`
+	ControllerIAMPolicyStatements []IAMPolicyStatement `yaml:"controllerIAMPolicyStatements"`
+	WorkerIAMPolicyStatements     []IAMPolicyStatement `yaml:"workerIAMPolicyStatements"`
+	NetworkPlugin                 string               `yaml:"networkPlugin"`
+	FlannelBackend                string               `yaml:"flannelBackend"`
+	KubeProxyMode                 string               `yaml:"kubeProxyMode"`
+	HTTPProxy                     string               `yaml:"httpProxy"`
+	HTTPSProxy                    string               `yaml:"httpsProxy"`
+	NoProxy                       string               `yaml:"noProxy"`
+	Subnets                       []Subnet             `yaml:"subnets"`
+	AllowUnknownKeys              bool                 `yaml:"allowUnknownKeys"`
+
+	// Recorded while loading for Warnings
+	unknownKeys       []string
+	sshAccessCIDRsSet bool
+	apiAccessCIDRsSet bool
 }
 
 type Subnet struct {
 	AvailabilityZone string `yaml:"availabilityZone"`
 	InstanceCIDR     string `yaml:"instanceCIDR"`
+	// PublicCIDR is the public subnet holding the NAT and the API load
+	// balancer in this availability zone when natMode is set
+	PublicCIDR string `yaml:"publicCIDR"`
 }
 
-const (
-	vpcLogicalName = "VPC"
-)
+// Taint is a node taint applied when the kubelet registers the node
+type Taint struct {
+	Key    string `yaml:"key"`
+	Value  string `yaml:"value"`
+	Effect string `yaml:"effect"`
+}
 
-var supportedReleaseChannels = map[string]bool{
-	"alpha":  true,
-	"beta":   true,
-	"stable": false,
+var taintEffects = map[string]bool{
+	"NoSchedule":       true,
+	"PreferNoSchedule": true,
+	"NoExecute":        true,
 }
 
-func (c Cluster) Config() (*Config, error) {
-	config := Config{Cluster: c}
-	config.ETCDEndpoints = fmt.Sprintf("http://%s:2379", c.ControllerIP)
-	config.APIServers = fmt.Sprintf("http://%s:8080", c.ControllerIP)
-	config.SecureAPIServers = fmt.Sprintf("https://%s:443", c.ControllerIP)
-	config.APIServerEndpoint = fmt.Sprintf("https://%s", c.ExternalDNSName)
-	if config.UseCalico {
-		config.K8sNetworkPlugin = "cni"
-	}
+func (t Taint) String() string {
+	return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+}
 
-	var err error
-	if config.AMI, err = getAMI(config.Region, config.ReleaseChannel); err != nil {
-		return nil, fmt.Errorf("failed getting AMI for config: %v", err)
+func (t Taint) valid() error {
+	if t.Key == "" {
+		return errors.New("key must be set")
+	}
+	if err := validateLabel(t.Key, t.Value); err != nil {
+		return err
+	}
+	if !taintEffects[t.Effect] {
+		return fmt.Errorf("effect must be one of NoSchedule, PreferNoSchedule or NoExecute, got %q", t.Effect)
 	}
+	return nil
+}
 
-	//Set logical name constants
-	config.VPCLogicalName = vpcLogicalName
+// WorkerPool is a group of workers with its own size, instance type, root
+// volume and node labels and taints, run by an autoscaling group of its own
+type WorkerPool struct {
+	Name           string            `yaml:"name"`
+	Count          int               `yaml:"count"`
+	InstanceType   string            `yaml:"instanceType"`
+	InstanceTypes  []string          `yaml:"instanceTypes"`
+	RootVolumeSize int               `yaml:"rootVolumeSize"`
+	RootVolumeType string            `yaml:"rootVolumeType"`
+	RootVolumeIOPS int               `yaml:"rootVolumeIOPS"`
+	NodeLabels     map[string]string `yaml:"nodeLabels"`
+	NodeTaints     []Taint           `yaml:"nodeTaints"`
+}
 
-	//Set reference strings
+func (p *WorkerPool) setDefaults(c *Cluster) {
+	if p.Count == 0 {
+		p.Count = c.WorkerCount
+	}
+	if p.InstanceType == "" && p.InstanceTypes == nil {
+		if c.WorkerInstanceTypes != nil {
+			p.InstanceTypes = c.WorkerInstanceTypes
+		} else {
+			p.InstanceType = c.WorkerInstanceType
+		}
+	}
+	if p.RootVolumeSize == 0 {
+		p.RootVolumeSize = c.WorkerRootVolumeSize
+	}
+	if p.RootVolumeType == "" {
+		p.RootVolumeType = c.WorkerRootVolumeType
+		if p.RootVolumeIOPS == 0 {
+			p.RootVolumeIOPS = c.WorkerRootVolumeIOPS
+		}
+	}
+	if p.NodeLabels == nil {
+		p.NodeLabels = c.WorkerNodeLabels
+	}
+	if p.NodeTaints == nil {
+		p.NodeTaints = c.WorkerNodeTaints
+	}
+}
 
-	//Assume VPC does not exist, reference by logical name
-	config.VPCRef = fmt.Sprintf(`{ "Ref" : %q }`, config.VPCLogicalName)
-	if config.VPCID != "" {
-		//This means this VPC already exists, and we can reference it directly by ID
-		config.VPCRef = fmt.Sprintf("%q", config.VPCID)
+// LogicalSuffix distinguishes the logical names of the pool's resources in
+// the stack template: its name in CamelCase, "gpu-large" becoming "GpuLarge"
+func (p WorkerPool) LogicalSuffix() string {
+	return strings.Replace(strings.Title(strings.Replace(p.Name, "-", " ", -1)), " ", "", -1)
+}
+
+func (p WorkerPool) valid() error {
+	if p.Count < 1 {
+		return fmt.Errorf("count must be at least 1, got %d", p.Count)
 	}
+	if p.InstanceTypes != nil {
+		if p.InstanceType != "" {
+			return errors.New("instanceType and instanceTypes cannot both be set, list every type in instanceTypes")
+		}
+		if err := validateInstanceTypes("instanceTypes", p.InstanceTypes); err != nil {
+			return err
+		}
+	} else if err := validateInstanceType("instanceType", p.InstanceType); err != nil {
+		return err
+	}
+	if err := validateRootVolume("worker", p.RootVolumeSize, p.RootVolumeType, p.RootVolumeIOPS); err != nil {
+		return err
+	}
+	for k, v := range p.NodeLabels {
+		if err := validateLabel(k, v); err != nil {
+			return fmt.Errorf("invalid nodeLabels: %v", err)
+		}
+	}
+	for i, taint := range p.NodeTaints {
+		if err := taint.valid(); err != nil {
+			return fmt.Errorf("invalid nodeTaints #%d: %v", i, err)
+		}
+	}
+	return nil
+}
 
-	return &config, nil
+// SecurityGroupRule is an additional ingress rule for a security group
+type SecurityGroupRule struct {
+	Protocol string `yaml:"protocol"`
+	FromPort int    `yaml:"fromPort"`
+	ToPort   int    `yaml:"toPort"`
+	CIDR     string `yaml:"cidr"`
 }
 
-type StackTemplateOptions struct {
-	TLSAssetsDir          string
-	ControllerTmplFile    string
-	WorkerTmplFile        string
-	StackTemplateTmplFile string
+// WorkerAutoScaling scales the worker group between MinSize and MaxSize to keep
+// the average CPU utilization of the workers around TargetCPUUtilization percent
+type WorkerAutoScaling struct {
+	MinSize              int `yaml:"minSize"`
+	MaxSize              int `yaml:"maxSize"`
+	TargetCPUUtilization int `yaml:"targetCPUUtilization"`
 }
 
-type stackConfig struct {
-	*Config
-	UserDataWorker        string
-	UserDataController    string
-	ControllerSubnetIndex int
+// ScaleInCPUUtilization is the utilization below which a worker is removed.
+// It is well below the target so that removing a worker doesn't immediately
+// push the utilization of the others back over it.
+func (a WorkerAutoScaling) ScaleInCPUUtilization() int {
+	return a.TargetCPUUtilization / 2
 }
 
-func execute(filename string, data interface{}, compress bool) (string, error) {
-	raw, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return "", err
+func (a WorkerAutoScaling) valid(workerCount int) error {
+	if a.MinSize < 0 {
+		return fmt.Errorf("minSize must be at least 0, got %d", a.MinSize)
 	}
-	tmpl, err := template.New(filename).Parse(string(raw))
-	if err != nil {
-		return "", err
+	if a.MinSize > workerCount || workerCount > a.MaxSize {
+		return fmt.Errorf(
+			"workerCount is the initial size of the worker group and must be between minSize and maxSize, got minSize %d, workerCount %d, maxSize %d",
+			a.MinSize,
+			workerCount,
+			a.MaxSize,
+		)
 	}
-	var buff bytes.Buffer
-	if err := tmpl.Execute(&buff, data); err != nil {
-		return "", err
+	if a.TargetCPUUtilization < 1 || a.TargetCPUUtilization > 100 {
+		return fmt.Errorf("targetCPUUtilization must be a percentage between 1 and 100, got %d", a.TargetCPUUtilization)
+	}
+	return nil
+}
+
+// ClusterAutoscaler lets the Kubernetes cluster-autoscaler discover the
+// worker group and resize it between MinSize and MaxSize
+type ClusterAutoscaler struct {
+	MinSize int `yaml:"minSize"`
+	MaxSize int `yaml:"maxSize"`
+}
+
+func (a ClusterAutoscaler) valid(workerCount int) error {
+	if a.MinSize < 0 {
+		return fmt.Errorf("minSize must be at least 0, got %d", a.MinSize)
 	}
-	if compress {
-		return compressData(buff.Bytes())
+	if a.MinSize >= a.MaxSize {
+		return fmt.Errorf("maxSize must be greater than minSize for the cluster-autoscaler to have room to scale, got minSize %d, maxSize %d", a.MinSize, a.MaxSize)
 	}
-	return buff.String(), nil
+	if a.MinSize > workerCount || workerCount > a.MaxSize {
+		return fmt.Errorf(
+			"workerCount is the initial size of the worker group and must be between minSize and maxSize, got minSize %d, workerCount %d, maxSize %d",
+			a.MinSize,
+			workerCount,
+			a.MaxSize,
+		)
+	}
+	return nil
 }
 
-func (c Cluster) stackConfig(opts StackTemplateOptions, compressUserData bool) (*stackConfig, error) {
-	assets, err := ReadTLSAssets(opts.TLSAssetsDir)
+// VPCPeering peers the VPC kube-aws creates with another VPC of the same
+// account and region, routing PeerCIDR to it from every route table of the
+// stack and the VPC CIDR back from each of PeerRouteTableIDs
+type VPCPeering struct {
+	PeerVPCID         string   `yaml:"peerVpcId"`
+	PeerCIDR          string   `yaml:"peerCIDR"`
+	PeerRouteTableIDs []string `yaml:"peerRouteTableIds"`
+}
+
+func (p VPCPeering) valid(vpcNet *net.IPNet) error {
+	if !vpcIDRegexp.MatchString(p.PeerVPCID) {
+		return fmt.Errorf("%q is not a valid vpc id", p.PeerVPCID)
+	}
+	_, peerNet, err := net.ParseCIDR(p.PeerCIDR)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("invalid peerCIDR: %v", err)
 	}
-	stackConfig := stackConfig{}
-
-	if stackConfig.Config, err = c.Config(); err != nil {
-		return nil, err
+	if cidrOverlap(peerNet, vpcNet) {
+		return fmt.Errorf("peerCIDR (%s) overlaps with vpcCIDR (%s). AWS can't route between peered VPCs with overlapping CIDRs", peerNet, vpcNet)
+	}
+	for _, routeTableID := range p.PeerRouteTableIDs {
+		if !routeTableIDRegexp.MatchString(routeTableID) {
+			return fmt.Errorf("%q is not a valid route table id", routeTableID)
+		}
 	}
+	return nil
+}
 
-	awsConfig := aws.NewConfig().
-		WithRegion(stackConfig.Config.Region).
-		WithCredentialsChainVerboseErrors(true)
+// AuditLog configures the apiserver audit log
+type AuditLog struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// MaxAge is the number of days to keep rotated logs
+	MaxAge     int `yaml:"maxAge"`
+	MaxBackups int `yaml:"maxBackups"`
+	// MaxSize is the size in megabytes at which the log is rotated
+	MaxSize int `yaml:"maxSize"`
+}
 
-	kmsSvc := kms.New(session.New(awsConfig))
+// Dir is the host directory holding the audit log, mounted into the apiserver
+func (a AuditLog) Dir() string {
+	return path.Dir(a.Path)
+}
 
-	compactAssets, err := assets.compact(stackConfig.Config, kmsSvc)
-	if err != nil {
-		return nil, fmt.Errorf("failed to compress TLS assets: %v", err)
-	}
+// CloudWatchLogging ships the journal of every controller, worker and etcd node
+// to a CloudWatch Logs group created with the stack, in a log stream per node
+type CloudWatchLogging struct {
+	Enabled bool `yaml:"enabled"`
+	// RetentionDays is the number of days to keep logs, forever when unset
+	RetentionDays int    `yaml:"retentionDays"`
+	LogGroupName  string `yaml:"logGroupName"`
+}
 
-	stackConfig.Config.TLSConfig = compactAssets
+// The retention periods CloudWatch Logs allows, in days
+var cloudWatchLogsRetentionDays = []int{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
 
-	controllerIPAddr := net.ParseIP(stackConfig.ControllerIP)
-	if controllerIPAddr == nil {
-		return nil, fmt.Errorf("invalid controllerIP: %s", stackConfig.ControllerIP)
+// CloudWatch Logs group names are at most 512 characters
+var logGroupNameRegexp = regexp.MustCompile(`^[-_./#A-Za-z0-9]{1,512}$`)
+
+func (l CloudWatchLogging) valid() error {
+	if !l.Enabled {
+		return nil
 	}
-	controllerSubnetFound := false
-	for i, subnet := range stackConfig.Subnets {
-		_, instanceCIDR, err := net.ParseCIDR(subnet.InstanceCIDR)
-		if err != nil {
-			return nil, fmt.Errorf("invalid instanceCIDR: %v", err)
-		}
-		if instanceCIDR.Contains(controllerIPAddr) {
-			stackConfig.ControllerSubnetIndex = i
-			controllerSubnetFound = true
-		}
+	if !logGroupNameRegexp.MatchString(l.LogGroupName) {
+		return fmt.Errorf("logGroupName must be at most 512 letters, digits and any of -_./#, got %q", l.LogGroupName)
+	}
+	if l.RetentionDays == 0 {
+		return nil
 	}
-	if !controllerSubnetFound {
-		return nil, fmt.Errorf("Fail-fast occurred possibly because of a bug: ControllerSubnetIndex couldn't be determined for subnets (%v) and controllerIP (%v)", stackConfig.Subnets, stackConfig.ControllerIP)
+	for _, days := range cloudWatchLogsRetentionDays {
+		if l.RetentionDays == days {
+			return nil
+		}
 	}
+	return fmt.Errorf("retentionDays must be one of %v, got %d", cloudWatchLogsRetentionDays, l.RetentionDays)
+}
+
+// MetadataOptions configure the instance metadata service of every node. Left
+// unset, the instances keep the EC2 defaults.
+type MetadataOptions struct {
+	// HTTPTokens is "required" to only answer IMDSv2 requests
+	HTTPTokens string `yaml:"httpTokens"`
+	// HTTPPutResponseHopLimit is the number of network hops the IMDSv2 token
+	// response may travel
+	HTTPPutResponseHopLimit int `yaml:"httpPutResponseHopLimit"`
+}
+
+const (
+	metadataHTTPTokensOptional = "optional"
+	metadataHTTPTokensRequired = "required"
+)
+
+// Enabled reports whether any option differs from the EC2 defaults
+func (m MetadataOptions) Enabled() bool {
+	return m.HTTPTokens != "" || m.HTTPPutResponseHopLimit != 0
+}
 
-	if stackConfig.UserDataWorker, err = execute(opts.WorkerTmplFile, stackConfig.Config, compressUserData); err != nil {
-		return nil, fmt.Errorf("failed to render worker cloud config: %v", err)
+func (m MetadataOptions) valid() error {
+	switch m.HTTPTokens {
+	case "", metadataHTTPTokensOptional, metadataHTTPTokensRequired:
+	default:
+		return fmt.Errorf("httpTokens must be %q or %q, got %q", metadataHTTPTokensOptional, metadataHTTPTokensRequired, m.HTTPTokens)
 	}
-	if stackConfig.UserDataController, err = execute(opts.ControllerTmplFile, stackConfig.Config, compressUserData); err != nil {
-		return nil, fmt.Errorf("failed to render controller cloud config: %v", err)
+	if m.HTTPPutResponseHopLimit != 0 && (m.HTTPPutResponseHopLimit < 1 || m.HTTPPutResponseHopLimit > 64) {
+		return fmt.Errorf("httpPutResponseHopLimit must be between 1 and 64, got %d", m.HTTPPutResponseHopLimit)
 	}
+	return nil
+}
 
-	return &stackConfig, nil
+// IAMPolicyStatement is an extra statement for the policy of the IAM roles kube-aws creates
+type IAMPolicyStatement struct {
+	Effect   string   `yaml:"effect" json:"Effect"`
+	Action   []string `yaml:"action" json:"Action"`
+	Resource []string `yaml:"resource" json:"Resource"`
 }
 
-func (c Cluster) ValidateUserData(opts StackTemplateOptions) error {
-	stackConfig, err := c.stackConfig(opts, false)
-	if err != nil {
+// JSON renders the statement for a policy document
+func (s IAMPolicyStatement) JSON() (string, error) {
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+// CustomFile is an extra file written by cloud-config on the nodes
+type CustomFile struct {
+	Path        string   `yaml:"path"`
+	Permissions FileMode `yaml:"permissions"`
+	Content     string   `yaml:"content"`
+}
+
+// FileMode is a file mode written in octal, with or without a leading 0, so
+// permissions: 644 means rw-r--r-- rather than decimal 644
+type FileMode uint
+
+// UnmarshalYAML reads the mode as octal whatever the YAML scalar resolves to
+func (m *FileMode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
 		return err
 	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return fmt.Errorf("permissions must be an octal file mode such as 0644, got %q", s)
+	}
+	*m = FileMode(mode)
+	return nil
+}
 
-	errors := []string{}
+// PermissionsString renders permissions as the octal mode cloud-config expects
+func (f CustomFile) PermissionsString() string {
+	return fmt.Sprintf("%04o", f.Permissions)
+}
 
-	for _, userData := range []struct {
-		Name    string
-		Content string
-	}{
-		{
-			Content: stackConfig.UserDataWorker,
-			Name:    "UserDataWorker",
-		},
-		{
-			Content: stackConfig.UserDataController,
-			Name:    "UserDataController",
-		},
-	} {
-		report, err := validate.Validate([]byte(userData.Content))
+// ContentArray splits content into lines so templates can indent it
+func (f CustomFile) ContentArray() []string {
+	return strings.Split(strings.TrimRight(f.Content, "\n"), "\n")
+}
 
-		if err != nil {
-			errors = append(
-				errors,
-				fmt.Sprintf("cloud-config %s could not be parsed: %v",
-					userData.Name,
-					err,
-				),
-			)
-			continue
-		}
+// CustomSystemdUnit is an extra systemd unit installed by cloud-config on the
+// nodes. Units are started before the kubelet, in the order they are listed.
+type CustomSystemdUnit struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+	Enable  bool   `yaml:"enable"`
+	Content string `yaml:"content"`
+}
 
-		for _, entry := range report.Entries() {
-			errors = append(errors, fmt.Sprintf("%s: %+v", userData.Name, entry))
-		}
-	}
+// ContentArray splits content into lines so templates can indent it
+func (u CustomSystemdUnit) ContentArray() []string {
+	return strings.Split(strings.TrimRight(u.Content, "\n"), "\n")
+}
 
-	if len(errors) > 0 {
-		reportString := strings.Join(errors, "\n")
-		return fmt.Errorf("cloud-config validation errors:\n%s\n", reportString)
-	}
+var systemdUnitCommands = map[string]bool{
+	"":        true,
+	"start":   true,
+	"stop":    true,
+	"restart": true,
+}
 
-	return nil
+// Units kube-aws itself installs on workers, which custom units must not replace
+var workerSystemdUnits = map[string]bool{
+	"docker.service":             true,
+	"flanneld.service":           true,
+	"kubelet.service":            true,
+	"calico-node.service":        true,
+	"decrypt-tls-assets.service": true,
 }
 
-func (c Cluster) RenderStackTemplate(opts StackTemplateOptions) ([]byte, error) {
-	stackConfig, err := c.stackConfig(opts, true)
-	if err != nil {
-		return nil, err
-	}
+const (
+	vpcLogicalName = "VPC"
+)
 
-	rendered, err := execute(opts.StackTemplateTmplFile, stackConfig, false)
-	if err != nil {
-		return nil, err
-	}
+// Worker pool names are lower case words joined by hyphens, so they make
+// readable resource names
+var workerPoolNameRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
 
-	//Use unmarshal function to do syntax validation
-	renderedBytes := []byte(rendered)
-	var jsonHolder map[string]interface{}
-	if err := json.Unmarshal(renderedBytes, &jsonHolder); err != nil {
-		syntaxError, ok := err.(*json.SyntaxError)
-		if ok {
-			contextString := getContextString(renderedBytes, int(syntaxError.Offset), 3)
-			return nil, fmt.Errorf("%v:\njson syntax error (offset=%d), in this region:\n-------\n%s\n-------\n", err, syntaxError.Offset, contextString)
-		}
-		return nil, err
-	}
+// EC2 instance types are of the form <family><generation>.<size>, e.g. m3.medium or c4.2xlarge
+var instanceTypeRegexp = regexp.MustCompile(`^[a-z][a-z0-9-]*\.[a-z0-9]+$`)
 
-	// minify JSON
-	var buff bytes.Buffer
-	if err := json.Compact(&buff, renderedBytes); err != nil {
-		return nil, err
+// Graviton instance families are a1 and those whose attributes, following the
+// generation, include a g, e.g. m6g, c6gn or t4g
+var armInstanceTypeRegexp = regexp.MustCompile(`^(a1|[a-z]+[0-9]+[a-z]*g[a-z]*)\.`)
+
+// validateInstanceType checks instanceType is an EC2 instance type that can
+// boot the x86_64 CoreOS AMI every node is launched from
+func validateInstanceType(field, instanceType string) error {
+	if !instanceTypeRegexp.MatchString(instanceType) {
+		return fmt.Errorf("%s %q is not a valid EC2 instance type", field, instanceType)
 	}
-	return buff.Bytes(), nil
+	if armInstanceTypeRegexp.MatchString(instanceType) {
+		return fmt.Errorf("%s %s is an arm64 instance type, which can't boot the x86_64 CoreOS AMI", field, instanceType)
+	}
+	return nil
 }
 
-func getContextString(buf []byte, offset, lineCount int) string {
-
-	linesSeen := 0
-	var leftLimit int
-	for leftLimit = offset; leftLimit > 0 && linesSeen <= lineCount; leftLimit-- {
-		if buf[leftLimit] == '\n' {
-			linesSeen++
-		}
+// validateInstanceTypes checks a list of instance types for a mixed instances
+// policy
+func validateInstanceTypes(field string, instanceTypes []string) error {
+	if len(instanceTypes) == 0 {
+		return fmt.Errorf("%s must list at least one instance type", field)
 	}
-
-	linesSeen = 0
-	var rightLimit int
-	for rightLimit = offset + 1; rightLimit < len(buf) && linesSeen <= lineCount; rightLimit++ {
-		if buf[rightLimit] == '\n' {
-			linesSeen++
+	seen := map[string]bool{}
+	for _, instanceType := range instanceTypes {
+		if err := validateInstanceType(field, instanceType); err != nil {
+			return err
+		}
+		if seen[instanceType] {
+			return fmt.Errorf("%s lists %s more than once", field, instanceType)
 		}
+		seen[instanceType] = true
 	}
-
-	return string(buf[leftLimit:rightLimit])
+	return nil
 }
 
-type Config struct {
-	Cluster
+var amiIDRegexp = regexp.MustCompile(`^ami-[0-9a-f]+$`)
 
-	ETCDEndpoints     string
-	APIServers        string
-	SecureAPIServers  string
-	APIServerEndpoint string
-	AMI               string
+// Captures the partition and region of a KMS key or alias ARN
+var kmsKeyARNRegexp = regexp.MustCompile(`^arn:(aws[a-z-]*):kms:([a-z0-9-]+):[^:]+:(key|alias)/.+$`)
 
-	// Encoded TLS assets
-	TLSConfig *CompactTLSAssets
+// ACM certificates are given by an ARN of the form
+// arn:aws:acm:<region>:<account>:certificate/<certificate-id>
+var acmCertARNRegexp = regexp.MustCompile(`^arn:(aws[a-z-]*):acm:([a-z0-9-]+):\d{12}:certificate/[0-9a-f-]+$`)
 
-	//Logical names of dynamic resources
-	VPCLogicalName string
+// IAM instance profiles are given by name or by an ARN of the form
+// arn:aws:iam::<account>:instance-profile/<path><name>
+var iamInstanceProfileRegexp = regexp.MustCompile(`^(arn:(aws[a-z-]*):iam::\d{12}:instance-profile/([\w+=,.@-]+/)*)?[\w+=,.@-]{1,128}$`)
 
-	//Reference strings for dynamic resources
-	VPCRef string
+// IAM actions look like <service>:<action>, where the action may contain wildcards
+var iamActionRegexp = regexp.MustCompile(`^(\*|[a-z0-9-]+:[A-Za-z0-9*?]+)$`)
 
-	K8sNetworkPlugin string
-}
+// Admission control plugins are registered under CamelCase names such as ServiceAccount
+var admissionControlPluginRegexp = regexp.MustCompile(`^[A-Z][A-Za-z]*$`)
 
-func (c Cluster) valid() error {
-	if c.ExternalDNSName == "" {
+// Admission control plugins without which a cluster kube-aws creates doesn't work
+var requiredAdmissionControlPlugins = []string{"ServiceAccount"}
+
+var securityGroupIDRegexp = regexp.MustCompile(`^sg-[0-9a-f]+$`)
+
+var subnetIDRegexp = regexp.MustCompile(`^subnet-[0-9a-f]+$`)
+
+var vpcIDRegexp = regexp.MustCompile(`^vpc-[0-9a-f]+$`)
+
+var routeTableIDRegexp = regexp.MustCompile(`^rtb-[0-9a-f]+$`)
+
+const hostedZoneIDPrefix = "/hostedzone/"
+
+var hostedZoneIDRegexp = regexp.MustCompile(`^[A-Z0-9]{1,32}$`)
+
+// Cluster names are the value of the KubernetesCluster tag the AWS cloud
+// provider looks resources up by, and part of tag keys and Name tags. They
+// start with a letter or digit and contain only letters, digits, hyphens,
+// underscores and dots.
+var clusterNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9_.]*$`)
+
+// The cluster-autoscaler tag key k8s.io/cluster-autoscaler/<clusterName> must
+// fit the 128 character limit of tag keys
+const maxClusterNameLength = 128 - len("k8s.io/cluster-autoscaler/")
+
+// CloudFormation stack names start with a letter and contain only letters, digits and hyphens
+var stackNameRegexp = regexp.MustCompile(`^[A-Za-z][-A-Za-z0-9]{0,127}$`)
+
+// Domain names are dot separated labels of letters, digits and inner hyphens
+var domainNameRegexp = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9]{0,61}[A-Za-z0-9])?(\.[A-Za-z0-9]([-A-Za-z0-9]{0,61}[A-Za-z0-9])?)*$`)
+
+const maxDomainNameLength = 253
+
+// Registries are a host name or IP address with an optional port, optionally
+// followed by a path under which the images are mirrored
+var imageRepositoryRegexp = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9]*[A-Za-z0-9])?(\.[A-Za-z0-9]([-A-Za-z0-9]*[A-Za-z0-9])?)*(:[0-9]+)?(/[a-z0-9]+([._-][a-z0-9]+)*)*$`)
+
+// Image repositories are an optional registry followed by slash separated
+// lowercase path components, without a tag
+var imageRepoRegexp = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9.]*(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*$`)
+
+// Hyperkube image tags look like v1.2.4_coreos.1, v1.2.4_coreos.cni.1 or v1.3.0-beta.1_coreos.0
+var k8sVerRegexp = regexp.MustCompile(`^v(\d+)\.(\d+)\.\d+(-[0-9A-Za-z.]+)?(_coreos(\.cni)?\.\d+)?$`)
+
+// k8sVerAtLeast reports whether a valid kubernetesVersion is major.minor or newer
+func k8sVerAtLeast(k8sVer string, major, minor int) bool {
+	m := k8sVerRegexp.FindStringSubmatch(k8sVer)
+	if m == nil {
+		return false
+	}
+	verMajor, _ := strconv.Atoi(m[1])
+	verMinor, _ := strconv.Atoi(m[2])
+	return verMajor > major || (verMajor == major && verMinor >= minor)
+}
+
+var supportedReleaseChannels = map[string]bool{
+	"alpha":  true,
+	"beta":   true,
+	"stable": false,
+}
+
+// Channels update-engine can follow once a node is running
+var supportedCoreOSChannels = map[string]bool{
+	"alpha":  true,
+	"beta":   true,
+	"stable": true,
+}
+
+// Reboot strategies understood by locksmithd
+var supportedRebootStrategies = map[string]bool{
+	"reboot":    true,
+	"etcd-lock": true,
+	"off":       true,
+}
+
+// Upper bound on recordSetTTL, a week. Longer TTLs would leave clients
+// resolving a replaced load balancer for too long.
+const maxRecordSetTTL = 604800
+
+// AWS allows at most 50 tags per resource
+const maxResourceTags = 50
+
+// Upper bound on tlsCertDurationDays, roughly ten years
+const maxTLSCertDurationDays = 3650
+
+// Durations under a year get a warning at generation time so operators plan rotation
+const tlsCertDurationWarnDays = 365
+
+// InstanceTags returns the tags applied to the cluster's EC2 instances: the
+// stackTags plus KubernetesCluster, which the AWS cloud provider relies on.
+// Name is left out, as kube-aws names each instance by its role.
+func (c Cluster) InstanceTags() map[string]string {
+	tags := map[string]string{}
+	for k, v := range c.StackTags {
+		if k != "Name" {
+			tags[k] = v
+		}
+	}
+	tags["KubernetesCluster"] = c.ClusterName
+	return tags
+}
+
+// JSONString quotes s as a JSON string, so user supplied values such as tags
+// can't break the stack template
+func (c Cluster) JSONString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// TagSpecifications renders the TagSpecifications of a launch template, which
+// tag the network interfaces and volumes of the instances launched from it
+// with InstanceTags and name as their Name
+func (c Cluster) TagSpecifications(name string) string {
+	type tag struct {
+		Key   string
+		Value string
+	}
+	instanceTags := c.InstanceTags()
+	keys := make([]string, 0, len(instanceTags))
+	for key := range instanceTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tags := make([]tag, 0, len(keys)+1)
+	for _, key := range keys {
+		tags = append(tags, tag{key, instanceTags[key]})
+	}
+	tags = append(tags, tag{"Name", name})
+
+	specifications := []struct {
+		ResourceType string
+		Tags         []tag
+	}{
+		{"network-interface", tags},
+		{"volume", tags},
+	}
+	data, _ := json.Marshal(specifications)
+	return string(data)
+}
+
+// Image moves image to imageRepository by replacing its registry, the
+// implicit Docker Hub one included. Without imageRepository image is
+// returned unchanged.
+func (c Cluster) Image(image string) string {
+	if c.ImageRepository == "" {
+		return image
+	}
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		image = parts[1]
+	}
+	return c.ImageRepository + "/" + image
+}
+
+// RktImage is Image for the images rkt runs. rkt can only discover the
+// signed ACIs of the public registries, so a mirrored image is fetched from
+// imageRepository as an unsigned Docker image.
+func (c Cluster) RktImage(image string) string {
+	if c.ImageRepository == "" {
+		return image
+	}
+	return "docker://" + c.Image(image)
+}
+
+// WorkerNodeLabelsString renders workerNodeLabels for the kubelet --node-labels flag
+func (c Cluster) WorkerNodeLabelsString() string {
+	return keyValueFlag(c.WorkerNodeLabels)
+}
+
+// KubeReservedString renders kubeReserved for the kubelet --kube-reserved flag
+func (c Cluster) KubeReservedString() string {
+	return keyValueFlag(c.KubeReserved)
+}
+
+// SystemReservedString renders systemReserved for the kubelet --system-reserved flag
+func (c Cluster) SystemReservedString() string {
+	return keyValueFlag(c.SystemReserved)
+}
+
+// keyValueFlag renders m as comma separated key=value pairs, sorted by key so
+// the rendered userdata is stable
+func keyValueFlag(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%s", k, m[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// WorkerNodeTaintsString renders workerNodeTaints for the kubelet --register-with-taints flag
+func (c Cluster) WorkerNodeTaintsString() string {
+	taints := make([]string, len(c.WorkerNodeTaints))
+	for i, taint := range c.WorkerNodeTaints {
+		taints[i] = taint.String()
+	}
+	return strings.Join(taints, ",")
+}
+
+// AdmissionControlString renders admissionControl for the apiserver --admission-control flag
+func (c Cluster) AdmissionControlString() string {
+	return strings.Join(c.AdmissionControl, ",")
+}
+
+// APIELBHTTPSPort is the port of the API load balancer's HTTPS listener,
+// served with apiELBACMCertArn beside the TCP listener on 443
+func (c Cluster) APIELBHTTPSPort() int {
+	return 8443
+}
+
+// Partition is the AWS partition of the cluster's region, which ARNs start with
+func (c Cluster) Partition() string {
+	switch {
+	case strings.HasPrefix(c.Region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(c.Region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// DNSSuffix is the domain of the AWS endpoints and service principals in the
+// cluster's partition
+func (c Cluster) DNSSuffix() string {
+	if c.Partition() == "aws-cn" {
+		return "amazonaws.com.cn"
+	}
+	return "amazonaws.com"
+}
+
+// ControllerIAMInstanceProfileName is the name of controllerIAMInstanceProfile,
+// which EC2 instances require rather than an ARN
+func (c Cluster) ControllerIAMInstanceProfileName() string {
+	return IAMInstanceProfileName(c.ControllerIAMInstanceProfile)
+}
+
+// WorkerIAMInstanceProfileName is the name of workerIAMInstanceProfile
+func (c Cluster) WorkerIAMInstanceProfileName() string {
+	return IAMInstanceProfileName(c.WorkerIAMInstanceProfile)
+}
+
+// IAMInstanceProfileName returns the name of an instance profile given by name or ARN
+func IAMInstanceProfileName(profile string) string {
+	return profile[strings.LastIndex(profile, "/")+1:]
+}
+
+// CalicoIPPoolKey is the etcd key of the Calico IP pool for podCIDR, which
+// Calico names after the CIDR with the slash replaced by a dash
+func (c Cluster) CalicoIPPoolKey() string {
+	return "/calico/v1/ipam/v4/pool/" + strings.Replace(c.PodCIDR, "/", "-", 1)
+}
+
+// ProxyEnabled reports whether nodes should reach the internet through an HTTP proxy
+func (c Cluster) ProxyEnabled() bool {
+	return c.HTTPProxy != "" || c.HTTPSProxy != ""
+}
+
+// NoProxyString renders NO_PROXY for the nodes: noProxy plus the addresses
+// that must never go through the proxy, namely the node itself, the EC2
+// metadata service, the names and IPs of the API server and etcd, the EC2
+// internal hostnames and the cluster's own networks. Many clients ignore
+// CIDRs in NO_PROXY, so the hosts are listed explicitly too.
+func (c Cluster) NoProxyString() (string, error) {
+	noProxy := []string{}
+	for _, host := range strings.Split(c.NoProxy, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			noProxy = append(noProxy, host)
+		}
+	}
+	noProxy = append(noProxy, "localhost", "127.0.0.1", "169.254.169.254")
+
+	dnsNames, ipAddresses, err := c.apiServerSANs()
+	if err != nil {
+		return "", err
+	}
+	noProxy = append(noProxy, dnsNames...)
+	noProxy = append(noProxy, ipAddresses...)
+	noProxy = append(noProxy, c.EtcdIPs()...)
+	// The API load balancer's generated name is only known once the stack
+	// is created, so its whole domain is listed
+	noProxy = append(noProxy, ".internal", ".compute.internal", "."+c.Region+".elb."+c.DNSSuffix())
+
+	noProxy = append(noProxy, c.VPCCIDR)
+	for _, subnet := range c.Subnets {
+		noProxy = append(noProxy, subnet.InstanceCIDR)
+	}
+	noProxy = append(noProxy, c.ServiceCIDR, c.PodCIDR)
+	return strings.Join(noProxy, ","), nil
+}
+
+// CloudFormationStackName is the name of the cluster's stack: stackName if
+// set, clusterName otherwise
+func (c Cluster) CloudFormationStackName() string {
+	if c.StackName != "" {
+		return c.StackName
+	}
+	return c.ClusterName
+}
+
+// AllWorkerPools is workerPools, or a single unnamed pool of the worker*
+// settings when it isn't set
+func (c Cluster) AllWorkerPools() []WorkerPool {
+	if len(c.WorkerPools) > 0 {
+		return c.WorkerPools
+	}
+	return []WorkerPool{
+		{
+			Count:          c.WorkerCount,
+			InstanceType:   c.WorkerInstanceType,
+			InstanceTypes:  c.WorkerInstanceTypes,
+			RootVolumeSize: c.WorkerRootVolumeSize,
+			RootVolumeType: c.WorkerRootVolumeType,
+			RootVolumeIOPS: c.WorkerRootVolumeIOPS,
+			NodeLabels:     c.WorkerNodeLabels,
+			NodeTaints:     c.WorkerNodeTaints,
+		},
+	}
+}
+
+// WorkerLaunchInstanceTypes are the instance types the mixed instances policy
+// of the worker group launches
+func (c Cluster) WorkerLaunchInstanceTypes() []string {
+	if len(c.WorkerInstanceTypes) > 0 {
+		return c.WorkerInstanceTypes
+	}
+	return []string{c.WorkerInstanceType}
+}
+
+// WorkerOnDemandPercentageAboveBase is the percentage of the workers above
+// workerOnDemandBaseCapacity launched as on-demand instances rather than spot
+func (c Cluster) WorkerOnDemandPercentageAboveBase() int {
+	if c.WorkerOnDemandPercentage != nil {
+		return *c.WorkerOnDemandPercentage
+	}
+	if c.WorkerSpotPrice != "" {
+		return 0
+	}
+	return 100
+}
+
+// WorkerMinSize is the minimum size of the worker group
+func (c Cluster) WorkerMinSize() int {
+	if c.WorkerAutoScaling != nil {
+		return c.WorkerAutoScaling.MinSize
+	}
+	if c.WorkerClusterAutoscaler != nil {
+		return c.WorkerClusterAutoscaler.MinSize
+	}
+	return c.WorkerCount
+}
+
+// WorkerMaxSize is the maximum size of the worker group
+func (c Cluster) WorkerMaxSize() int {
+	if c.WorkerAutoScaling != nil {
+		return c.WorkerAutoScaling.MaxSize
+	}
+	if c.WorkerClusterAutoscaler != nil {
+		return c.WorkerClusterAutoscaler.MaxSize
+	}
+	return c.WorkerCount
+}
+
+// FlannelRoutes is the number of routes the aws-vpc flannel backend adds to
+// the route table of the nodes, one per controller and worker. The other
+// backends don't touch the route table.
+func (c Cluster) FlannelRoutes() int {
+	if c.FlannelBackend != flannelBackendAWSVPC {
+		return 0
+	}
+	return c.ControllerCount + c.WorkerMaxSize()
+}
+
+// KubeProxyIPVSFeatureGate reports whether kube-proxy needs the
+// SupportIPVSProxyMode feature gate to run in IPVS mode, which it does from
+// v1.8 until IPVS mode became generally available in v1.11
+func (c Cluster) KubeProxyIPVSFeatureGate() bool {
+	return c.KubeProxyMode == kubeProxyModeIPVS && k8sVerAtLeast(c.K8sVer, 1, 8) && !k8sVerAtLeast(c.K8sVer, 1, 11)
+}
+
+// SSHWorldOpen reports whether sshAccessCIDRs opens SSH to any address
+func (c Cluster) SSHWorldOpen() bool {
+	return anyWorldOpen(c.SSHAccessCIDRs)
+}
+
+// APIWorldOpen reports whether apiAccessCIDRs opens the API to any address
+func (c Cluster) APIWorldOpen() bool {
+	return anyWorldOpen(c.APIAccessCIDRs)
+}
+
+// reachableFromVPC reports whether cidr overlaps the VPC or the VPC it is
+// peered with, from which an internal load balancer can be reached
+func (c Cluster) reachableFromVPC(cidr string) bool {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	reachableCIDRs := []string{c.VPCCIDR}
+	if c.VPCPeering != nil {
+		reachableCIDRs = append(reachableCIDRs, c.VPCPeering.PeerCIDR)
+	}
+	for _, reachable := range reachableCIDRs {
+		if _, reachableNet, err := net.ParseCIDR(reachable); err == nil && cidrOverlap(ipNet, reachableNet) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyWorldOpen(cidrs []string) bool {
+	for _, cidr := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			if ones, _ := ipNet.Mask.Size(); ones == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SubnetRef is the stack template reference to the subnet at index in
+// subnets: the existing subnet of subnetIds, or the subnet the stack creates.
+func (c Cluster) SubnetRef(index int) string {
+	if len(c.SubnetIDs) > 0 {
+		return fmt.Sprintf("%q", c.SubnetIDs[index])
+	}
+	return fmt.Sprintf(`{ "Ref" : "Subnet%d" }`, index)
+}
+
+// ControllerIPs returns the private IPs of the controllers. They are spread
+// across the subnets in turn, starting with the one containing controllerIP,
+// at controllerIP's offset into its subnet. Once every subnet has a
+// controller the next ones take the following addresses, so with a single
+// subnet they are controllerCount consecutive addresses from controllerIP.
+func (c Cluster) ControllerIPs() []string {
+	ip := net.ParseIP(c.ControllerIP).To4()
+	if ip == nil {
+		return nil
+	}
+	instanceNets := []*net.IPNet{}
+	first := 0
+	for _, subnet := range c.Subnets {
+		_, instanceNet, err := net.ParseCIDR(subnet.InstanceCIDR)
+		if err != nil {
+			return nil
+		}
+		if instanceNet.Contains(ip) {
+			first = len(instanceNets)
+		}
+		instanceNets = append(instanceNets, instanceNet)
+	}
+	if len(instanceNets) == 0 {
+		instanceNets = append(instanceNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)})
+	}
+	mask := instanceNets[first].Mask
+
+	ips := make([]string, c.ControllerCount)
+	for i := range ips {
+		instanceNet := instanceNets[(first+i)%len(instanceNets)]
+		controllerIP := make(net.IP, len(ip))
+		for j := range ip {
+			controllerIP[j] = instanceNet.IP[j] | ip[j]&^mask[j]
+		}
+		for round := 0; round < i/len(instanceNets); round++ {
+			controllerIP = incrementIP(controllerIP)
+		}
+		ips[i] = controllerIP.String()
+	}
+	return ips
+}
+
+// EtcdIPs returns the private IPs of the etcd nodes: etcdCount consecutive
+// addresses starting at etcdIP or, if etcdIP is unset, ending just below
+// controllerIP.
+func (c Cluster) EtcdIPs() []string {
+	var ip net.IP
+	if c.EtcdIP != "" {
+		ip = net.ParseIP(c.EtcdIP)
+	} else if ip = net.ParseIP(c.ControllerIP); ip != nil {
+		for i := 0; i < c.EtcdCount; i++ {
+			ip = decrementIP(ip)
+		}
+	}
+	if ip == nil || c.EtcdCount < 0 {
+		return nil
+	}
+	ips := make([]string, c.EtcdCount)
+	for i := range ips {
+		ips[i] = ip.String()
+		ip = incrementIP(ip)
+	}
+	return ips
+}
+
+func (c Cluster) Config() (*Config, error) {
+	config := Config{Cluster: c}
+	config.APIServers = fmt.Sprintf("http://%s:8080", c.ControllerIP)
+	config.SecureAPIServers = fmt.Sprintf("https://%s:443", c.ControllerIP)
+	config.APIServerEndpoint = fmt.Sprintf("https://%s", c.ExternalDNSName)
+
+	etcdIPs := c.EtcdIPs()
+	etcdEndpoints := make([]string, len(etcdIPs))
+	etcdPeers := make([]string, len(etcdIPs))
+	for i, ip := range etcdIPs {
+		etcdEndpoints[i] = fmt.Sprintf("http://%s:2379", ip)
+		etcdPeers[i] = fmt.Sprintf("%s=http://%s:2380", ip, ip)
+	}
+	config.ETCDEndpoints = strings.Join(etcdEndpoints, ",")
+	config.ETCDInitialCluster = strings.Join(etcdPeers, ",")
+
+	if c.ControllerCount > 1 {
+		//Reach the API through the load balancer rather than a single controller
+		config.SecureAPIServers = config.APIServerEndpoint
+	}
+	if config.NetworkPlugin == networkPluginCalico {
+		//Calico networking always comes with its policy agent
+		config.UseCalico = true
+	}
+	if config.UseCalico {
+		config.K8sNetworkPlugin = "cni"
+	}
+
+	if config.AmiId != "" {
+		config.AMI = config.AmiId
+	} else {
+		var err error
+		if config.AMI, err = getAMI(config.Region, config.ReleaseChannel); err != nil {
+			return nil, fmt.Errorf("failed getting AMI for config: %v", err)
+		}
+	}
+
+	//Set logical name constants
+	config.VPCLogicalName = vpcLogicalName
+
+	//Set reference strings
+
+	//Assume VPC does not exist, reference by logical name
+	config.VPCRef = fmt.Sprintf(`{ "Ref" : %q }`, config.VPCLogicalName)
+	if config.VPCID != "" {
+		//This means this VPC already exists, and we can reference it directly by ID
+		config.VPCRef = fmt.Sprintf("%q", config.VPCID)
+	}
+
+	return &config, nil
+}
+
+type StackTemplateOptions struct {
+	TLSAssetsDir          string
+	ControllerTmplFile    string
+	EtcdTmplFile          string
+	WorkerTmplFile        string
+	StackTemplateTmplFile string
+}
+
+type stackConfig struct {
+	*Config
+	UserDataController string
+	UserDataEtcd       string
+	Workers            []stackWorkerPool
+	Controllers        []stackInstance
+	Etcds              []stackInstance
+}
+
+// stackWorkerPool is the autoscaling group and launch configuration of a
+// worker pool in the stack template. Its Config has the pool's settings in
+// the worker* fields, so the worker templates render the pool unchanged.
+type stackWorkerPool struct {
+	*Config
+	Name string
+	// Suffix distinguishes the logical names of the pool's resources. It is
+	// empty for the workers of a cluster without workerPools so that their
+	// resources keep the names they had before workerPools existed.
+	Suffix         string
+	UserDataWorker string
+}
+
+// NameTag is the Name tag of the pool's instances
+func (w stackWorkerPool) NameTag() string {
+	if w.Name != "" {
+		return w.ClusterName + "-kube-aws-worker-" + w.Name
+	}
+	return w.ClusterName + "-kube-aws-worker"
+}
+
+// stackWorkerPools renders the worker cloud-config of each worker pool
+func (c *Config) stackWorkerPools(workerTmplFile string) ([]stackWorkerPool, error) {
+	pools := c.AllWorkerPools()
+	workers := make([]stackWorkerPool, len(pools))
+	for i, pool := range pools {
+		poolConfig := *c
+		poolConfig.WorkerCount = pool.Count
+		poolConfig.WorkerInstanceType = pool.InstanceType
+		poolConfig.WorkerInstanceTypes = pool.InstanceTypes
+		if len(pool.InstanceTypes) > 0 {
+			// The launch template's type, which the policy overrides anyway
+			poolConfig.WorkerInstanceType = pool.InstanceTypes[0]
+		}
+		poolConfig.WorkerRootVolumeSize = pool.RootVolumeSize
+		poolConfig.WorkerRootVolumeType = pool.RootVolumeType
+		poolConfig.WorkerRootVolumeIOPS = pool.RootVolumeIOPS
+		poolConfig.WorkerNodeLabels = pool.NodeLabels
+		poolConfig.WorkerNodeTaints = pool.NodeTaints
+
+		workers[i] = stackWorkerPool{
+			Config: &poolConfig,
+			Name:   pool.Name,
+			Suffix: pool.LogicalSuffix(),
+		}
+		userData, err := execute(workerTmplFile, workers[i].Config)
+		if err != nil {
+			if pool.Name != "" {
+				return nil, fmt.Errorf("failed to render worker cloud config of worker pool %s: %v", pool.Name, err)
+			}
+			return nil, fmt.Errorf("failed to render worker cloud config: %v", err)
+		}
+		workers[i].UserDataWorker = userData
+	}
+	return workers, nil
+}
+
+// stackInstance is an EC2 instance with a fixed IP in the stack template
+type stackInstance struct {
+	// Suffix distinguishes the logical names of the instance's resources.
+	// It is empty for the first instance of a role so that the first
+	// controller's resources keep the names they had before controllerCount
+	// existed.
+	Suffix      string
+	IP          string
+	SubnetIndex int
+}
+
+// stackInstances places an instance at each of ips in the subnet containing it
+func (c Cluster) stackInstances(ips []string) ([]stackInstance, error) {
+	instances := make([]stackInstance, len(ips))
+	for i, ip := range ips {
+		instances[i].IP = ip
+		if i > 0 {
+			instances[i].Suffix = strconv.Itoa(i)
+		}
+		ipAddr := net.ParseIP(ip)
+		subnetFound := false
+		for j, subnet := range c.Subnets {
+			_, instanceCIDR, err := net.ParseCIDR(subnet.InstanceCIDR)
+			if err != nil {
+				return nil, fmt.Errorf("invalid instanceCIDR: %v", err)
+			}
+			if instanceCIDR.Contains(ipAddr) {
+				instances[i].SubnetIndex = j
+				subnetFound = true
+			}
+		}
+		if !subnetFound {
+			return nil, fmt.Errorf("Fail-fast occurred possibly because of a bug: subnet index couldn't be determined for subnets (%v) and IP (%v)", c.Subnets, ip)
+		}
+	}
+	return instances, nil
+}
+
+func execute(filename string, data interface{}) (string, error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(filename).Parse(string(raw))
+	if err != nil {
+		return "", err
+	}
+	var buff bytes.Buffer
+	if err := tmpl.Execute(&buff, data); err != nil {
+		return "", err
+	}
+	return buff.String(), nil
+}
+
+// EC2 limits userdata to 16KB before it is base64 encoded, so at most this
+// many bytes of the base64 encoded userdata embedded in the stack template
+var maxUserDataSize = base64.StdEncoding.EncodedLen(16 * 1024)
+
+// compressUserData gzips and base64 encodes each rendered cloud-config of the
+// stack for embedding in the stack template. coreos-cloudinit recognises the
+// gzip header and decompresses userdata at boot, so compressing keeps
+// cloud-configs well under the EC2 limit. It is an error if any compressed
+// cloud-config is still too large, listing every one that is.
+func (s *stackConfig) compressUserData() error {
+	type userData struct {
+		name    string
+		content *string
+	}
+	// Copy the workers so that a shallow copy of a stackConfig can be
+	// compressed leaving the original's userdata as it was
+	s.Workers = append([]stackWorkerPool(nil), s.Workers...)
+	userDatas := []userData{}
+	for i := range s.Workers {
+		name := "worker"
+		if s.Workers[i].Name != "" {
+			name = "worker pool " + s.Workers[i].Name
+		}
+		userDatas = append(userDatas, userData{name, &s.Workers[i].UserDataWorker})
+	}
+	userDatas = append(userDatas,
+		userData{"controller", &s.UserDataController},
+		userData{"etcd", &s.UserDataEtcd},
+	)
+
+	oversized := []string{}
+	for _, userData := range userDatas {
+		encoded, err := compressData([]byte(*userData.content))
+		if err != nil {
+			return err
+		}
+		if len(encoded) > maxUserDataSize {
+			oversized = append(oversized, fmt.Sprintf("%s cloud-config is %d bytes (%d bytes uncompressed)",
+				userData.name,
+				len(encoded),
+				len(*userData.content),
+			))
+		}
+		*userData.content = encoded
+	}
+	if len(oversized) > 0 {
+		return fmt.Errorf("userdata exceeds the EC2 limit of %d bytes after gzip and base64 encoding: %s",
+			maxUserDataSize,
+			strings.Join(oversized, ", "),
+		)
+	}
+	return nil
+}
+
+func (c Cluster) kmsService() *kms.KMS {
+	awsConfig := aws.NewConfig().
+		WithRegion(c.Region).
+		WithCredentialsChainVerboseErrors(true)
+
+	return kms.New(session.New(awsConfig))
+}
+
+// Warnings returns the problems with the cluster config that don't stop it
+// from being deployed, for the CLI to print once
+func (c Cluster) Warnings() []string {
+	var warnings []string
+
+	if len(c.unknownKeys) > 0 {
+		warnings = append(warnings, fmt.Sprintf("ignoring unknown keys: %s", strings.Join(c.unknownKeys, ", ")))
+	}
+
+	if c.NATMode == natModeInstance && len(c.Subnets) > 1 {
+		warnings = append(warnings, fmt.Sprintf(
+			"natMode is instance, so all %d subnets reach the internet through a single NAT instance in %s. It is a single point of failure for egress; use natMode gateway for a NAT gateway per subnet.",
+			len(c.Subnets),
+			c.Subnets[0].AvailabilityZone,
+		))
+	}
+
+	if !c.CreateRecordSet && c.RecordSetTTL != newDefaultCluster().RecordSetTTL {
+		warnings = append(warnings, "recordSetTTL is ignored because createRecordSet is false.")
+	}
+
+	if c.InstanceTenancy == instanceTenancyDedicated {
+		warnings = append(warnings, "instanceTenancy is dedicated, so every node runs on single-tenant hardware. Dedicated instances cost significantly more, and AWS charges an additional fee per region while any are running.")
+	}
+
+	if c.MetadataOptions.HTTPTokens == metadataHTTPTokensRequired {
+		warnings = append(warnings, "metadataOptions.httpTokens is required, so the instance metadata service only answers IMDSv2 requests. Older AWS SDKs and tools inside pods, and on the nodes themselves, only make IMDSv1 requests and will fail to get credentials or instance details.")
+	}
+
+	if c.KubeProxyMode == kubeProxyModeIPVS && !k8sVerAtLeast(c.K8sVer, 1, 8) {
+		warnings = append(warnings, fmt.Sprintf(
+			"kubeProxyMode is ipvs, which kube-proxy supports from kubernetes v1.8, but kubernetesVersion is %s. kube-proxy will fail to start; use kubeProxyMode iptables or a newer kubernetesVersion.",
+			c.K8sVer,
+		))
+	}
+
+	if routes := c.FlannelRoutes(); routes > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"flannelBackend %s adds a route per node to the VPC route table, which AWS limits to %d routes by default. This cluster can have up to %d nodes.",
+			c.FlannelBackend,
+			maxRoutesPerRouteTable,
+			routes,
+		))
+	}
+
+	if c.sshAccessCIDRsSet && c.SSHWorldOpen() {
+		warnings = append(warnings, "sshAccessCIDRs includes 0.0.0.0/0, so SSH on every node is open to the whole internet. Restrict sshAccessCIDRs to the networks the cluster is administered from.")
+	}
+	if c.apiAccessCIDRsSet && c.APIEndpointInternal {
+		for _, cidr := range c.APIAccessCIDRs {
+			if !c.reachableFromVPC(cidr) {
+				warnings = append(warnings, fmt.Sprintf(
+					"apiAccessCIDRs includes %s, which is outside vpcCIDR %s. The internal API load balancer is only reachable from it through peering, VPN or Direct Connect.",
+					cidr,
+					c.VPCCIDR,
+				))
+			}
+		}
+	} else if c.apiAccessCIDRsSet && c.APIWorldOpen() {
+		warnings = append(warnings, "apiAccessCIDRs includes 0.0.0.0/0, so the API load balancer is open to the whole internet. Restrict apiAccessCIDRs to the networks the cluster is administered from.")
+	}
+
+	if c.TLSCertDurationDays > 0 && c.TLSCertDurationDays < tlsCertDurationWarnDays {
+		warnings = append(warnings, fmt.Sprintf(
+			"tlsCertDurationDays is %d, generated certificates will expire %d days after they are generated. Plan to rotate them before then.",
+			c.TLSCertDurationDays,
+			c.TLSCertDurationDays,
+		))
+	}
+
+	return warnings
+}
+
+func (c Cluster) stackConfig(opts StackTemplateOptions, compressUserData bool, kmsSvc encryptService) (*stackConfig, error) {
+	assets, err := ReadTLSAssets(opts.TLSAssetsDir)
+	if err != nil {
+		return nil, err
+	}
+	stackConfig := stackConfig{}
+
+	if stackConfig.Config, err = c.Config(); err != nil {
+		return nil, err
+	}
+
+	compactAssets, err := assets.compact(stackConfig.Config, kmsSvc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress TLS assets: %v", err)
+	}
+
+	stackConfig.Config.TLSConfig = compactAssets
+
+	if c.DockerConfigJSON != "" {
+		if stackConfig.Config.DockerConfig, err = compactSecret(stackConfig.Config, kmsSvc, "dockerConfigJSON", []byte(c.DockerConfigJSON)); err != nil {
+			return nil, err
+		}
+	}
+
+	if stackConfig.Controllers, err = stackConfig.stackInstances(stackConfig.ControllerIPs()); err != nil {
+		return nil, err
+	}
+	if stackConfig.Etcds, err = stackConfig.stackInstances(stackConfig.EtcdIPs()); err != nil {
+		return nil, err
+	}
+
+	if stackConfig.Workers, err = stackConfig.stackWorkerPools(opts.WorkerTmplFile); err != nil {
+		return nil, err
+	}
+	if stackConfig.UserDataController, err = execute(opts.ControllerTmplFile, stackConfig.Config); err != nil {
+		return nil, fmt.Errorf("failed to render controller cloud config: %v", err)
+	}
+	if stackConfig.UserDataEtcd, err = execute(opts.EtcdTmplFile, stackConfig.Config); err != nil {
+		return nil, fmt.Errorf("failed to render etcd cloud config: %v", err)
+	}
+
+	if compressUserData {
+		if err := stackConfig.compressUserData(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &stackConfig, nil
+}
+
+// RenderedAssets are the rendered artifacts of a cluster: the stack template
+// kube-aws up would create, the userdata embedded in it and the TLS assets.
+type RenderedAssets struct {
+	StackTemplate      []byte
+	UserDataController []byte
+	UserDataEtcd       []byte
+	TLSAssets          *RawTLSAssets
+	// UserDataWorkers is keyed by worker pool name, "" for the workers of a
+	// cluster without workerPools
+	UserDataWorkers map[string][]byte
+}
+
+// digestEncryptService stands in for KMS when rendering offline. Instead of
+// ciphertext it embeds a digest of the plaintext, so rendered output is
+// deterministic and changes exactly when a TLS asset changes.
+type digestEncryptService struct{}
+
+func (d digestEncryptService) Encrypt(input *kms.EncryptInput) (*kms.EncryptOutput, error) {
+	return &kms.EncryptOutput{
+		CiphertextBlob: []byte(fmt.Sprintf("kms-encrypted sha256:%x", sha256.Sum256(input.Plaintext))),
+	}, nil
+}
+
+// RenderAssets renders the stack template and userdata without making any AWS
+// API calls. TLS assets are replaced by digests of their contents rather than
+// being encrypted with KMS, so the stack template is suitable for review but
+// not for deployment. Output is deterministic for a given config, asset
+// directory and AMI. Set amiId to avoid looking up the AMI of releaseChannel.
+func (c Cluster) RenderAssets(opts StackTemplateOptions) (*RenderedAssets, error) {
+	tlsAssets, err := ReadTLSAssets(opts.TLSAssetsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	rawStackConfig, err := c.stackConfig(opts, false, digestEncryptService{})
+	if err != nil {
+		return nil, err
+	}
+
+	stackConfig := *rawStackConfig
+	if err := stackConfig.compressUserData(); err != nil {
+		return nil, err
+	}
+
+	stackTemplate, err := renderStackTemplate(opts, &stackConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	userDataWorkers := map[string][]byte{}
+	for _, worker := range rawStackConfig.Workers {
+		userDataWorkers[worker.Name] = []byte(worker.UserDataWorker)
+	}
+
+	return &RenderedAssets{
+		StackTemplate:      stackTemplate,
+		UserDataController: []byte(rawStackConfig.UserDataController),
+		UserDataWorkers:    userDataWorkers,
+		UserDataEtcd:       []byte(rawStackConfig.UserDataEtcd),
+		TLSAssets:          tlsAssets,
+	}, nil
+}
+
+// WriteToDir writes the rendered assets to dirname, laid out like an asset directory
+func (r *RenderedAssets) WriteToDir(dirname string) error {
+	for _, dir := range []string{credentialsDir, userDataDir} {
+		if err := os.MkdirAll(filepath.Join(dirname, dir), 0700); err != nil {
+			return err
+		}
+	}
+
+	if err := r.TLSAssets.WriteToDir(filepath.Join(dirname, credentialsDir)); err != nil {
+		return err
+	}
+
+	type file struct {
+		name string
+		data []byte
+		mode os.FileMode
+	}
+	files := []file{
+		{filepath.Join(credentialsDir, ".gitignore"), []byte("*"), 0644},
+		{filepath.Join(userDataDir, "cloud-config-controller"), r.UserDataController, 0644},
+		{filepath.Join(userDataDir, "cloud-config-etcd"), r.UserDataEtcd, 0644},
+		{"stack-template.json", r.StackTemplate, 0644},
+	}
+	for name, userData := range r.UserDataWorkers {
+		filename := "cloud-config-worker"
+		if name != "" {
+			filename += "-" + name
+		}
+		files = append(files, file{filepath.Join(userDataDir, filename), userData, 0644})
+	}
+	for _, file := range files {
+		if err := ioutil.WriteFile(filepath.Join(dirname, file.name), file.data, file.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type KubeConfigOptions struct {
+	TLSAssetsDir string
+	// Path defaults to kubeconfig in the asset directory holding TLSAssetsDir
+	Path string
+	// APIEndpoint defaults to https://<externalDNSName>, set it to reach the
+	// API through the load balancer's own DNS name instead
+	APIEndpoint string
+}
+
+type kubeConfig struct {
+	ClusterName       string
+	APIServerEndpoint string
+	CACertPath        string
+	AdminCertPath     string
+	AdminKeyPath      string
+}
+
+// WriteKubeConfig writes a kubeconfig for the admin user of the cluster and
+// returns its path. It references the CA and admin TLS assets by paths
+// relative to the kubeconfig, so the asset directory can be moved as a whole.
+func (c Cluster) WriteKubeConfig(opts KubeConfigOptions) (string, []string, error) {
+	kubeConfigPath := opts.Path
+	if kubeConfigPath == "" {
+		kubeConfigPath = filepath.Join(filepath.Dir(filepath.Clean(opts.TLSAssetsDir)), "kubeconfig")
+	}
+
+	assetPaths := map[string]string{}
+	for _, asset := range []string{"ca.pem", "admin.pem", "admin-key.pem"} {
+		assetPath := filepath.Join(opts.TLSAssetsDir, asset)
+		if _, err := os.Stat(assetPath); err != nil {
+			return "", nil, fmt.Errorf("TLS asset %s not found, generate the TLS assets with \"kube-aws render\" first: %v", assetPath, err)
+		}
+		relPath, err := relativePath(filepath.Dir(kubeConfigPath), assetPath)
+		if err != nil {
+			return "", nil, err
+		}
+		assetPaths[asset] = relPath
+	}
+
+	var warnings []string
+	apiEndpoint := opts.APIEndpoint
+	if apiEndpoint == "" {
+		apiEndpoint = fmt.Sprintf("https://%s", c.ExternalDNSName)
+	}
+
+	//kubectl refuses to connect to an endpoint the apiserver certificate isn't valid for
+	if apiServerCert, err := ioutil.ReadFile(filepath.Join(opts.TLSAssetsDir, "apiserver.pem")); err == nil {
+		endpointURL, err := url.Parse(apiEndpoint)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid API endpoint %q: %v", apiEndpoint, err)
+		}
+		assets := &RawTLSAssets{APIServerCert: apiServerCert}
+		if missing, err := assets.missingAPIServerSANs([]string{endpointURL.Hostname()}); err == nil && len(missing) > 0 {
+			warnings = append(warnings, fmt.Sprintf(
+				"the apiserver certificate is not valid for %s, so kubectl will fail TLS verification connecting to %s. Add it to apiServerAdditionalSANs and regenerate the certificates with \"kube-aws rotate-certs\".",
+				endpointURL.Hostname(),
+				apiEndpoint,
+			))
+		}
+	}
+
+	tmpl, err := template.New("kubeconfig").Parse(string(KubeConfigTemplate))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse kubeconfig template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, kubeConfig{
+		ClusterName:       c.ClusterName,
+		APIServerEndpoint: apiEndpoint,
+		CACertPath:        assetPaths["ca.pem"],
+		AdminCertPath:     assetPaths["admin.pem"],
+		AdminKeyPath:      assetPaths["admin-key.pem"],
+	}); err != nil {
+		return "", nil, fmt.Errorf("failed to render kubeconfig: %v", err)
+	}
+
+	if err := ioutil.WriteFile(kubeConfigPath, buf.Bytes(), 0600); err != nil {
+		return "", nil, err
+	}
+	return kubeConfigPath, warnings, nil
+}
+
+// relativePath returns target relative to base, falling back to the absolute
+// path of target when it can't be expressed relative to base
+func relativePath(base, target string) (string, error) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	if relPath, err := filepath.Rel(absBase, absTarget); err == nil {
+		return relPath, nil
+	}
+	return absTarget, nil
+}
+
+func (c Cluster) ValidateUserData(opts StackTemplateOptions) error {
+	stackConfig, err := c.stackConfig(opts, false, c.kmsService())
+	if err != nil {
+		return err
+	}
+
+	errors := []string{}
+
+	type userData struct {
+		Name    string
+		Content string
+	}
+	userDatas := []userData{
+		{
+			Content: stackConfig.UserDataController,
+			Name:    "UserDataController",
+		},
+		{
+			Content: stackConfig.UserDataEtcd,
+			Name:    "UserDataEtcd",
+		},
+	}
+	for _, worker := range stackConfig.Workers {
+		userDatas = append(userDatas, userData{
+			Content: worker.UserDataWorker,
+			Name:    "UserDataWorker" + worker.Suffix,
+		})
+	}
+
+	for _, userData := range userDatas {
+		report, err := validate.Validate([]byte(userData.Content))
+
+		if err != nil {
+			errors = append(
+				errors,
+				fmt.Sprintf("cloud-config %s could not be parsed: %v",
+					userData.Name,
+					err,
+				),
+			)
+			continue
+		}
+
+		for _, entry := range report.Entries() {
+			errors = append(errors, fmt.Sprintf("%s: %+v", userData.Name, entry))
+		}
+	}
+
+	compressed := *stackConfig
+	if err := compressed.compressUserData(); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	if len(errors) > 0 {
+		reportString := strings.Join(errors, "\n")
+		return fmt.Errorf("cloud-config validation errors:\n%s\n", reportString)
+	}
+
+	return nil
+}
+
+func (c Cluster) RenderStackTemplate(opts StackTemplateOptions) ([]byte, error) {
+	return c.renderStackTemplate(opts, c.kmsService())
+}
+
+func (c Cluster) renderStackTemplate(opts StackTemplateOptions, kmsSvc encryptService) ([]byte, error) {
+	stackConfig, err := c.stackConfig(opts, true, kmsSvc)
+	if err != nil {
+		return nil, err
+	}
+
+	return renderStackTemplate(opts, stackConfig)
+}
+
+func renderStackTemplate(opts StackTemplateOptions, stackConfig *stackConfig) ([]byte, error) {
+	rendered, err := execute(opts.StackTemplateTmplFile, stackConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	//Use unmarshal function to do syntax validation
+	renderedBytes := []byte(rendered)
+	var jsonHolder map[string]interface{}
+	if err := json.Unmarshal(renderedBytes, &jsonHolder); err != nil {
+		syntaxError, ok := err.(*json.SyntaxError)
+		if ok {
+			contextString := getContextString(renderedBytes, int(syntaxError.Offset), 3)
+			return nil, fmt.Errorf("%v:\njson syntax error (offset=%d), in this region:\n-------\n%s\n-------\n", err, syntaxError.Offset, contextString)
+		}
+		return nil, err
+	}
+
+	// minify JSON
+	var buff bytes.Buffer
+	if err := json.Compact(&buff, renderedBytes); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func getContextString(buf []byte, offset, lineCount int) string {
+
+	linesSeen := 0
+	var leftLimit int
+	for leftLimit = offset; leftLimit > 0 && linesSeen <= lineCount; leftLimit-- {
+		if buf[leftLimit] == '\n' {
+			linesSeen++
+		}
+	}
+
+	linesSeen = 0
+	var rightLimit int
+	for rightLimit = offset + 1; rightLimit < len(buf) && linesSeen <= lineCount; rightLimit++ {
+		if buf[rightLimit] == '\n' {
+			linesSeen++
+		}
+	}
+
+	return string(buf[leftLimit:rightLimit])
+}
+
+type Config struct {
+	Cluster
+
+	ETCDEndpoints      string
+	ETCDInitialCluster string
+	APIServers         string
+	SecureAPIServers   string
+	APIServerEndpoint  string
+	AMI                string
+
+	// Encoded TLS assets
+	TLSConfig *CompactTLSAssets
+
+	// Encrypted and encoded dockerConfigJSON, empty when it is unset
+	DockerConfig string
+
+	//Logical names of dynamic resources
+	VPCLogicalName string
+
+	//Reference strings for dynamic resources
+	VPCRef string
+
+	K8sNetworkPlugin string
+}
+
+func (c Cluster) valid() error {
+	if c.ExternalDNSName == "" {
 		return errors.New("externalDNSName must be set")
 	}
+	if strings.Contains(c.ExternalDNSName, "://") {
+		return fmt.Errorf("externalDNSName must be a hostname without a scheme, got %q", c.ExternalDNSName)
+	}
+	if len(c.ExternalDNSName) > maxDomainNameLength || !domainNameRegexp.MatchString(c.ExternalDNSName) {
+		return fmt.Errorf(
+			"externalDNSName %q is not a valid hostname. it must be dot separated labels of at most 63 letters, digits and inner hyphens, with no port or path",
+			c.ExternalDNSName,
+		)
+	}
+	if len(c.DNSDomain) > maxDomainNameLength || !domainNameRegexp.MatchString(c.DNSDomain) {
+		return fmt.Errorf("dnsDomain %q is not a valid domain name, expected something like cluster.local", c.DNSDomain)
+	}
+
+	releaseChannelSupported := supportedReleaseChannels[c.ReleaseChannel]
+	if !releaseChannelSupported {
+		return fmt.Errorf("releaseChannel %s is not supported", c.ReleaseChannel)
+	}
+
+	if c.CoreOSChannel != "" && !supportedCoreOSChannels[c.CoreOSChannel] {
+		return fmt.Errorf("coreosChannel %s is not supported. must be one of stable, beta or alpha", c.CoreOSChannel)
+	}
+	if !supportedRebootStrategies[c.RebootStrategy] {
+		return fmt.Errorf("rebootStrategy %s is not supported. must be one of reboot, etcd-lock or off", c.RebootStrategy)
+	}
+
+	if c.CreateRecordSet {
+		if c.RecordSetTTL < 1 || c.RecordSetTTL > maxRecordSetTTL {
+			return fmt.Errorf("recordSetTTL must be between 1 and %d seconds, got %d", maxRecordSetTTL, c.RecordSetTTL)
+		}
+		if c.HostedZone == "" {
+			return errors.New("hostedZone cannot be blank when createRecordSet is true")
+		}
+		//Zones are looked up by name only to validate them; the record is
+		//created in the zone with this id, as public and private zones may
+		//share a name
+		if c.HostedZoneID == "" {
+			if c.ManageRecordSet {
+				return errors.New("hostedZoneId cannot be blank when createRecordSet and manageRecordSet are true")
+			}
+		} else if !hostedZoneIDRegexp.MatchString(c.HostedZoneID) {
+			return fmt.Errorf("hostedZoneId %q is not a valid Route53 hosted zone id like Z1D633PJN98FT9", c.HostedZoneID)
+		}
+		if c.RecordSetAlias {
+			//Alias records have no TTL of their own
+			if c.RecordSetTTL != newDefaultCluster().RecordSetTTL {
+				return errors.New("recordSetTTL should not be modified when recordSetAlias is true")
+			}
+		}
+		if !isSubdomain(c.ExternalDNSName, c.HostedZone) {
+			return fmt.Errorf("%s is not a subdomain of %s",
+				c.ExternalDNSName,
+				c.HostedZone)
+		}
+	} else {
+		if c.RecordSetAlias {
+			return errors.New("recordSetAlias should not be set when createRecordSet is false")
+		}
+		if !c.ManageRecordSet {
+			return errors.New("manageRecordSet should not be set when createRecordSet is false")
+		}
+	}
+	if c.KeyName == "" {
+		return errors.New("keyName must be set")
+	}
+	if c.Region == "" {
+		return errors.New("region must be set")
+	}
+	if err := validateRegion(c.Region); err != nil {
+		return err
+	}
+	if c.ClusterName == "" {
+		return errors.New("clusterName must be set")
+	}
+	if !clusterNameRegexp.MatchString(c.ClusterName) || len(c.ClusterName) > maxClusterNameLength {
+		return fmt.Errorf(
+			"clusterName %q is invalid: it must start with a letter or digit, contain only letters, digits, hyphens, underscores and dots and be at most %d characters long",
+			c.ClusterName,
+			maxClusterNameLength,
+		)
+	}
+	if !stackNameRegexp.MatchString(c.CloudFormationStackName()) {
+		return fmt.Errorf(
+			"%q is not a valid CloudFormation stack name: it must start with a letter, contain only letters, digits and hyphens and be at most 128 characters long. set stackName to use a different name than clusterName",
+			c.CloudFormationStackName(),
+		)
+	}
+	if c.KMSKeyARN == "" {
+		return errors.New("kmsKeyArn must be set")
+	}
+	kmsKeyARNParts := kmsKeyARNRegexp.FindStringSubmatch(c.KMSKeyARN)
+	if kmsKeyARNParts == nil {
+		return fmt.Errorf("kmsKeyArn %q is not a KMS key ARN of the form arn:aws:kms:<region>:<account>:key/<key-id>", c.KMSKeyARN)
+	}
+	if kmsKeyPartition := kmsKeyARNParts[1]; kmsKeyPartition != c.Partition() {
+		return fmt.Errorf("kmsKeyArn %s is in partition %s, but region %s is in %s", c.KMSKeyARN, kmsKeyPartition, c.Region, c.Partition())
+	}
+	if kmsKeyRegion := kmsKeyARNParts[2]; kmsKeyRegion != c.Region {
+		return fmt.Errorf("kmsKeyArn %s is in region %s, but the cluster is in %s. KMS keys can only be used in their own region", c.KMSKeyARN, kmsKeyRegion, c.Region)
+	}
+
+	if err := validateStackTags(c.StackTags); err != nil {
+		return err
+	}
+
+	//InstanceTags plus the Name tag
+	if n := len(c.InstanceTags()) + 1; n > maxResourceTags {
+		return fmt.Errorf(
+			"stackTags would result in %d tags on instances, which exceeds the AWS limit of %d. kube-aws adds KubernetesCluster and Name tags itself",
+			n,
+			maxResourceTags,
+		)
+	}
+
+	if c.StackCreationTimeout < 1 {
+		return fmt.Errorf("stackCreationTimeout must be a positive number of minutes, got %d", c.StackCreationTimeout)
+	}
+
+	if c.APIELBIdleTimeout < 1 || c.APIELBIdleTimeout > 3600 {
+		return fmt.Errorf("apiELBIdleTimeout must be between 1 and 3600 seconds, got %d", c.APIELBIdleTimeout)
+	}
+	if c.APIELBACMCertARN != "" {
+		acmCertARNParts := acmCertARNRegexp.FindStringSubmatch(c.APIELBACMCertARN)
+		if acmCertARNParts == nil {
+			return fmt.Errorf("apiELBACMCertArn %q is not an ACM certificate ARN of the form arn:aws:acm:<region>:<account>:certificate/<certificate-id>", c.APIELBACMCertARN)
+		}
+		if acmCertPartition := acmCertARNParts[1]; acmCertPartition != c.Partition() {
+			return fmt.Errorf("apiELBACMCertArn %s is in partition %s, but region %s is in %s", c.APIELBACMCertARN, acmCertPartition, c.Region, c.Partition())
+		}
+		if acmCertRegion := acmCertARNParts[2]; acmCertRegion != c.Region {
+			return fmt.Errorf("apiELBACMCertArn %s is in region %s, but the cluster is in %s. load balancers can only use ACM certificates of their own region", c.APIELBACMCertARN, acmCertRegion, c.Region)
+		}
+	}
+
+	//Zero keeps the default validity periods of the generated certificates
+	if c.TLSCertDurationDays < 0 || c.TLSCertDurationDays > maxTLSCertDurationDays {
+		return fmt.Errorf(
+			"tlsCertDurationDays must be between 1 and %d, got %d",
+			maxTLSCertDurationDays,
+			c.TLSCertDurationDays,
+		)
+	}
+
+	if (c.CACertFile == "") != (c.CAKeyFile == "") {
+		return errors.New("caCertFile and caKeyFile must be set together to sign the certificates with an existing CA")
+	}
+
+	if c.AmiId != "" && !amiIDRegexp.MatchString(c.AmiId) {
+		return fmt.Errorf("amiId %q is not a valid AMI id", c.AmiId)
+	}
+
+	if !k8sVerRegexp.MatchString(c.K8sVer) {
+		return fmt.Errorf(
+			"kubernetesVersion %q is not a valid hyperkube image tag, expected something like v1.2.4_coreos.1",
+			c.K8sVer,
+		)
+	}
+
+	if c.RBACEnabled && !k8sVerAtLeast(c.K8sVer, 1, 3) {
+		return fmt.Errorf("rbacEnabled requires kubernetesVersion v1.3.0 or later, got %s", c.K8sVer)
+	}
+
+	if !imageRepoRegexp.MatchString(c.HyperkubeImageRepo) {
+		return fmt.Errorf("hyperkubeImageRepo %q is not a valid image repository, expected something like quay.io/coreos/hyperkube", c.HyperkubeImageRepo)
+	}
+
+	if c.ImageRepository != "" {
+		host := strings.SplitN(c.ImageRepository, "/", 2)[0]
+		if !imageRepositoryRegexp.MatchString(c.ImageRepository) || len(host) > maxDomainNameLength {
+			return fmt.Errorf("imageRepository %q is not a valid registry, expected a host with an optional port and path like registry.example.com:5000/mirror", c.ImageRepository)
+		}
+	}
+
+	if c.DockerConfigJSON != "" {
+		var dockerConfig map[string]interface{}
+		if err := json.Unmarshal([]byte(c.DockerConfigJSON), &dockerConfig); err != nil {
+			return fmt.Errorf("dockerConfigJSON is not a valid docker config.json: %v", err)
+		}
+	}
+
+	if err := c.AuditLog.valid(); err != nil {
+		return fmt.Errorf("invalid auditLog: %v", err)
+	}
+	if err := c.CloudWatchLogging.valid(); err != nil {
+		return fmt.Errorf("invalid cloudWatchLogging: %v", err)
+	}
+	if err := c.MetadataOptions.valid(); err != nil {
+		return fmt.Errorf("invalid metadataOptions: %v", err)
+	}
+
+	for _, flags := range []struct {
+		name  string
+		flags []string
+	}{
+		{"apiServerFlags", c.APIServerFlags},
+		{"controllerManagerFlags", c.ControllerManagerFlags},
+		{"schedulerFlags", c.SchedulerFlags},
+	} {
+		if err := validateExtraFlags(flags.name, flags.flags); err != nil {
+			return err
+		}
+	}
+
+	if (c.ControllerIAMInstanceProfile == "") != (c.WorkerIAMInstanceProfile == "") {
+		return errors.New("controllerIAMInstanceProfile and workerIAMInstanceProfile must be set together. kube-aws either creates both instance profiles or uses existing ones for both")
+	}
+	for _, profile := range []struct {
+		name, value string
+	}{
+		{"controllerIAMInstanceProfile", c.ControllerIAMInstanceProfile},
+		{"workerIAMInstanceProfile", c.WorkerIAMInstanceProfile},
+	} {
+		if profile.value == "" {
+			continue
+		}
+		profileParts := iamInstanceProfileRegexp.FindStringSubmatch(profile.value)
+		if profileParts == nil {
+			return fmt.Errorf("%s %q is not an instance profile name or ARN", profile.name, profile.value)
+		}
+		if partition := profileParts[2]; partition != "" && partition != c.Partition() {
+			return fmt.Errorf("%s %s is in partition %s, but region %s is in %s", profile.name, profile.value, partition, c.Region, c.Partition())
+		}
+	}
+
+	for _, statements := range []struct {
+		name, profile string
+		statements    []IAMPolicyStatement
+	}{
+		{"controllerIAMPolicyStatements", c.ControllerIAMInstanceProfile, c.ControllerIAMPolicyStatements},
+		{"workerIAMPolicyStatements", c.WorkerIAMInstanceProfile, c.WorkerIAMPolicyStatements},
+	} {
+		if len(statements.statements) > 0 && statements.profile != "" {
+			return fmt.Errorf("%s can't be used with an existing instance profile, add the statements to its role instead", statements.name)
+		}
+		for i, statement := range statements.statements {
+			if err := statement.valid(); err != nil {
+				return fmt.Errorf("invalid %s #%d: %v", statements.name, i, err)
+			}
+		}
+	}
+
+	for i, file := range c.WorkerCustomFiles {
+		if err := file.valid(); err != nil {
+			return fmt.Errorf("invalid workerCustomFiles #%d: %v", i, err)
+		}
+	}
+	units := map[string]bool{}
+	for i, unit := range c.WorkerSystemdUnits {
+		if err := unit.valid(); err != nil {
+			return fmt.Errorf("invalid workerSystemdUnits #%d: %v", i, err)
+		}
+		if workerSystemdUnits[unit.Name] {
+			return fmt.Errorf("invalid workerSystemdUnits #%d: %s is managed by kube-aws", i, unit.Name)
+		}
+		if units[unit.Name] {
+			return fmt.Errorf("invalid workerSystemdUnits #%d: %s is listed more than once", i, unit.Name)
+		}
+		units[unit.Name] = true
+	}
+
+	admissionControl := map[string]bool{}
+	for _, plugin := range c.AdmissionControl {
+		if !admissionControlPluginRegexp.MatchString(plugin) {
+			return fmt.Errorf("admissionControl plugin %q is not a valid plugin name such as ServiceAccount", plugin)
+		}
+		if admissionControl[plugin] {
+			return fmt.Errorf("admissionControl plugin %s is listed more than once", plugin)
+		}
+		admissionControl[plugin] = true
+	}
+	for _, plugin := range requiredAdmissionControlPlugins {
+		if !admissionControl[plugin] {
+			return fmt.Errorf("admissionControl must include %s, the cluster's service accounts depend on it", plugin)
+		}
+	}
+
+	if c.WorkerCount < 1 {
+		return fmt.Errorf("workerCount must be at least 1, got %d", c.WorkerCount)
+	}
+	if err := validateInstanceType("workerInstanceType", c.WorkerInstanceType); err != nil {
+		return err
+	}
+	if err := validateInstanceType("controllerInstanceType", c.ControllerInstanceType); err != nil {
+		return err
+	}
+
+	if err := validateRootVolume(
+		"controller",
+		c.ControllerRootVolumeSize,
+		c.ControllerRootVolumeType,
+		c.ControllerRootVolumeIOPS,
+	); err != nil {
+		return err
+	}
+	if err := validateRootVolume(
+		"worker",
+		c.WorkerRootVolumeSize,
+		c.WorkerRootVolumeType,
+		c.WorkerRootVolumeIOPS,
+	); err != nil {
+		return err
+	}
+
+	if c.WorkerSpotPrice != "" {
+		spotPrice, err := strconv.ParseFloat(c.WorkerSpotPrice, 64)
+		if err != nil || spotPrice <= 0 {
+			return fmt.Errorf(
+				"workerSpotPrice (%s) must be a positive decimal such as \"0.05\". leave it unset for on-demand instances",
+				c.WorkerSpotPrice,
+			)
+		}
+	}
+	if c.WorkerInstanceTypes != nil {
+		if err := validateInstanceTypes("workerInstanceTypes", c.WorkerInstanceTypes); err != nil {
+			return err
+		}
+	}
+	if c.WorkerOnDemandBaseCapacity < 0 {
+		return fmt.Errorf("workerOnDemandBaseCapacity must not be negative, got %d", c.WorkerOnDemandBaseCapacity)
+	}
+	if p := c.WorkerOnDemandPercentage; p != nil && (*p < 0 || *p > 100) {
+		return fmt.Errorf("workerOnDemandPercentageAboveBaseCapacity must be between 0 and 100, got %d", *p)
+	}
+	if !c.UseLaunchTemplate {
+		poolInstanceTypes := false
+		for _, pool := range c.WorkerPools {
+			poolInstanceTypes = poolInstanceTypes || pool.InstanceTypes != nil
+		}
+		if c.WorkerInstanceTypes != nil || poolInstanceTypes || c.WorkerOnDemandBaseCapacity != 0 || c.WorkerOnDemandPercentage != nil {
+			return errors.New("workerInstanceTypes, instanceTypes of workerPools, workerOnDemandBaseCapacity and workerOnDemandPercentageAboveBaseCapacity configure a mixed instances policy, which needs useLaunchTemplate")
+		}
+	} else {
+		spotWorkers := c.WorkerOnDemandPercentageAboveBase() < 100
+		if c.WorkerSpotPrice != "" && !spotWorkers {
+			return errors.New("workerSpotPrice has no effect with useLaunchTemplate when workerOnDemandPercentageAboveBaseCapacity is 100, as every worker is on-demand. lower the percentage or remove workerSpotPrice")
+		}
+		if spotWorkers && c.InstanceTenancy == instanceTenancyDedicated {
+			return errors.New("spot workers can't run with instanceTenancy dedicated. set workerOnDemandPercentageAboveBaseCapacity to 100 or use the default tenancy")
+		}
+	}
+	if c.WorkerAutoScaling != nil {
+		if err := c.WorkerAutoScaling.valid(c.WorkerCount); err != nil {
+			return fmt.Errorf("invalid workerAutoScaling: %v", err)
+		}
+	}
+	if c.WorkerClusterAutoscaler != nil {
+		if c.WorkerAutoScaling != nil {
+			return errors.New("workerAutoScaling and workerClusterAutoscaler would both resize the worker group. use only one of them")
+		}
+		if err := c.WorkerClusterAutoscaler.valid(c.WorkerCount); err != nil {
+			return fmt.Errorf("invalid workerClusterAutoscaler: %v", err)
+		}
+	}
+	if c.ControllerSpotPrice != "" {
+		return errors.New(
+			"controllerSpotPrice is not supported: the controller must run on-demand for stability. remove it to use an on-demand controller",
+		)
+	}
+
+	for i, rule := range c.ExtraWorkerSecurityGroupRules {
+		if err := rule.valid(); err != nil {
+			return fmt.Errorf("invalid extraWorkerSecurityGroupRules #%d: %v", i, err)
+		}
+	}
+	if err := validateAccessCIDRs("sshAccessCIDRs", c.SSHAccessCIDRs); err != nil {
+		return err
+	}
+	if err := validateAccessCIDRs("apiAccessCIDRs", c.APIAccessCIDRs); err != nil {
+		return err
+	}
+	// An internal endpoint gives the controllers no public IP, so they need
+	// a NAT for outbound access: created with natMode, or the existing VPC's.
+	if c.APIEndpointInternal && c.NATMode == "" && c.VPCID == "" {
+		return errors.New("apiEndpointInternal leaves the controllers without outbound access unless natMode is set. set natMode, or vpcId to a VPC routing through a NAT")
+	}
+	// With several controllers workers reach the API through the load
+	// balancer. A public one sees them by their public IPs, which can't be
+	// admitted ahead of time; behind a NAT they are admitted by its address.
+	if c.ControllerCount > 1 && !c.APIEndpointInternal && !c.APIWorldOpen() && c.VPCID == "" && c.NATMode == "" {
+		return errors.New("apiAccessCIDRs can't admit the workers, which reach the public API load balancer from their public IPs when controllerCount > 1. set natMode, apiEndpointInternal or an apiAccessCIDRs including 0.0.0.0/0")
+	}
+	for _, san := range c.APIServerAdditionalSANs {
+		if net.ParseIP(san) == nil && (len(san) > maxDomainNameLength || !domainNameRegexp.MatchString(san)) {
+			return fmt.Errorf("apiServerAdditionalSANs must be IP addresses or DNS names, got %q", san)
+		}
+	}
+
+	for k, v := range c.WorkerNodeLabels {
+		if err := validateLabel(k, v); err != nil {
+			return fmt.Errorf("invalid workerNodeLabels: %v", err)
+		}
+	}
+
+	for i, taint := range c.WorkerNodeTaints {
+		if err := taint.valid(); err != nil {
+			return fmt.Errorf("invalid workerNodeTaints #%d: %v", i, err)
+		}
+	}
+
+	if len(c.WorkerPools) > 0 {
+		if c.WorkerAutoScaling != nil || c.WorkerClusterAutoscaler != nil {
+			return errors.New("workerAutoScaling and workerClusterAutoscaler resize a single worker group and cannot be used with workerPools")
+		}
+		poolNames := map[string]string{}
+		for i, pool := range c.WorkerPools {
+			if pool.Name == "" {
+				return fmt.Errorf("workerPools #%d must have a name", i)
+			}
+			if !workerPoolNameRegexp.MatchString(pool.Name) {
+				return fmt.Errorf("workerPools name %q must be lower case letters and digits, optionally joined by hyphens", pool.Name)
+			}
+			if other, ok := poolNames[pool.LogicalSuffix()]; ok {
+				if other == pool.Name {
+					return fmt.Errorf("workerPools name %q is used more than once", pool.Name)
+				}
+				return fmt.Errorf("workerPools names %q and %q would give their resources the same names", other, pool.Name)
+			}
+			poolNames[pool.LogicalSuffix()] = pool.Name
+			if err := pool.valid(); err != nil {
+				return fmt.Errorf("invalid workerPools %q: %v", pool.Name, err)
+			}
+		}
+	}
+
+	if c.MaxPods < 0 {
+		return fmt.Errorf("maxPods must be positive, got %d", c.MaxPods)
+	}
+	if err := validateReservation("kubeReserved", c.KubeReserved); err != nil {
+		return err
+	}
+	if err := validateReservation("systemReserved", c.SystemReserved); err != nil {
+		return err
+	}
+
+	for _, proxy := range []struct {
+		name, value string
+	}{
+		{"httpProxy", c.HTTPProxy},
+		{"httpsProxy", c.HTTPSProxy},
+	} {
+		if proxy.value == "" {
+			continue
+		}
+		proxyURL, err := url.Parse(proxy.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", proxy.name, err)
+		}
+		if (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") || proxyURL.Host == "" {
+			return fmt.Errorf("%s %q must be an http:// or https:// URL such as http://proxy.example.com:3128", proxy.name, proxy.value)
+		}
+	}
+	if c.NoProxy != "" && !c.ProxyEnabled() {
+		return errors.New("noProxy should not be set when neither httpProxy nor httpsProxy is set")
+	}
+
+	switch c.NetworkPlugin {
+	case networkPluginFlannel:
+	case networkPluginCalico:
+		if c.PodCIDR == "" {
+			return errors.New("podCIDR must be set when networkPlugin is calico, it is used as the Calico IP pool")
+		}
+	default:
+		return fmt.Errorf("networkPlugin must be %q or %q, got %q", networkPluginFlannel, networkPluginCalico, c.NetworkPlugin)
+	}
+
+	switch c.FlannelBackend {
+	case flannelBackendVXLAN:
+	case flannelBackendHostGW:
+		// host-gw routes pod traffic directly to the destination node, which
+		// must be on the same network
+		if len(c.Subnets) > 1 {
+			return fmt.Errorf("flannelBackend %s needs every node in a single subnet, got %d subnets. use %s across subnets", c.FlannelBackend, len(c.Subnets), flannelBackendVXLAN)
+		}
+	case flannelBackendAWSVPC:
+		// aws-vpc maintains the routes of a single route table, while in
+		// gateway NAT mode every subnet has its own
+		if c.NATMode == natModeGateway && len(c.Subnets) > 1 {
+			return fmt.Errorf("flannelBackend %s needs every node subnet to share a route table, but natMode gateway creates one per subnet. use %s or natMode instance", c.FlannelBackend, flannelBackendVXLAN)
+		}
+	default:
+		return fmt.Errorf("flannelBackend must be %q, %q or %q, got %q", flannelBackendVXLAN, flannelBackendHostGW, flannelBackendAWSVPC, c.FlannelBackend)
+	}
+	if c.FlannelBackend != flannelBackendVXLAN && c.NetworkPlugin != networkPluginFlannel {
+		return fmt.Errorf("flannelBackend can only be set when networkPlugin is %s", networkPluginFlannel)
+	}
+
+	switch c.KubeProxyMode {
+	case kubeProxyModeIPTables, kubeProxyModeIPVS:
+	default:
+		return fmt.Errorf("kubeProxyMode must be %q or %q, got %q", kubeProxyModeIPTables, kubeProxyModeIPVS, c.KubeProxyMode)
+	}
+
+	switch c.InstanceTenancy {
+	case instanceTenancyDefault, instanceTenancyDedicated:
+	default:
+		return fmt.Errorf("instanceTenancy must be %q or %q, got %q", instanceTenancyDefault, instanceTenancyDedicated, c.InstanceTenancy)
+	}
+
+	if c.HostedZonePrivate && c.VPCID == "" {
+		return errors.New("vpcId must be specified if hostedZonePrivate is true, as the private hosted zone must already be associated with the VPC")
+	}
+
+	if c.VPCID == "" && c.RouteTableID != "" {
+		return errors.New("vpcId must be specified if routeTableId is specified")
+	}
+	if c.VPCDomainName != "" {
+		if c.VPCID != "" {
+			return errors.New("vpcDomainName can only be used when kube-aws creates the VPC. set the DHCP options of the existing vpc instead")
+		}
+		if len(c.VPCDomainName) > maxDomainNameLength || !domainNameRegexp.MatchString(c.VPCDomainName) {
+			return fmt.Errorf("vpcDomainName %q is not a valid domain name", c.VPCDomainName)
+		}
+	}
+	if len(c.SubnetIDs) > 0 {
+		if c.VPCID == "" {
+			return errors.New("vpcId must be specified if subnetIds are specified")
+		}
+		if c.InstanceCIDR != "" {
+			return errors.New("subnetIds and instanceCIDR cannot both be set. kube-aws either reuses the subnets of subnetIds or creates one for instanceCIDR")
+		}
+		if c.RouteTableID != "" {
+			return errors.New("routeTableId cannot be used with subnetIds. the existing subnets keep their own route tables")
+		}
+		if len(c.Subnets) != len(c.SubnetIDs) {
+			return fmt.Errorf(
+				"subnetIds needs an entry in subnets giving the availabilityZone and instanceCIDR of each subnet, got %d subnetIds and %d subnets",
+				len(c.SubnetIDs),
+				len(c.Subnets),
+			)
+		}
+		for _, subnetID := range c.SubnetIDs {
+			if !subnetIDRegexp.MatchString(subnetID) {
+				return fmt.Errorf("%q is not a valid subnet id", subnetID)
+			}
+		}
+	}
+	if c.VPCID == "" && len(c.APIELBSubnetIds) > 0 {
+		return errors.New("vpcId must be specified if apiELBSubnetIds are specified")
+	}
+	for _, subnetID := range c.APIELBSubnetIds {
+		if !subnetIDRegexp.MatchString(subnetID) {
+			return fmt.Errorf("apiELBSubnetIds entry %q is not a valid subnet id", subnetID)
+		}
+	}
+
+	if c.VPCID == "" && (len(c.ControllerSecurityGroupIds) > 0 || len(c.WorkerSecurityGroupIds) > 0) {
+		return errors.New("vpcId must be specified if controllerSecurityGroupIds or workerSecurityGroupIds are specified")
+	}
+	for _, sgID := range append(c.ControllerSecurityGroupIds, c.WorkerSecurityGroupIds...) {
+		if !securityGroupIDRegexp.MatchString(sgID) {
+			return fmt.Errorf("%q is not a valid security group id", sgID)
+		}
+	}
+
+	_, vpcNet, err := net.ParseCIDR(c.VPCCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid vpcCIDR: %v", err)
+	}
+	if err := c.validateVPCSize(vpcNet); err != nil {
+		return err
+	}
+
+	if c.VPCPeering != nil {
+		if c.VPCID != "" {
+			return errors.New("vpcPeering can only be used when kube-aws creates the VPC. peer the existing vpc yourself instead")
+		}
+		if err := c.VPCPeering.valid(vpcNet); err != nil {
+			return fmt.Errorf("invalid vpcPeering: %v", err)
+		}
+	}
+
+	controllerIPAddr := net.ParseIP(c.ControllerIP)
+	if controllerIPAddr == nil {
+		return fmt.Errorf("invalid controllerIP: %s", c.ControllerIP)
+	}
+
+	if c.ControllerCount < 1 {
+		return fmt.Errorf("controllerCount must be at least 1, got %d", c.ControllerCount)
+	}
+
+	if c.EtcdCount < 1 {
+		return fmt.Errorf("etcdCount must be at least 1, got %d", c.EtcdCount)
+	}
+	if c.EtcdCount%2 == 0 {
+		return fmt.Errorf(
+			"etcdCount must be odd, got %d. an even number of etcd members tolerates no more failures than one fewer. use %d or %d",
+			c.EtcdCount,
+			c.EtcdCount-1,
+			c.EtcdCount+1,
+		)
+	}
+	if c.EtcdIP != "" && net.ParseIP(c.EtcdIP) == nil {
+		return fmt.Errorf("invalid etcdIP: %s", c.EtcdIP)
+	}
+	if err := validateInstanceType("etcdInstanceType", c.EtcdInstanceType); err != nil {
+		return err
+	}
+	if c.EtcdRootVolumeSize < 1 {
+		return fmt.Errorf("etcdRootVolumeSize must be at least 1 GiB, got %d", c.EtcdRootVolumeSize)
+	}
+	if c.EtcdDataVolumeSize < minEtcdDataVolumeSize || c.EtcdDataVolumeSize > maxEtcdDataVolumeSize {
+		return fmt.Errorf(
+			"etcdDataVolumeSize must be between %d and %d GiB, got %d",
+			minEtcdDataVolumeSize,
+			maxEtcdDataVolumeSize,
+			c.EtcdDataVolumeSize,
+		)
+	}
+
+	var instanceCIDRs = make([]*net.IPNet, 0)
+	if len(c.Subnets) == 0 {
+		if c.AvailabilityZone == "" {
+			return fmt.Errorf("availabilityZone must be set")
+		}
+		_, instanceCIDR, err := net.ParseCIDR(c.InstanceCIDR)
+		if err != nil {
+			return fmt.Errorf("invalid instanceCIDR: %v", err)
+		}
+		instanceCIDRs = append(instanceCIDRs, instanceCIDR)
+		if err := validateInstanceCIDRWithinVPC(vpcNet, instanceCIDR); err != nil {
+			return err
+		}
+		if !instanceCIDR.Contains(controllerIPAddr) {
+			return fmt.Errorf("instanceCIDR (%s) does not contain controllerIP (%s)",
+				c.InstanceCIDR,
+				c.ControllerIP,
+			)
+		}
+	} else {
+		if c.InstanceCIDR != "" {
+			return fmt.Errorf("The top-level instanceCIDR(%s) must be empty when subnets are specified", c.InstanceCIDR)
+		}
+		if c.AvailabilityZone != "" {
+			return fmt.Errorf("The top-level availabilityZone(%s) must be empty when subnets are specified", c.AvailabilityZone)
+		}
+
+		for i, subnet := range c.Subnets {
+			if subnet.AvailabilityZone == "" {
+				return fmt.Errorf("availabilityZone must be set for subnet #%d", i)
+			}
+			_, instanceCIDR, err := net.ParseCIDR(subnet.InstanceCIDR)
+			if err != nil {
+				return fmt.Errorf("invalid instanceCIDR for subnet #%d: %v", i, err)
+			}
+			instanceCIDRs = append(instanceCIDRs, instanceCIDR)
+			if err := validateInstanceCIDRWithinVPC(vpcNet, instanceCIDR); err != nil {
+				return fmt.Errorf("%v for subnet #%d", err, i)
+			}
+		}
+
+		controllerInstanceCidrExists := false
+		for _, a := range instanceCIDRs {
+			if a.Contains(controllerIPAddr) {
+				controllerInstanceCidrExists = true
+			}
+		}
+		if !controllerInstanceCidrExists {
+			return fmt.Errorf("No instanceCIDRs in Subnets (%v) contain controllerIP (%s)",
+				instanceCIDRs,
+				c.ControllerIP,
+			)
+		}
+
+		for i, a := range instanceCIDRs {
+			for j, b := range instanceCIDRs[i+1:] {
+				if cidrOverlap(a, b) {
+					return fmt.Errorf("CIDR of subnet %d (%s) overlaps with CIDR of subnet %d (%s)", i, a, i+1+j, b)
+				}
+			}
+		}
+	}
+
+	if err := c.validateNAT(vpcNet, instanceCIDRs); err != nil {
+		return err
+	}
+
+	//The controllers are spread across the subnets, starting with the one
+	//containing controllerIP
+	first := 0
+	for i, instanceCIDR := range instanceCIDRs {
+		if instanceCIDR.Contains(controllerIPAddr) {
+			first = i
+		}
+	}
+	for i, ip := range c.ControllerIPs() {
+		if instanceCIDR := instanceCIDRs[(first+i)%len(instanceCIDRs)]; !instanceCIDR.Contains(net.ParseIP(ip)) {
+			return fmt.Errorf(
+				"controller IP %s is not in instanceCIDR %s. controllerCount (%d) controllers are spread across the subnets at controllerIP's (%s) offset into its subnet, taking the following addresses once every subnet has one",
+				ip,
+				instanceCIDR,
+				c.ControllerCount,
+				c.ControllerIP,
+			)
+		}
+	}
+
+	controllerIPs := map[string]bool{}
+	for _, ip := range c.ControllerIPs() {
+		controllerIPs[ip] = true
+	}
+	for _, ip := range c.EtcdIPs() {
+		ipAddr := net.ParseIP(ip)
+		contained := false
+		for _, instanceCIDR := range instanceCIDRs {
+			if instanceCIDR.Contains(ipAddr) {
+				contained = true
+			}
+		}
+		if !contained {
+			return fmt.Errorf(
+				"etcd IP %s is not in any instanceCIDR. etcdCount (%d) etcd nodes take consecutive addresses starting at etcdIP, or ending just below controllerIP if etcdIP is unset",
+				ip,
+				c.EtcdCount,
+			)
+		}
+		if controllerIPs[ip] {
+			return fmt.Errorf("etcd IP %s is also a controller IP. set etcdIP to a range that does not overlap the controllers", ip)
+		}
+	}
+
+	for _, nodes := range []struct {
+		role string
+		ips  []string
+	}{
+		{"controller", c.ControllerIPs()},
+		{"etcd", c.EtcdIPs()},
+	} {
+		for _, ip := range nodes.ips {
+			ipAddr := net.ParseIP(ip)
+			for _, instanceCIDR := range instanceCIDRs {
+				if !instanceCIDR.Contains(ipAddr) {
+					continue
+				}
+				if reserved := reservedSubnetAddress(instanceCIDR, ipAddr); reserved != "" {
+					return fmt.Errorf(
+						"%s IP %s is %s in instanceCIDR (%s) and cannot be assigned to an instance",
+						nodes.role,
+						ip,
+						reserved,
+						instanceCIDR,
+					)
+				}
+			}
+		}
+	}
+
+	usableHosts := 0
+	for _, instanceCIDR := range instanceCIDRs {
+		usableHosts += usableHostCount(instanceCIDR)
+	}
+	if nodeCount := c.WorkerMaxSize() + c.ControllerCount + c.EtcdCount; nodeCount > usableHosts {
+		return fmt.Errorf(
+			"instanceCIDRs %v have %d usable addresses once AWS reserves 5 in each subnet, but up to %d workers, controllerCount (%d) and etcdCount (%d) need %d",
+			instanceCIDRs,
+			usableHosts,
+			c.WorkerMaxSize(),
+			c.ControllerCount,
+			c.EtcdCount,
+			nodeCount,
+		)
+	}
+
+	_, podNet, err := net.ParseCIDR(c.PodCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid podCIDR: %v", err)
+	}
+
+	_, serviceNet, err := net.ParseCIDR(c.ServiceCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid serviceCIDR: %v", err)
+	}
+	for _, instanceCIDR := range instanceCIDRs {
+		if cidrOverlap(serviceNet, instanceCIDR) {
+			return fmt.Errorf("instanceCIDR (%s) overlaps with serviceCIDR (%s)", instanceCIDR, c.ServiceCIDR)
+		}
+		if cidrOverlap(podNet, instanceCIDR) {
+			return fmt.Errorf("instanceCIDR (%s) overlaps with podCIDR (%s)", instanceCIDR, c.PodCIDR)
+		}
+	}
+	if cidrOverlap(serviceNet, vpcNet) {
+		return fmt.Errorf("vpcCIDR (%s) overlaps with serviceCIDR (%s)", c.VPCCIDR, c.ServiceCIDR)
+	}
+	if cidrOverlap(podNet, vpcNet) {
+		return fmt.Errorf("vpcCIDR (%s) overlaps with podCIDR (%s)", c.VPCCIDR, c.PodCIDR)
+	}
+	if cidrOverlap(serviceNet, podNet) {
+		return fmt.Errorf("serviceCIDR (%s) overlaps with podCIDR (%s)", c.ServiceCIDR, c.PodCIDR)
+	}
+
+	kubernetesServiceIPAddr := incrementIP(serviceNet.IP)
+	if !serviceNet.Contains(kubernetesServiceIPAddr) {
+		return fmt.Errorf("serviceCIDR (%s) does not contain kubernetesServiceIP (%s)", c.ServiceCIDR, kubernetesServiceIPAddr)
+	}
+
+	// Suggested in errors below: the conventional kube-dns address, 10 past the start of serviceCIDR
+	suggestedDNSServiceIP := serviceNet.IP
+	for i := 0; i < 10; i++ {
+		suggestedDNSServiceIP = incrementIP(suggestedDNSServiceIP)
+	}
+	if !serviceNet.Contains(suggestedDNSServiceIP) {
+		suggestedDNSServiceIP = incrementIP(kubernetesServiceIPAddr)
+	}
+
+	dnsServiceIPAddr := net.ParseIP(c.DNSServiceIP)
+	if dnsServiceIPAddr == nil {
+		return fmt.Errorf("invalid dnsServiceIP: %q, set it to an address in serviceCIDR (%s) such as %s",
+			c.DNSServiceIP,
+			c.ServiceCIDR,
+			suggestedDNSServiceIP,
+		)
+	}
+	if !serviceNet.Contains(dnsServiceIPAddr) {
+		return fmt.Errorf("serviceCIDR (%s) does not contain dnsServiceIP (%s), use an address in serviceCIDR such as %s",
+			c.ServiceCIDR,
+			c.DNSServiceIP,
+			suggestedDNSServiceIP,
+		)
+	}
+	if dnsServiceIPAddr.Equal(serviceNet.IP) {
+		return fmt.Errorf("dnsServiceIP (%s) is the network address of serviceCIDR (%s), use an address such as %s",
+			c.DNSServiceIP,
+			c.ServiceCIDR,
+			suggestedDNSServiceIP,
+		)
+	}
+
+	if dnsServiceIPAddr.Equal(kubernetesServiceIPAddr) {
+		return fmt.Errorf("dnsServiceIP (%s) conflicts with kubernetesServiceIP, which is reserved for the API server. use an address such as %s",
+			dnsServiceIPAddr,
+			suggestedDNSServiceIP,
+		)
+	}
+
+	return nil
+}
+
+const (
+	networkPluginFlannel = "flannel"
+	networkPluginCalico  = "calico"
+)
+
+const (
+	flannelBackendVXLAN  = "vxlan"
+	flannelBackendHostGW = "host-gw"
+	flannelBackendAWSVPC = "aws-vpc"
+)
+
+const (
+	kubeProxyModeIPTables = "iptables"
+	kubeProxyModeIPVS     = "ipvs"
+)
+
+const (
+	instanceTenancyDefault   = "default"
+	instanceTenancyDedicated = "dedicated"
+)
+
+// maxRoutesPerRouteTable is the default AWS limit on the routes of a VPC route table
+const maxRoutesPerRouteTable = 50
+
+const (
+	natModeGateway  = "gateway"
+	natModeInstance = "instance"
+)
+
+// validateNAT checks natMode and the public subnets it needs, which must fit
+// in the VPC without overlapping each other or the instance subnets.
+func (c Cluster) validateNAT(vpcNet *net.IPNet, instanceCIDRs []*net.IPNet) error {
+	publicCIDRs := []string{c.PublicCIDR}
+	if len(c.Subnets) > 0 {
+		if c.PublicCIDR != "" {
+			return fmt.Errorf("The top-level publicCIDR(%s) must be empty when subnets are specified", c.PublicCIDR)
+		}
+		publicCIDRs = make([]string, len(c.Subnets))
+		for i, subnet := range c.Subnets {
+			publicCIDRs[i] = subnet.PublicCIDR
+		}
+	}
 
-	releaseChannelSupported := supportedReleaseChannels[c.ReleaseChannel]
-	if !releaseChannelSupported {
-		return fmt.Errorf("releaseChannel %s is not supported", c.ReleaseChannel)
+	switch c.NATMode {
+	case "":
+		for _, publicCIDR := range publicCIDRs {
+			if publicCIDR != "" {
+				return errors.New("publicCIDR is only used when natMode is set")
+			}
+		}
+		return nil
+	case natModeGateway, natModeInstance:
+	default:
+		return fmt.Errorf("natMode must be %q or %q, got %q", natModeGateway, natModeInstance, c.NATMode)
 	}
 
-	if c.CreateRecordSet {
-		if c.HostedZone == "" {
-			return errors.New("hostedZone cannot be blank when createRecordSet is true")
+	if c.VPCID != "" {
+		return errors.New("natMode can only be used when kube-aws creates the VPC. remove vpcId or natMode")
+	}
+	if c.NATMode == natModeInstance {
+		if err := validateInstanceType("natInstanceType", c.NATInstanceType); err != nil {
+			return err
 		}
-		if c.RecordSetTTL < 1 {
-			return errors.New("TTL must be at least 1 second")
+	}
+
+	publicNets := make([]*net.IPNet, len(publicCIDRs))
+	for i, publicCIDR := range publicCIDRs {
+		if publicCIDR == "" {
+			return fmt.Errorf("publicCIDR must be set for subnet #%d when natMode is set", i)
 		}
-		if !isSubdomain(c.ExternalDNSName, c.HostedZone) {
-			return fmt.Errorf("%s is not a subdomain of %s",
-				c.ExternalDNSName,
-				c.HostedZone)
+		_, publicNet, err := net.ParseCIDR(publicCIDR)
+		if err != nil {
+			return fmt.Errorf("invalid publicCIDR for subnet #%d: %v", i, err)
 		}
-	} else {
-		if c.RecordSetTTL != newDefaultCluster().RecordSetTTL {
-			return errors.New(
-				"recordSetTTL should not be modified when createRecordSet is false",
-			)
+		if !cidrContains(vpcNet, publicNet) {
+			return fmt.Errorf("publicCIDR (%s) for subnet #%d is not within vpcCIDR (%s)", publicNet, i, vpcNet)
 		}
+		for _, instanceCIDR := range instanceCIDRs {
+			if cidrOverlap(publicNet, instanceCIDR) {
+				return fmt.Errorf("publicCIDR (%s) for subnet #%d overlaps with instanceCIDR (%s)", publicNet, i, instanceCIDR)
+			}
+		}
+		for j, other := range publicNets[:i] {
+			if cidrOverlap(publicNet, other) {
+				return fmt.Errorf("publicCIDR of subnet %d (%s) overlaps with publicCIDR of subnet %d (%s)", i, publicNet, j, other)
+			}
+		}
+		publicNets[i] = publicNet
 	}
-	if c.KeyName == "" {
-		return errors.New("keyName must be set")
-	}
-	if c.Region == "" {
-		return errors.New("region must be set")
-	}
-	if c.ClusterName == "" {
-		return errors.New("clusterName must be set")
-	}
-	if c.KMSKeyARN == "" {
-		return errors.New("kmsKeyArn must be set")
+
+	return nil
+}
+
+const (
+	// Limits AWS enforces on provisioned IOPS (io1) volumes
+	minRootVolumeIOPS        = 100
+	maxRootVolumeIOPS        = 64000
+	maxRootVolumeIOPSPerGiB  = 50
+	minRootVolumeSizeForIOPS = 4
+)
+
+const (
+	// etcd keeps its write-ahead log and snapshots on the data volume
+	minEtcdDataVolumeSize = 5
+	// Largest gp2 volume AWS allows
+	maxEtcdDataVolumeSize = 16384
+)
+
+const (
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// validateStackTags checks stackTags against the limits AWS enforces on tags,
+// reporting every offending tag at once.
+func validateStackTags(tags map[string]string) error {
+	var errs []string
+
+	if len(tags) > maxResourceTags {
+		errs = append(errs, fmt.Sprintf("%d tags given, at most %d are allowed", len(tags), maxResourceTags))
 	}
 
-	if c.VPCID == "" && c.RouteTableID != "" {
-		return errors.New("vpcId must be specified if routeTableId is specified")
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	_, vpcNet, err := net.ParseCIDR(c.VPCCIDR)
-	if err != nil {
-		return fmt.Errorf("invalid vpcCIDR: %v", err)
+	for _, k := range keys {
+		v := tags[k]
+		if strings.HasPrefix(strings.ToLower(k), "aws:") {
+			errs = append(errs, fmt.Sprintf("tag %q: the aws: prefix is reserved for use by AWS", k))
+		}
+		if utf8.RuneCountInString(k) > maxTagKeyLength {
+			errs = append(errs, fmt.Sprintf("tag %q: key is longer than %d characters", k, maxTagKeyLength))
+		}
+		if utf8.RuneCountInString(v) > maxTagValueLength {
+			errs = append(errs, fmt.Sprintf("tag %q: value is longer than %d characters", k, maxTagValueLength))
+		}
 	}
 
-	controllerIPAddr := net.ParseIP(c.ControllerIP)
-	if controllerIPAddr == nil {
-		return fmt.Errorf("invalid controllerIP: %s", c.ControllerIP)
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid stackTags:\n%s", strings.Join(errs, "\n"))
 	}
+	return nil
+}
 
-	if len(c.Subnets) == 0 {
-		if c.AvailabilityZone == "" {
-			return fmt.Errorf("availabilityZone must be set")
+// Resources the kubelet can reserve for kubeReserved and systemReserved
+var reservableResources = map[string]bool{
+	"cpu":    true,
+	"memory": true,
+}
+
+// Kubernetes resource quantities: a decimal number with an optional SI or
+// binary suffix, or a decimal exponent
+var quantityRegexp = regexp.MustCompile(`^([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+|[numkMGTPE]|[KMGTPE]i)?$`)
+
+// validateReservation checks a kubelet resource reservation, a map of
+// resource name to quantity
+func validateReservation(name string, reservation map[string]string) error {
+	for resource, quantity := range reservation {
+		if !reservableResources[resource] {
+			return fmt.Errorf("%s can only reserve cpu and memory, got %q", name, resource)
 		}
-		_, instanceCIDR, err := net.ParseCIDR(c.InstanceCIDR)
+		if !quantityRegexp.MatchString(quantity) {
+			return fmt.Errorf("%s %s must be a quantity like 100m or 512Mi, got %q", name, resource, quantity)
+		}
+	}
+	return nil
+}
+
+// validateAccessCIDRs checks the source CIDRs of security group ingress rules,
+// which are IPv4 only
+func validateAccessCIDRs(name string, cidrs []string) error {
+	for _, cidr := range cidrs {
+		ip, _, err := net.ParseCIDR(cidr)
 		if err != nil {
-			return fmt.Errorf("invalid instanceCIDR: %v", err)
+			return fmt.Errorf("invalid %s: %v", name, err)
 		}
-		if !vpcNet.Contains(instanceCIDR.IP) {
-			return fmt.Errorf("vpcCIDR (%s) does not contain instanceCIDR (%s)",
-				c.VPCCIDR,
-				c.InstanceCIDR,
-			)
+		if ip.To4() == nil {
+			return fmt.Errorf("%s must be IPv4 CIDRs, got %s", name, cidr)
 		}
-		if !instanceCIDR.Contains(controllerIPAddr) {
-			return fmt.Errorf("instanceCIDR (%s) does not contain controllerIP (%s)",
-				c.InstanceCIDR,
-				c.ControllerIP,
-			)
+	}
+	return nil
+}
+
+func (r SecurityGroupRule) valid() error {
+	switch r.Protocol {
+	case "tcp", "udp":
+		if r.FromPort < 0 || r.FromPort > 65535 || r.ToPort < 0 || r.ToPort > 65535 {
+			return fmt.Errorf("ports must be between 0 and 65535, got %d-%d", r.FromPort, r.ToPort)
 		}
-	} else {
-		if c.InstanceCIDR != "" {
-			return fmt.Errorf("The top-level instanceCIDR(%s) must be empty when subnets are specified", c.InstanceCIDR)
+		if r.FromPort > r.ToPort {
+			return fmt.Errorf("fromPort (%d) must not be greater than toPort (%d)", r.FromPort, r.ToPort)
 		}
-		if c.AvailabilityZone != "" {
-			return fmt.Errorf("The top-level availabilityZone(%s) must be empty when subnets are specified", c.AvailabilityZone)
+	case "icmp":
+		//For icmp, fromPort is the ICMP type and toPort the code. -1 means all.
+		if r.FromPort < -1 || r.FromPort > 255 || r.ToPort < -1 || r.ToPort > 255 {
+			return fmt.Errorf("icmp type and code must be between -1 and 255, got %d and %d", r.FromPort, r.ToPort)
 		}
+	default:
+		return fmt.Errorf("protocol must be one of tcp, udp or icmp, got %q", r.Protocol)
+	}
 
-		var instanceCIDRs = make([]*net.IPNet, 0)
-		for i, subnet := range c.Subnets {
-			if subnet.AvailabilityZone == "" {
-				return fmt.Errorf("availabilityZone must be set for subnet #%d", i)
-			}
-			_, instanceCIDR, err := net.ParseCIDR(subnet.InstanceCIDR)
-			if err != nil {
-				return fmt.Errorf("invalid instanceCIDR for subnet #%d: %v", i, err)
-			}
-			instanceCIDRs = append(instanceCIDRs, instanceCIDR)
-			if !vpcNet.Contains(instanceCIDR.IP) {
-				return fmt.Errorf("vpcCIDR (%s) does not contain instanceCIDR (%s) for subnet #%d",
-					c.VPCCIDR,
-					c.InstanceCIDR,
-					i,
-				)
-			}
+	if _, _, err := net.ParseCIDR(r.CIDR); err != nil {
+		return fmt.Errorf("invalid cidr: %v", err)
+	}
+
+	return nil
+}
+
+func (a AuditLog) valid() error {
+	if !a.Enabled {
+		return nil
+	}
+	if !path.IsAbs(a.Path) || path.Dir(a.Path) == "/" {
+		return fmt.Errorf("path must be an absolute path below a directory such as /var/log/kube-apiserver/audit.log, got %q", a.Path)
+	}
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"maxAge", a.MaxAge},
+		{"maxBackups", a.MaxBackups},
+		{"maxSize", a.MaxSize},
+	} {
+		if limit.value < 1 {
+			return fmt.Errorf("%s must be positive, got %d", limit.name, limit.value)
 		}
+	}
+	return nil
+}
 
-		controllerInstanceCidrExists := false
-		for _, a := range instanceCIDRs {
-			if a.Contains(controllerIPAddr) {
-				controllerInstanceCidrExists = true
-			}
+// validateExtraFlags checks flags appended verbatim to a component's command
+// line, each of which must be a single --flag or --flag=value argument
+func validateExtraFlags(name string, flags []string) error {
+	for _, flag := range flags {
+		if !strings.HasPrefix(flag, "--") || len(flag) == 2 {
+			return fmt.Errorf("%s entry %q must be a flag starting with --, e.g. --v=4", name, flag)
 		}
-		if !controllerInstanceCidrExists {
-			return fmt.Errorf("No instanceCIDRs in Subnets (%v) contain controllerIP (%s)",
-				instanceCIDRs,
-				c.ControllerIP,
-			)
+		if strings.ContainsAny(flag, "\n\r") {
+			return fmt.Errorf("%s entry %q must not contain a line break", name, flag)
 		}
+	}
+	return nil
+}
 
-		for i, a := range instanceCIDRs {
-			for j, b := range instanceCIDRs[i+1:] {
-				if i > 0 && cidrOverlap(a, b) {
-					return fmt.Errorf("CIDR of subnet %d (%s) overlaps with CIDR of subnet %d (%s)", i, a, j, b)
-				}
-			}
+func (s IAMPolicyStatement) valid() error {
+	if s.Effect != "Allow" && s.Effect != "Deny" {
+		return fmt.Errorf("effect must be Allow or Deny, got %q", s.Effect)
+	}
+	if len(s.Action) == 0 {
+		return errors.New("action must list at least one action")
+	}
+	for _, action := range s.Action {
+		if !iamActionRegexp.MatchString(action) {
+			return fmt.Errorf("action %q is not an IAM action such as ecr:GetAuthorizationToken", action)
 		}
 	}
+	if len(s.Resource) == 0 {
+		return errors.New("resource must list at least one resource")
+	}
+	for _, resource := range s.Resource {
+		if resource != "*" && !strings.HasPrefix(resource, "arn:") {
+			return fmt.Errorf("resource %q must be * or an ARN", resource)
+		}
+	}
+	return nil
+}
 
-	_, podNet, err := net.ParseCIDR(c.PodCIDR)
-	if err != nil {
-		return fmt.Errorf("invalid podCIDR: %v", err)
+func (f CustomFile) valid() error {
+	if !path.IsAbs(f.Path) {
+		return fmt.Errorf("path must be absolute, got %q", f.Path)
 	}
+	if f.Permissions > 07777 {
+		return fmt.Errorf("permissions must be an octal file mode such as 0644, got %o", f.Permissions)
+	}
+	return nil
+}
 
-	_, serviceNet, err := net.ParseCIDR(c.ServiceCIDR)
-	if err != nil {
-		return fmt.Errorf("invalid serviceCIDR: %v", err)
+func (u CustomSystemdUnit) valid() error {
+	if !strings.HasSuffix(u.Name, ".service") && !strings.HasSuffix(u.Name, ".timer") {
+		return fmt.Errorf("name must end in .service or .timer, got %q", u.Name)
 	}
-	if cidrOverlap(serviceNet, vpcNet) {
-		return fmt.Errorf("vpcCIDR (%s) overlaps with serviceCIDR (%s)", c.VPCCIDR, c.ServiceCIDR)
+	if strings.ContainsAny(u.Name, "/ ") {
+		return fmt.Errorf("name must be a unit file name, got %q", u.Name)
 	}
-	if cidrOverlap(podNet, vpcNet) {
-		return fmt.Errorf("vpcCIDR (%s) overlaps with podCIDR (%s)", c.VPCCIDR, c.PodCIDR)
+	if !systemdUnitCommands[u.Command] {
+		return fmt.Errorf("command must be start, stop or restart, got %q", u.Command)
 	}
-	if cidrOverlap(serviceNet, podNet) {
-		return fmt.Errorf("serviceCIDR (%s) overlaps with podCIDR (%s)", c.ServiceCIDR, c.PodCIDR)
+	if u.Content == "" && u.Command == "" && !u.Enable {
+		return errors.New("content, command or enable must be set")
 	}
+	return nil
+}
 
-	kubernetesServiceIPAddr := incrementIP(serviceNet.IP)
-	if !serviceNet.Contains(kubernetesServiceIPAddr) {
-		return fmt.Errorf("serviceCIDR (%s) does not contain kubernetesServiceIP (%s)", c.ServiceCIDR, kubernetesServiceIPAddr)
+func validateRootVolume(role string, size int, volumeType string, iops int) error {
+	if size < 1 {
+		return fmt.Errorf("%sRootVolumeSize must be at least 1 GiB, got %d", role, size)
 	}
 
-	dnsServiceIPAddr := net.ParseIP(c.DNSServiceIP)
-	if dnsServiceIPAddr == nil {
-		return fmt.Errorf("Invalid dnsServiceIP: %s", c.DNSServiceIP)
-	}
-	if !serviceNet.Contains(dnsServiceIPAddr) {
-		return fmt.Errorf("serviceCIDR (%s) does not contain dnsServiceIP (%s)", c.ServiceCIDR, c.DNSServiceIP)
+	switch volumeType {
+	case "gp2", "standard":
+		if iops != 0 {
+			return fmt.Errorf(
+				"%sRootVolumeIOPS can only be set when %sRootVolumeType is io1, not %s",
+				role,
+				role,
+				volumeType,
+			)
+		}
+	case "io1":
+		if iops < minRootVolumeIOPS || iops > maxRootVolumeIOPS {
+			return fmt.Errorf(
+				"%sRootVolumeIOPS must be between %d and %d when %sRootVolumeType is io1, got %d",
+				role,
+				minRootVolumeIOPS,
+				maxRootVolumeIOPS,
+				role,
+				iops,
+			)
+		}
+		if size < minRootVolumeSizeForIOPS {
+			return fmt.Errorf("%sRootVolumeSize must be at least %d GiB for io1 volumes, got %d",
+				role,
+				minRootVolumeSizeForIOPS,
+				size,
+			)
+		}
+		if iops > size*maxRootVolumeIOPSPerGiB {
+			return fmt.Errorf(
+				"%sRootVolumeIOPS (%d) exceeds the maximum of %d IOPS per GiB for a %d GiB io1 volume (max %d)",
+				role,
+				iops,
+				maxRootVolumeIOPSPerGiB,
+				size,
+				size*maxRootVolumeIOPSPerGiB,
+			)
+		}
+	default:
+		return fmt.Errorf("%sRootVolumeType must be one of gp2, io1 or standard, got %q", role, volumeType)
 	}
 
-	if dnsServiceIPAddr.Equal(kubernetesServiceIPAddr) {
-		return fmt.Errorf("dnsServiceIp conflicts with kubernetesServiceIp (%s)", dnsServiceIPAddr)
-	}
+	return nil
+}
 
+// ValidateFlannelRouteTable checks that an existing route table of the nodes,
+// which already holds existingRoutes routes, has room for the routes the
+// aws-vpc flannel backend adds
+func (c *Cluster) ValidateFlannelRouteTable(routeTableID string, existingRoutes int) error {
+	if routes := c.FlannelRoutes(); existingRoutes+routes > maxRoutesPerRouteTable {
+		return fmt.Errorf(
+			"route table %s has %d routes and flannelBackend %s adds up to %d more, one per node, exceeding the limit of %d routes per route table",
+			routeTableID,
+			existingRoutes,
+			c.FlannelBackend,
+			routes,
+			maxRoutesPerRouteTable,
+		)
+	}
 	return nil
 }
 
@@ -564,9 +3177,12 @@ func (c *Cluster) ValidateExistingVPC(existingVPCCIDR string, existingSubnetCIDR
 			)
 		}
 	}
-	_, instanceNet, err := net.ParseCIDR(c.InstanceCIDR)
-	if err != nil {
-		return fmt.Errorf("error parsing instances cidr %s : %v", c.InstanceCIDR, err)
+	instanceNets := make([]*net.IPNet, len(c.Subnets))
+	for i, subnet := range c.Subnets {
+		_, instanceNets[i], err = net.ParseCIDR(subnet.InstanceCIDR)
+		if err != nil {
+			return fmt.Errorf("error parsing instances cidr %s : %v", subnet.InstanceCIDR, err)
+		}
 	}
 	_, vpcNet, err := net.ParseCIDR(c.VPCCIDR)
 	if err != nil {
@@ -584,12 +3200,14 @@ func (c *Cluster) ValidateExistingVPC(existingVPCCIDR string, existingSubnetCIDR
 
 	//Loop through all existing subnets in the VPC and look for conflicting CIDRS
 	for _, existingSubnet := range existingSubnets {
-		if cidrOverlap(instanceNet, existingSubnet) {
-			return fmt.Errorf(
-				"instance cidr (%s) conflicts with existing subnet cidr=%s",
-				instanceNet,
-				existingSubnet,
-			)
+		for _, instanceNet := range instanceNets {
+			if cidrOverlap(instanceNet, existingSubnet) {
+				return fmt.Errorf(
+					"instance cidr (%s) conflicts with existing subnet cidr=%s",
+					instanceNet,
+					existingSubnet,
+				)
+			}
 		}
 	}
 
@@ -611,6 +3229,129 @@ func incrementIP(netIP net.IP) net.IP {
 	return ip
 }
 
+//Return previous IP address in network range
+func decrementIP(netIP net.IP) net.IP {
+	ip := make(net.IP, len(netIP))
+	copy(ip, netIP)
+
+	for j := len(ip) - 1; j >= 0; j-- {
+		ip[j]--
+		if ip[j] < 255 {
+			break
+		}
+	}
+
+	return ip
+}
+
+//AWS reserves the first four and the last address of every subnet. Returns
+//what ip is reserved for, or an empty string if it is a usable host address
+func reservedSubnetAddress(subnet *net.IPNet, ip net.IP) string {
+	addr := subnet.IP
+	for _, reserved := range []string{
+		"the network address",
+		"the VPC router address reserved by AWS",
+		"the DNS server address reserved by AWS",
+		"an address reserved by AWS for future use",
+	} {
+		if addr.Equal(ip) {
+			return reserved
+		}
+		addr = incrementIP(addr)
+	}
+
+	broadcast := make(net.IP, len(subnet.IP))
+	for i := range subnet.IP {
+		broadcast[i] = subnet.IP[i] | ^subnet.Mask[i]
+	}
+	if broadcast.Equal(ip) {
+		return "the broadcast address"
+	}
+	return ""
+}
+
+//Number of addresses in subnet that can be assigned to instances
+func usableHostCount(subnet *net.IPNet) int {
+	ones, bits := subnet.Mask.Size()
+	usable := (1 << uint(bits-ones)) - 5
+	if usable < 0 {
+		return 0
+	}
+	return usable
+}
+
+//Number of addresses in subnet, usable or not
+func addressCount(subnet *net.IPNet) uint64 {
+	ones, bits := subnet.Mask.Size()
+	return 1 << uint(bits-ones)
+}
+
+// validateVPCSize checks that vpcCIDR has room for every subnet the stack
+// creates, so that an undersized VPC is reported as such rather than by
+// whichever subnet doesn't fit. CIDRs that don't parse are left to the
+// checks of the individual subnets.
+func (c Cluster) validateVPCSize(vpcNet *net.IPNet) error {
+	type subnetCIDR struct{ field, cidr string }
+	var subnets []subnetCIDR
+	if len(c.Subnets) == 0 {
+		subnets = append(subnets, subnetCIDR{"instanceCIDR", c.InstanceCIDR})
+		if c.NATMode != "" {
+			subnets = append(subnets, subnetCIDR{"publicCIDR", c.PublicCIDR})
+		}
+	}
+	for i, subnet := range c.Subnets {
+		subnets = append(subnets, subnetCIDR{fmt.Sprintf("instanceCIDR of subnet #%d", i), subnet.InstanceCIDR})
+		if c.NATMode != "" {
+			subnets = append(subnets, subnetCIDR{fmt.Sprintf("publicCIDR of subnet #%d", i), subnet.PublicCIDR})
+		}
+	}
+
+	var required uint64
+	var requested []string
+	for _, subnet := range subnets {
+		_, subnetNet, err := net.ParseCIDR(subnet.cidr)
+		if err != nil {
+			continue
+		}
+		required += addressCount(subnetNet)
+		requested = append(requested, fmt.Sprintf("%s %s (%d)", subnet.field, subnetNet, addressCount(subnetNet)))
+	}
+
+	if available := addressCount(vpcNet); required > available {
+		return fmt.Errorf(
+			"vpcCIDR (%s) has %d addresses but the subnets need %d: %s. use a larger vpcCIDR or smaller subnets",
+			vpcNet,
+			available,
+			required,
+			strings.Join(requested, ", "),
+		)
+	}
+	return nil
+}
+
+//Is the address space of network "inner" entirely within network "outer"?
+func cidrContains(outer, inner *net.IPNet) bool {
+	outerOnes, _ := outer.Mask.Size()
+	innerOnes, _ := inner.Mask.Size()
+	return outer.Contains(inner.IP) && innerOnes >= outerOnes
+}
+
+func validateInstanceCIDRWithinVPC(vpcNet, instanceNet *net.IPNet) error {
+	if cidrContains(vpcNet, instanceNet) {
+		return nil
+	}
+	if cidrOverlap(vpcNet, instanceNet) {
+		return fmt.Errorf("instanceCIDR (%s) is larger than vpcCIDR (%s) and extends beyond it",
+			instanceNet,
+			vpcNet,
+		)
+	}
+	return fmt.Errorf("instanceCIDR (%s) is outside of vpcCIDR (%s)",
+		instanceNet,
+		vpcNet,
+	)
+}
+
 //Does the address space of these networks "a" and "b" overlap?
 func cidrOverlap(a, b *net.IPNet) bool {
 	return a.Contains(b.IP) || b.Contains(a.IP)
@@ -627,6 +3368,48 @@ func WithTrailingDot(s string) string {
 	return s
 }
 
+// Kubernetes label names and values: at most 63 alphanumerics, '-', '_' or '.',
+// beginning and ending with an alphanumeric
+var labelNameRegexp = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+
+// Label key prefixes are DNS subdomains
+var labelPrefixRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+const (
+	maxLabelNameLength   = 63
+	maxLabelPrefixLength = 253
+)
+
+// validateLabel checks a label against the Kubernetes label syntax: the key
+// is an optional DNS subdomain prefix and '/' followed by a name, the value
+// is empty or follows the same rules as the name.
+func validateLabel(key, value string) error {
+	name := key
+	if i := strings.Index(key, "/"); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+		if len(prefix) > maxLabelPrefixLength || !labelPrefixRegexp.MatchString(prefix) {
+			return fmt.Errorf("label key %q must have a DNS subdomain of at most %d characters as prefix", key, maxLabelPrefixLength)
+		}
+	}
+	if len(name) > maxLabelNameLength || !labelNameRegexp.MatchString(name) {
+		return fmt.Errorf(
+			"label key %q must have a name of at most %d alphanumerics, '-', '_' or '.', beginning and ending with an alphanumeric",
+			key,
+			maxLabelNameLength,
+		)
+	}
+	if value != "" && (len(value) > maxLabelNameLength || !labelNameRegexp.MatchString(value)) {
+		return fmt.Errorf(
+			"label value %q for key %q must be at most %d alphanumerics, '-', '_' or '.', beginning and ending with an alphanumeric",
+			value,
+			key,
+			maxLabelNameLength,
+		)
+	}
+	return nil
+}
+
 func isSubdomain(sub, parent string) bool {
 	sub, parent = WithTrailingDot(sub), WithTrailingDot(parent)
 	subParts, parentParts := strings.Split(sub, "."), strings.Split(parent, ".")
@@ -1,8 +1,18 @@
 package config
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -41,15 +51,47 @@ routeTableId: rtb-xxxxxx
 `, `
 vpcId: vpc-xxxxx
 `, `
+vpcId: vpc-xxxxx
+controllerSecurityGroupIds:
+  - sg-12345678
+workerSecurityGroupIds:
+  - sg-12345678
+  - sg-9abcdef0
+`, `
 createRecordSet: false
 hostedZone: ""
 `, `
 createRecordSet: true
 recordSetTTL: 400
 hostedZone: core-os.net
+hostedZoneId: Z1D633PJN98FT9
+`, `
+createRecordSet: true
+recordSetTTL: 604800
+hostedZone: core-os.net
+hostedZoneId: Z1D633PJN98FT9
+`, `
+# recordSetTTL is ignored with a warning when createRecordSet is false
+createRecordSet: false
+recordSetTTL: 400
+`, `
+createRecordSet: false
+recordSetTTL: 0
+`, `
+createRecordSet: true
+hostedZone: "staging.core-os.net"
+hostedZoneId: Z1D633PJN98FT9
+`, `
+createRecordSet: true
+recordSetAlias: true
+hostedZone: "staging.core-os.net"
+hostedZoneId: Z1D633PJN98FT9
 `, `
+vpcId: vpc-xxxxx
 createRecordSet: true
 hostedZone: "staging.core-os.net"
+hostedZoneId: Z1D633PJN98FT9
+hostedZonePrivate: true
 `,
 }
 
@@ -99,22 +141,100 @@ podCIDR: 172.4.0.0/16
 serviceCIDR: 172.5.0.0/16
 dnsServiceIP: 172.6.100.101 #dnsServiceIP not in service CIDR
 `, `
+vpcCIDR: 10.5.0.0/16
+instanceCIDR: 10.6.0.0/24 #instanceCIDR outside of vpcCIDR
+controllerIP: 10.6.0.5
+podCIDR: 10.7.0.0/16
+serviceCIDR: 10.8.0.0/16
+dnsServiceIP: 10.8.100.101
+`, `
+vpcCIDR: 10.4.0.0/16
+instanceCIDR: 10.4.0.0/15 #instanceCIDR extends beyond vpcCIDR
+controllerIP: 10.4.3.5
+podCIDR: 10.6.0.0/16
+serviceCIDR: 10.7.0.0/16
+dnsServiceIP: 10.7.100.101
+`, `
+vpcCIDR: 10.4.0.0/16
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.5
+podCIDR: 10.4.3.0/25 #podCIDR overlaps with instanceCIDR
+serviceCIDR: 10.5.0.0/16
+dnsServiceIP: 10.5.100.101
+`, `
+vpcCIDR: 10.4.0.0/16
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.5
+podCIDR: 10.6.0.0/16
+serviceCIDR: 10.4.0.0/20 #serviceCIDR overlaps with instanceCIDR
+dnsServiceIP: 10.4.0.10
+`, `
+vpcCIDR: 10.4.0.0/16
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.5
+podCIDR: 10.6.0.0/16
+serviceCIDR: 10.6.128.0/24 #serviceCIDR overlaps with podCIDR
+dnsServiceIP: 10.6.128.10
+`, `
+serviceCIDR: 10.3.0.0/24
+dnsServiceIP: 10.3.0.0 #dnsServiceIP is the network address of serviceCIDR
+`, `
+dnsServiceIP: not-an-ip
+`, `
+# security groups specified without vpcId
+workerSecurityGroupIds:
+  - sg-12345678
+`, `
+vpcId: vpc-xxxxx
+controllerSecurityGroupIds:
+  - my-security-group # not a security group id
+`, `
+# hostedZonePrivate specified without vpcId
+createRecordSet: true
+hostedZone: staging.core-os.net
+hostedZoneId: Z1D633PJN98FT9
+hostedZonePrivate: true
+`, `
 routeTableId: rtb-xxxxxx # routeTableId specified without vpcId
 `, `
 # invalid TTL
+createRecordSet: true
 recordSetTTL: 0
+hostedZone: staging.core-os.net
+hostedZoneId: Z1D633PJN98FT9
 `, `
 # hostedZone shouldn't be blank when createRecordSet is true
 createRecordSet: true
 hostedZone: ""
 `, `
-# recordSetTTL shouldn't be modified when createRecordSet is false
-createRecordSet: false
-recordSetTTL: 400
+# the record is created in the zone with hostedZoneId
+createRecordSet: true
+hostedZone: staging.core-os.net
+`, `
+createRecordSet: true
+hostedZone: staging.core-os.net
+hostedZoneId: staging_id # not a zone id
+`, `
+# TTLs over a week are too long to follow a replaced load balancer
+createRecordSet: true
+recordSetTTL: 604801
+hostedZone: staging.core-os.net
+hostedZoneId: Z1D633PJN98FT9
+`, `
+# recordSetTTL has no effect on alias records
+createRecordSet: true
+recordSetAlias: true
+recordSetTTL: 60
+hostedZone: "staging.core-os.net"
+hostedZoneId: Z1D633PJN98FT9
+`, `
+# recordSetAlias shouldn't be set when createRecordSet is false
+recordSetAlias: true
 `, `
 # whatever.com is not a superdomain of test.staging.core-os.net
 createRecordSet: true
 hostedZone: "whatever.com"
+hostedZoneId: Z1D633PJN98FT9
 `,
 }
 
@@ -440,11 +560,37 @@ subnets:
 `,
 		`
 subnets:
+# instanceCIDR not contained by the default vpcCIDR
+- availabilityZone: "ap-northeast-1a"
+  instanceCIDR: 10.0.0.0/24
+- availabilityZone: "ap-northeast-1b"
+  instanceCIDR: 10.1.0.0/24
+`,
+		`
+subnets:
 # Overlapping subnets
 - availabilityZone: "ap-northeast-1a"
   instanceCIDR: 10.0.5.0/24
 - availabilityZone: "ap-northeast-1b"
   instanceCIDR: 10.0.5.0/24
+`,
+		`
+subnets:
+# Overlapping subnets, the first of which contains the default controllerIP
+- availabilityZone: "ap-northeast-1a"
+  instanceCIDR: 10.0.0.0/24
+- availabilityZone: "ap-northeast-1b"
+  instanceCIDR: 10.0.0.128/25
+`,
+		`
+subnets:
+# Overlapping subnets beyond the first one
+- availabilityZone: "ap-northeast-1a"
+  instanceCIDR: 10.0.0.0/24
+- availabilityZone: "ap-northeast-1b"
+  instanceCIDR: 10.0.1.0/24
+- availabilityZone: "ap-northeast-1c"
+  instanceCIDR: 10.0.1.0/25
 `,
 	}
 
@@ -474,3 +620,4045 @@ subnets:
 	}
 
 }
+
+func TestWorkerSizing(t *testing.T) {
+	validConfigs := []struct {
+		conf         string
+		count        int
+		instanceType string
+	}{
+		{
+			conf:         ``,
+			count:        1,
+			instanceType: "m3.medium",
+		},
+		{
+			conf: `
+workerCount: 5
+workerInstanceType: c4.2xlarge
+`,
+			count:        5,
+			instanceType: "c4.2xlarge",
+		},
+	}
+
+	invalidConfigs := []string{
+		`
+workerCount: 0
+`,
+		`
+workerCount: -1
+`,
+		`
+workerInstanceType: medium
+`,
+		`
+workerInstanceType: M3.Medium
+`,
+		`
+controllerInstanceType: ""
+`,
+	}
+
+	for _, conf := range validConfigs {
+		confBody := singleAzConfigYaml + conf.conf
+		c, err := ClusterFromBytes([]byte(confBody))
+		if err != nil {
+			t.Errorf("failed to parse config %s: %v", confBody, err)
+			continue
+		}
+		if c.WorkerCount != conf.count {
+			t.Errorf("parsed workerCount %d does not match expected %d", c.WorkerCount, conf.count)
+		}
+		if c.WorkerInstanceType != conf.instanceType {
+			t.Errorf("parsed workerInstanceType %s does not match expected %s", c.WorkerInstanceType, conf.instanceType)
+		}
+	}
+
+	for _, conf := range invalidConfigs {
+		confBody := singleAzConfigYaml + conf
+		_, err := ClusterFromBytes([]byte(confBody))
+		if err == nil {
+			t.Errorf("expected error parsing invalid config: %s", confBody)
+		}
+	}
+}
+
+func TestWorkerSpotPrice(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+workerSpotPrice: "0.05"
+`,
+		`
+workerSpotPrice: "1"
+`,
+	}
+
+	invalidConfigs := []string{
+		`
+workerSpotPrice: "0"
+`,
+		`
+workerSpotPrice: "-0.05"
+`,
+		`
+workerSpotPrice: "five cents"
+`,
+		`
+# the controller must be on-demand
+controllerSpotPrice: "0.05"
+`,
+	}
+
+	for _, conf := range validConfigs {
+		confBody := singleAzConfigYaml + conf
+		if _, err := ClusterFromBytes([]byte(confBody)); err != nil {
+			t.Errorf("failed to parse config %s: %v", confBody, err)
+		}
+	}
+
+	for _, conf := range invalidConfigs {
+		confBody := singleAzConfigYaml + conf
+		if _, err := ClusterFromBytes([]byte(confBody)); err == nil {
+			t.Errorf("expected error parsing invalid config: %s", confBody)
+		}
+	}
+}
+
+func TestRootVolumes(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+controllerRootVolumeSize: 50
+controllerRootVolumeType: gp2
+workerRootVolumeSize: 100
+workerRootVolumeType: gp2
+`,
+		`
+workerRootVolumeSize: 100
+workerRootVolumeType: io1
+workerRootVolumeIOPS: 5000
+`,
+	}
+
+	invalidConfigs := []string{
+		`
+# unknown volume type
+workerRootVolumeType: sc1
+`,
+		`
+# io1 requires iops
+controllerRootVolumeType: io1
+`,
+		`
+# iops only apply to io1
+workerRootVolumeType: gp2
+workerRootVolumeIOPS: 1000
+`,
+		`
+# iops exceed 50 per GiB
+workerRootVolumeSize: 30
+workerRootVolumeType: io1
+workerRootVolumeIOPS: 2000
+`,
+		`
+workerRootVolumeSize: 0
+`,
+	}
+
+	for _, conf := range validConfigs {
+		confBody := singleAzConfigYaml + conf
+		if _, err := ClusterFromBytes([]byte(confBody)); err != nil {
+			t.Errorf("failed to parse config %s: %v", confBody, err)
+		}
+	}
+
+	for _, conf := range invalidConfigs {
+		confBody := singleAzConfigYaml + conf
+		if _, err := ClusterFromBytes([]byte(confBody)); err == nil {
+			t.Errorf("expected error parsing invalid config: %s", confBody)
+		}
+	}
+}
+
+func TestAmiId(t *testing.T) {
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+amiId: ami-0123abcd
+`))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	// An amiId skips AMI lookup entirely
+	cfg, err := c.Config()
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	if cfg.AMI != "ami-0123abcd" {
+		t.Errorf("expected AMI ami-0123abcd, got %s", cfg.AMI)
+	}
+
+	if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+amiId: my-favorite-image
+`)); err == nil {
+		t.Errorf("expected error parsing invalid amiId")
+	}
+}
+
+func TestKubernetesVersion(t *testing.T) {
+	validVersions := []string{
+		"v1.2.4_coreos.1",
+		"v1.2.4_coreos.cni.1",
+		"v1.5.2_coreos.0",
+		"v1.3.0-beta.1_coreos.0",
+		"v1.4.0",
+	}
+	for _, version := range validVersions {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + "\nkubernetesVersion: " + version))
+		if err != nil {
+			t.Errorf("failed to parse valid kubernetesVersion %s: %v", version, err)
+			continue
+		}
+		if c.K8sVer != version {
+			t.Errorf("expected kubernetesVersion %s, got %s", version, c.K8sVer)
+		}
+	}
+
+	invalidVersions := []string{
+		"1.2.4_coreos.1",
+		"v1.2_coreos.1",
+		"v1.2.4_coreos",
+		"latest",
+		"\"\"",
+	}
+	for _, version := range invalidVersions {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "\nkubernetesVersion: " + version)); err == nil {
+			t.Errorf("expected error parsing invalid kubernetesVersion %s", version)
+		}
+	}
+}
+
+func TestDNSServiceIPSuggestion(t *testing.T) {
+	_, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+serviceCIDR: 10.3.0.0/24
+dnsServiceIP: 10.4.0.10
+`))
+	if err == nil {
+		t.Fatalf("expected error for dnsServiceIP outside of serviceCIDR")
+	}
+	if !strings.Contains(err.Error(), "10.3.0.10") {
+		t.Errorf("expected error to suggest 10.3.0.10, got: %v", err)
+	}
+}
+
+func TestAPIELBIdleTimeout(t *testing.T) {
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if c.APIELBIdleTimeout != 1800 {
+		t.Errorf("expected default apiELBIdleTimeout of 1800, got %d", c.APIELBIdleTimeout)
+	}
+
+	for _, timeout := range []string{"1", "60", "3600"} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "\napiELBIdleTimeout: " + timeout)); err != nil {
+			t.Errorf("failed to parse valid apiELBIdleTimeout %s: %v", timeout, err)
+		}
+	}
+
+	for _, timeout := range []string{"0", "-5", "3601"} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "\napiELBIdleTimeout: " + timeout)); err == nil {
+			t.Errorf("expected error parsing invalid apiELBIdleTimeout %s", timeout)
+		}
+	}
+}
+
+func TestStackCreationTimeout(t *testing.T) {
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if c.StackCreationTimeout != 60 {
+		t.Errorf("expected default stackCreationTimeout of 60, got %d", c.StackCreationTimeout)
+	}
+
+	for _, timeout := range []string{"1", "30", "180"} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "\nstackCreationTimeout: " + timeout)); err != nil {
+			t.Errorf("failed to parse valid stackCreationTimeout %s: %v", timeout, err)
+		}
+	}
+
+	for _, timeout := range []string{"0", "-10"} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "\nstackCreationTimeout: " + timeout)); err == nil {
+			t.Errorf("expected error parsing invalid stackCreationTimeout %s", timeout)
+		}
+	}
+}
+
+func TestExtraWorkerSecurityGroupRules(t *testing.T) {
+	validConfigs := []struct {
+		conf  string
+		rules []SecurityGroupRule
+	}{
+		{
+			conf:  ``,
+			rules: nil,
+		},
+		{
+			conf: `
+extraWorkerSecurityGroupRules:
+  - protocol: tcp
+    fromPort: 30000
+    toPort: 32767
+    cidr: 10.0.0.0/8
+  - protocol: icmp
+    fromPort: -1
+    toPort: -1
+    cidr: 192.168.0.0/16
+`,
+			rules: []SecurityGroupRule{
+				{Protocol: "tcp", FromPort: 30000, ToPort: 32767, CIDR: "10.0.0.0/8"},
+				{Protocol: "icmp", FromPort: -1, ToPort: -1, CIDR: "192.168.0.0/16"},
+			},
+		},
+	}
+
+	invalidConfigs := []string{
+		`
+extraWorkerSecurityGroupRules:
+  - protocol: tcp
+    fromPort: 9100
+    toPort: 9000 # toPort less than fromPort
+    cidr: 10.0.0.0/8
+`,
+		`
+extraWorkerSecurityGroupRules:
+  - protocol: tcp
+    fromPort: 9100
+    toPort: 70000 # port out of range
+    cidr: 10.0.0.0/8
+`,
+		`
+extraWorkerSecurityGroupRules:
+  - protocol: sctp # unsupported protocol
+    fromPort: 9100
+    toPort: 9100
+    cidr: 10.0.0.0/8
+`,
+		`
+extraWorkerSecurityGroupRules:
+  - protocol: udp
+    fromPort: 9100
+    toPort: 9100
+    cidr: 10.0.0.300/8 # invalid cidr
+`,
+	}
+
+	for _, validConfig := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + validConfig.conf))
+		if err != nil {
+			t.Errorf("failed to parse config %s: %v", validConfig.conf, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.ExtraWorkerSecurityGroupRules, validConfig.rules) {
+			t.Errorf("extraWorkerSecurityGroupRules %+v does not match expected %+v",
+				c.ExtraWorkerSecurityGroupRules, validConfig.rules)
+		}
+	}
+
+	for _, invalidConfig := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + invalidConfig)); err == nil {
+			t.Errorf("expected error parsing invalid config: %s", invalidConfig)
+		}
+	}
+}
+
+func TestInstanceTagLimit(t *testing.T) {
+	stackTags := func(n int) string {
+		conf := "\nstackTags:\n"
+		for i := 0; i < n; i++ {
+			conf += fmt.Sprintf("  Key%d: Value%d\n", i, i)
+		}
+		return conf
+	}
+
+	if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + stackTags(48))); err != nil {
+		t.Errorf("failed to parse config with 48 stackTags: %v", err)
+	}
+
+	if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + stackTags(49))); err == nil {
+		t.Errorf("expected error parsing config with 49 stackTags, which exceeds the instance tag limit")
+	}
+}
+
+func TestStackTagsValidation(t *testing.T) {
+	validConfig := `
+stackTags:
+  Name: Kubernetes
+  Environment: Production
+  awsome: true
+`
+	if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + validConfig)); err != nil {
+		t.Errorf("failed to parse valid stackTags: %v", err)
+	}
+
+	longKey := strings.Repeat("k", 129)
+	longValue := strings.Repeat("v", 257)
+	invalidConfig := fmt.Sprintf(`
+stackTags:
+  "aws:cloudformation:stack-name": mine
+  %s: value
+  LongValue: %s
+  Fine: fine
+`, longKey, longValue)
+
+	_, err := ClusterFromBytes([]byte(singleAzConfigYaml + invalidConfig))
+	if err == nil {
+		t.Fatalf("expected error parsing invalid stackTags")
+	}
+	// Every offending tag is reported, not just the first
+	for _, offender := range []string{"aws:cloudformation:stack-name", longKey, "LongValue"} {
+		if !strings.Contains(err.Error(), offender) {
+			t.Errorf("expected error to mention tag %s, got: %v", offender, err)
+		}
+	}
+	if strings.Contains(err.Error(), "Fine") {
+		t.Errorf("error mentions valid tag Fine: %v", err)
+	}
+
+	tooMany := "\nstackTags:\n"
+	for i := 0; i < 51; i++ {
+		tooMany += fmt.Sprintf("  Key%d: Value%d\n", i, i)
+	}
+	if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + tooMany)); err == nil {
+		t.Errorf("expected error parsing more than 50 stackTags")
+	}
+}
+
+func TestLaunchTagSpecifications(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	type tag struct {
+		Key   string
+		Value string
+	}
+	var stack struct {
+		Resources map[string]struct {
+			Properties struct {
+				LaunchTemplate struct {
+					LaunchTemplateId map[string]string
+				}
+				LaunchTemplateData struct {
+					TagSpecifications []struct {
+						ResourceType string
+						Tags         []tag
+					}
+				}
+				Tags []tag
+			}
+		}
+	}
+
+	r.renderStack(`
+natMode: instance
+publicCIDR: 10.0.128.0/24
+useLaunchTemplate: true
+stackTags:
+  Team: 'the "kube" team'
+`, &stack)
+
+	expected := []tag{
+		{"KubernetesCluster", "test-cluster-name"},
+		{"Team", `the "kube" team`},
+	}
+	for _, name := range []string{"InstanceController", "InstanceEtcd", "InstanceNAT"} {
+		if ref := stack.Resources[name].Properties.LaunchTemplate.LaunchTemplateId["Ref"]; ref != "LaunchTemplateInstance" {
+			t.Errorf("expected %s to be launched from LaunchTemplateInstance, got %q", name, ref)
+		}
+		if tags := stack.Resources[name].Properties.Tags; !reflect.DeepEqual(tags[:2], expected) {
+			t.Errorf("expected %s to be tagged %v, got %v", name, expected, tags)
+		}
+	}
+	for name, nameTag := range map[string]string{
+		"LaunchTemplateInstance": "test-cluster-name-kube-aws",
+		"LaunchTemplateWorker":   "test-cluster-name-kube-aws-worker",
+	} {
+		specifications := stack.Resources[name].Properties.LaunchTemplateData.TagSpecifications
+		resourceTypes := []string{}
+		for _, specification := range specifications {
+			resourceTypes = append(resourceTypes, specification.ResourceType)
+			if tags := append(expected, tag{"Name", nameTag}); !reflect.DeepEqual(specification.Tags, tags) {
+				t.Errorf("expected %s to tag %s with %v, got %v", name, specification.ResourceType, tags, specification.Tags)
+			}
+		}
+		if expected := []string{"network-interface", "volume"}; !reflect.DeepEqual(resourceTypes, expected) {
+			t.Errorf("expected %s to tag %v, got %v", name, expected, resourceTypes)
+		}
+	}
+}
+
+func TestWorkerNodeLabels(t *testing.T) {
+	validConfigs := []struct {
+		conf   string
+		labels string
+	}{
+		{
+			conf:   ``,
+			labels: "",
+		},
+		{
+			conf: `
+workerNodeLabels:
+  role: batch
+  example.com/team: data-eng
+  empty: ""
+`,
+			labels: "empty=,example.com/team=data-eng,role=batch",
+		},
+	}
+
+	invalidConfigs := []string{
+		`
+workerNodeLabels:
+  -role: batch # must begin with an alphanumeric
+`,
+		`
+workerNodeLabels:
+  role: batch! # invalid character in value
+`,
+		`
+workerNodeLabels:
+  Example.com/team: data # prefix must be lower case
+`,
+		`
+workerNodeLabels:
+  example.com/: data # empty name
+`,
+		`
+workerNodeLabels:
+  role: aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa # value longer than 63 characters
+`,
+	}
+
+	for _, validConfig := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + validConfig.conf))
+		if err != nil {
+			t.Errorf("failed to parse config %s: %v", validConfig.conf, err)
+			continue
+		}
+		if labels := c.WorkerNodeLabelsString(); labels != validConfig.labels {
+			t.Errorf("expected node labels %q, got %q", validConfig.labels, labels)
+		}
+	}
+
+	for _, invalidConfig := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + invalidConfig)); err == nil {
+			t.Errorf("expected error parsing invalid config: %s", invalidConfig)
+		}
+	}
+}
+
+func TestWorkerNodeTaints(t *testing.T) {
+	validConfigs := []struct {
+		conf   string
+		taints string
+	}{
+		{
+			conf:   ``,
+			taints: "",
+		},
+		{
+			conf: `
+workerNodeTaints: []
+`,
+			taints: "",
+		},
+		{
+			conf: `
+workerNodeTaints:
+  - key: dedicated
+    value: gpu
+    effect: NoSchedule
+  - key: example.com/batch
+    effect: PreferNoSchedule
+  - key: evict
+    value: "true"
+    effect: NoExecute
+`,
+			taints: "dedicated=gpu:NoSchedule,example.com/batch=:PreferNoSchedule,evict=true:NoExecute",
+		},
+	}
+
+	invalidConfigs := []string{
+		`
+workerNodeTaints:
+  - key: dedicated
+    value: gpu
+    effect: NoScheduling # unknown effect
+`,
+		`
+workerNodeTaints:
+  - key: dedicated
+    value: gpu # missing effect
+`,
+		`
+workerNodeTaints:
+  - value: gpu # missing key
+    effect: NoSchedule
+`,
+		`
+workerNodeTaints:
+  - key: dedicated
+    value: gpu:nvidia # invalid value
+    effect: NoSchedule
+`,
+	}
+
+	for _, validConfig := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + validConfig.conf))
+		if err != nil {
+			t.Errorf("failed to parse config %s: %v", validConfig.conf, err)
+			continue
+		}
+		if taints := c.WorkerNodeTaintsString(); taints != validConfig.taints {
+			t.Errorf("expected node taints %q, got %q", validConfig.taints, taints)
+		}
+	}
+
+	for _, invalidConfig := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + invalidConfig)); err == nil {
+			t.Errorf("expected error parsing invalid config: %s", invalidConfig)
+		}
+	}
+}
+
+func TestWriteKubeConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-aws-kubeconfig")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	tlsAssetsDir := filepath.Join(dir, "credentials")
+	if _, _, err := c.WriteKubeConfig(KubeConfigOptions{TLSAssetsDir: tlsAssetsDir}); err == nil {
+		t.Errorf("expected error writing kubeconfig without TLS assets")
+	}
+
+	if err := os.Mkdir(tlsAssetsDir, 0700); err != nil {
+		t.Fatalf("failed to create credentials dir: %v", err)
+	}
+	if err := genTLSAssets(t).WriteToDir(tlsAssetsDir); err != nil {
+		t.Fatalf("failed to write TLS assets: %v", err)
+	}
+
+	path, warnings, err := c.WriteKubeConfig(KubeConfigOptions{TLSAssetsDir: tlsAssetsDir})
+	if err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("expected no warnings for externalDNSName, got %v", warnings)
+	}
+	if expected := filepath.Join(dir, "kubeconfig"); path != expected {
+		t.Errorf("expected kubeconfig to be written to %s, got %s", expected, path)
+	}
+	kubeconfig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read kubeconfig: %v", err)
+	}
+	for _, expected := range []string{
+		"server: https://" + c.ExternalDNSName + "\n",
+		"certificate-authority: credentials/ca.pem\n",
+		"client-certificate: credentials/admin.pem\n",
+		"client-key: credentials/admin-key.pem\n",
+	} {
+		if !strings.Contains(string(kubeconfig), expected) {
+			t.Errorf("expected kubeconfig to contain %q:\n%s", expected, kubeconfig)
+		}
+	}
+
+	path, _, err = c.WriteKubeConfig(KubeConfigOptions{
+		TLSAssetsDir: tlsAssetsDir,
+		Path:         filepath.Join(dir, "out", "admin.kubeconfig"),
+		APIEndpoint:  "https://test-elb.us-west-1.elb.amazonaws.com",
+	})
+	if err == nil {
+		t.Errorf("expected error writing kubeconfig to a missing directory, wrote %s", path)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "out"), 0700); err != nil {
+		t.Fatalf("failed to create output dir: %v", err)
+	}
+	if path, warnings, err = c.WriteKubeConfig(KubeConfigOptions{
+		TLSAssetsDir: tlsAssetsDir,
+		Path:         filepath.Join(dir, "out", "admin.kubeconfig"),
+		APIEndpoint:  "https://test-elb.us-west-1.elb.amazonaws.com",
+	}); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "not valid for test-elb.us-west-1.elb.amazonaws.com") {
+		t.Errorf("expected a warning that the apiserver certificate is not valid for the endpoint, got %v", warnings)
+	}
+	if kubeconfig, err = ioutil.ReadFile(path); err != nil {
+		t.Fatalf("failed to read kubeconfig: %v", err)
+	}
+	for _, expected := range []string{
+		"server: https://test-elb.us-west-1.elb.amazonaws.com\n",
+		"certificate-authority: ../credentials/ca.pem\n",
+	} {
+		if !strings.Contains(string(kubeconfig), expected) {
+			t.Errorf("expected kubeconfig to contain %q:\n%s", expected, kubeconfig)
+		}
+	}
+}
+
+// testRenderer renders the assets of test clusters from the templates and a
+// set of TLS assets written once to a temp dir. Call cleanup to remove it.
+type testRenderer struct {
+	t    *testing.T
+	dir  string
+	opts StackTemplateOptions
+}
+
+func newTestRenderer(t *testing.T) *testRenderer {
+	dir, err := ioutil.TempDir("", "kube-aws-render")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	r := &testRenderer{
+		t:   t,
+		dir: dir,
+		opts: StackTemplateOptions{
+			TLSAssetsDir:          filepath.Join(dir, "credentials"),
+			ControllerTmplFile:    filepath.Join(dir, "cloud-config-controller"),
+			WorkerTmplFile:        filepath.Join(dir, "cloud-config-worker"),
+			EtcdTmplFile:          filepath.Join(dir, "cloud-config-etcd"),
+			StackTemplateTmplFile: filepath.Join(dir, "stack-template.json"),
+		},
+	}
+	for filename, data := range map[string][]byte{
+		r.opts.ControllerTmplFile:    CloudConfigController,
+		r.opts.WorkerTmplFile:        CloudConfigWorker,
+		r.opts.EtcdTmplFile:          CloudConfigEtcd,
+		r.opts.StackTemplateTmplFile: StackTemplateTemplate,
+	} {
+		if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+			r.cleanup()
+			t.Fatalf("failed to write template: %v", err)
+		}
+	}
+	if err := os.Mkdir(r.opts.TLSAssetsDir, 0700); err != nil {
+		r.cleanup()
+		t.Fatalf("failed to create credentials dir: %v", err)
+	}
+	tlsAssets := genTLSAssets(t)
+	if err := tlsAssets.WriteToDir(r.opts.TLSAssetsDir); err != nil {
+		r.cleanup()
+		t.Fatalf("failed to write TLS assets: %v", err)
+	}
+	return r
+}
+
+func (r *testRenderer) cleanup() {
+	os.RemoveAll(r.dir)
+}
+
+// renderCluster renders the assets of c, failing the test if it can't
+func (r *testRenderer) renderCluster(c *Cluster) *RenderedAssets {
+	assets, err := c.RenderAssets(r.opts)
+	if err != nil {
+		r.t.Fatalf("failed to render assets: %v", err)
+	}
+	return assets
+}
+
+// render renders a single AZ cluster with conf appended to its cluster.yaml.
+// amiId avoids looking up the AMI, so nothing touches the network.
+func (r *testRenderer) render(conf string) *RenderedAssets {
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml + "amiId: ami-0123abcd\n" + conf))
+	if err != nil {
+		r.t.Fatalf("failed to parse config %q: %v", conf, err)
+	}
+	return r.renderCluster(c)
+}
+
+// renderStack renders conf like render and decodes the stack template into stack
+func (r *testRenderer) renderStack(conf string, stack interface{}) *RenderedAssets {
+	assets := r.render(conf)
+	if err := json.Unmarshal(assets.StackTemplate, stack); err != nil {
+		r.t.Fatalf("rendered stack template is not valid json for config %q: %v", conf, err)
+	}
+	return assets
+}
+
+func TestRenderAssets(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	// amiId avoids looking up the AMI, so nothing touches the network
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+amiId: ami-0123abcd
+`))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	assets := r.renderCluster(c)
+
+	var stack struct {
+		Resources map[string]struct {
+			Type       string
+			Properties struct {
+				Tags []struct {
+					Key   string
+					Value interface{}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(assets.StackTemplate, &stack); err != nil {
+		t.Errorf("rendered stack template is not valid json: %v", err)
+	}
+	// The AWS cloud provider only manages ELBs and routes for resources
+	// tagged with the cluster name
+	for name, resource := range stack.Resources {
+		switch resource.Type {
+		case "AWS::EC2::Subnet", "AWS::EC2::SecurityGroup", "AWS::EC2::RouteTable", "AWS::EC2::VPC", "AWS::EC2::InternetGateway":
+		default:
+			continue
+		}
+		tagged := false
+		for _, tag := range resource.Properties.Tags {
+			tagged = tagged || tag.Key == "KubernetesCluster" && tag.Value == c.ClusterName
+		}
+		if !tagged {
+			t.Errorf("%s %s is not tagged KubernetesCluster=%s", resource.Type, name, c.ClusterName)
+		}
+	}
+	if !bytes.Contains(assets.UserDataController, []byte("--service-cluster-ip-range="+c.ServiceCIDR)) {
+		t.Errorf("controller userdata was not rendered")
+	}
+	if !bytes.Contains(assets.UserDataEtcd, []byte("initial-cluster: ")) {
+		t.Errorf("etcd userdata was not rendered")
+	}
+
+	if again := r.renderCluster(c); !reflect.DeepEqual(assets, again) {
+		t.Errorf("rendering the same config twice produced different assets")
+	}
+
+	outputDir := filepath.Join(r.dir, "rendered")
+	if err := assets.WriteToDir(outputDir); err != nil {
+		t.Fatalf("failed to write rendered assets: %v", err)
+	}
+	for _, filename := range []string{
+		"stack-template.json",
+		"userdata/cloud-config-controller",
+		"userdata/cloud-config-worker",
+		"userdata/cloud-config-etcd",
+		"credentials/ca.pem",
+		"credentials/.gitignore",
+	} {
+		if _, err := os.Stat(filepath.Join(outputDir, filename)); err != nil {
+			t.Errorf("expected %s to be written: %v", filename, err)
+		}
+	}
+}
+
+func TestKMSKeyARN(t *testing.T) {
+	clusterYaml := `externalDNSName: test.staging.core-os.net
+keyName: test-key-name
+region: us-west-1
+availabilityZone: us-west-1c
+clusterName: test-cluster-name
+`
+	validARNs := []string{
+		"arn:aws:kms:us-west-1:123456789012:key/12345678-1234-1234-1234-123456789012",
+		"arn:aws:kms:us-west-1:123456789012:alias/kube-aws",
+	}
+	for _, arn := range validARNs {
+		if _, err := ClusterFromBytes([]byte(clusterYaml + "kmsKeyArn: " + arn)); err != nil {
+			t.Errorf("failed to parse valid kmsKeyArn %s: %v", arn, err)
+		}
+	}
+
+	invalidARNs := []string{
+		"12345678-1234-1234-1234-123456789012",                                        // not an ARN
+		"arn:aws:kms:us-east-1:123456789012:key/12345678-1234-1234-1234-123456789012", // another region
+		"arn:aws:iam:us-west-1:123456789012:key/12345678-1234-1234-1234-123456789012", // not KMS
+	}
+	for _, arn := range invalidARNs {
+		if _, err := ClusterFromBytes([]byte(clusterYaml + "kmsKeyArn: " + arn)); err == nil {
+			t.Errorf("expected error parsing invalid kmsKeyArn %s", arn)
+		}
+	}
+}
+
+func TestTLSCertDurationDays(t *testing.T) {
+	validConfigs := []string{
+		"",
+		"tlsCertDurationDays: 1",
+		"tlsCertDurationDays: 730",
+		"tlsCertDurationDays: 3650",
+	}
+	for _, confBody := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + confBody)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", confBody, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		"tlsCertDurationDays: -1",
+		"tlsCertDurationDays: 3651",
+	}
+	for _, confBody := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + confBody)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", confBody)
+		}
+	}
+}
+
+func TestControllerCount(t *testing.T) {
+	validConfigs := []struct {
+		conf          string
+		controllerIPs []string
+	}{
+		{
+			conf:          ``,
+			controllerIPs: []string{"10.0.0.50"},
+		},
+		{
+			conf: `
+controllerCount: 2
+`,
+			controllerIPs: []string{"10.0.0.50", "10.0.0.51"},
+		},
+		{
+			conf: `
+controllerCount: 3
+`,
+			controllerIPs: []string{"10.0.0.50", "10.0.0.51", "10.0.0.52"},
+		},
+		{
+			conf: `
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.252
+controllerCount: 3
+`,
+			controllerIPs: []string{"10.4.3.252", "10.4.3.253", "10.4.3.254"},
+		},
+	}
+	for _, valid := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + valid.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", valid.conf, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.ControllerIPs(), valid.controllerIPs) {
+			t.Errorf("expected controller IPs %v, got %v", valid.controllerIPs, c.ControllerIPs())
+		}
+	}
+
+	c, err := ClusterFromBytes([]byte(minimalConfigYaml + `
+controllerCount: 5
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.0.0.0/24
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.0.1.0/24
+  - availabilityZone: us-west-1c
+    instanceCIDR: 10.0.2.0/24
+`))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if expected := []string{"10.0.0.50", "10.0.1.50", "10.0.2.50", "10.0.0.51", "10.0.1.51"}; !reflect.DeepEqual(c.ControllerIPs(), expected) {
+		t.Errorf("expected the controllers to be spread across the subnets at %v, got %v", expected, c.ControllerIPs())
+	}
+	controllers, err := c.stackInstances(c.ControllerIPs())
+	if err != nil {
+		t.Fatalf("failed to place controllers: %v", err)
+	}
+	for i, controller := range controllers {
+		if controller.SubnetIndex != i%3 {
+			t.Errorf("expected controller %s in subnet %d, got %d", controller.IP, i%3, controller.SubnetIndex)
+		}
+	}
+	if _, err := ClusterFromBytes([]byte(minimalConfigYaml + `
+controllerCount: 2
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.0.0.0/24
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.0.1.0/28 # has no address at controllerIP's offset
+`)); err == nil {
+		t.Errorf("expected error placing a controller outside a subnet too small for controllerIP's offset")
+	}
+
+	invalidConfigs := []string{
+		`
+controllerCount: 0
+`, `
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.254
+controllerCount: 3 # runs past the end of instanceCIDR
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestReservedSubnetAddresses(t *testing.T) {
+	validConfigs := []string{
+		`
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.5 # etcd takes 10.4.3.4, the first usable address
+`, `
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.254 # the last usable address
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []struct {
+		conf     string
+		reserved string
+	}{
+		{`
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.0
+etcdIP: 10.4.3.10
+`, "controller IP 10.4.3.0 is the network address"},
+		{`
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.1
+etcdIP: 10.4.3.10
+`, "controller IP 10.4.3.1 is the VPC router address reserved by AWS"},
+		{`
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.2
+etcdIP: 10.4.3.10
+`, "controller IP 10.4.3.2 is the DNS server address reserved by AWS"},
+		{`
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.4 # etcd takes 10.4.3.3
+`, "etcd IP 10.4.3.3 is an address reserved by AWS for future use"},
+		{`
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.254
+controllerCount: 2
+`, "controller IP 10.4.3.255 is the broadcast address"},
+	}
+	for _, invalid := range invalidConfigs {
+		_, err := ClusterFromBytes([]byte(singleAzConfigYaml + invalid.conf))
+		if err == nil {
+			t.Errorf("expected error parsing invalid config %q", invalid.conf)
+		} else if !strings.Contains(err.Error(), invalid.reserved) {
+			t.Errorf("expected error to contain %q, got: %v", invalid.reserved, err)
+		}
+	}
+}
+
+func TestInstanceCIDRCapacity(t *testing.T) {
+	validConfigs := []string{
+		`
+availabilityZone: us-west-1a
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/28
+controllerIP: 10.4.3.10
+workerCount: 9 # 11 usable addresses in a /28
+`, `
+vpcCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.10
+workerCount: 20
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.3.0/28
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.4.3.16/28
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(minimalConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+availabilityZone: us-west-1a
+vpcCIDR: 10.4.3.0/24
+instanceCIDR: 10.4.3.0/28
+controllerIP: 10.4.3.10
+workerCount: 10
+`, `
+vpcCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.10
+workerCount: 21
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.3.0/28
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.4.3.16/28
+`,
+	}
+	for _, conf := range invalidConfigs {
+		_, err := ClusterFromBytes([]byte(minimalConfigYaml + conf))
+		if err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		} else if !strings.Contains(err.Error(), "usable addresses") {
+			t.Errorf("expected error to report the usable capacity, got: %v", err)
+		}
+	}
+}
+
+func TestEtcdCount(t *testing.T) {
+	validConfigs := []struct {
+		conf    string
+		etcdIPs []string
+	}{
+		{
+			conf:    ``,
+			etcdIPs: []string{"10.0.0.49"},
+		},
+		{
+			conf: `
+etcdCount: 3
+`,
+			etcdIPs: []string{"10.0.0.47", "10.0.0.48", "10.0.0.49"},
+		},
+		{
+			conf: `
+etcdCount: 3
+etcdIP: 10.0.0.20
+controllerCount: 2
+`,
+			etcdIPs: []string{"10.0.0.20", "10.0.0.21", "10.0.0.22"},
+		},
+	}
+	for _, valid := range validConfigs {
+		// amiId avoids looking up the AMI in Config()
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + "amiId: ami-0123abcd\n" + valid.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", valid.conf, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.EtcdIPs(), valid.etcdIPs) {
+			t.Errorf("expected etcd IPs %v, got %v", valid.etcdIPs, c.EtcdIPs())
+		}
+
+		cfg, err := c.Config()
+		if err != nil {
+			t.Fatalf("failed to create config: %v", err)
+		}
+		for _, ip := range valid.etcdIPs {
+			if !strings.Contains(cfg.ETCDEndpoints, "http://"+ip+":2379") {
+				t.Errorf("expected etcd endpoints %q to contain %s", cfg.ETCDEndpoints, ip)
+			}
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+etcdCount: 0
+`, `
+etcdCount: 2
+`, `
+etcdIP: 10.0.0.50 # the controller's address
+`, `
+etcdIP: 10.1.0.20 # outside instanceCIDR
+`, `
+etcdIP: not-an-ip
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestEtcdVolumes(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+etcdRootVolumeSize: 10
+etcdDataVolumeSize: 5
+`, `
+etcdDataVolumeSize: 500
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+etcdRootVolumeSize: 0
+`, `
+etcdDataVolumeSize: 4
+`, `
+etcdDataVolumeSize: 16385
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+
+	r := newTestRenderer(t)
+	defer r.cleanup()
+	var stack struct {
+		Resources map[string]struct {
+			Properties struct {
+				Encrypted bool
+			}
+		}
+	}
+	r.renderStack("", &stack)
+	if !stack.Resources["EtcdDataVolume"].Properties.Encrypted {
+		t.Errorf("expected the etcd data volume to be encrypted")
+	}
+}
+
+func TestNodePublicIPs(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	for conf, public := range map[string]bool{
+		// Nodes of a public cluster reach the internet from public IPs
+		`
+controllerCount: 2
+etcdCount: 3
+`: true,
+		// and through the NAT otherwise
+		`
+controllerCount: 2
+etcdCount: 3
+natMode: gateway
+publicCIDR: 10.0.128.0/24
+`: false,
+	} {
+		var stack struct {
+			Resources map[string]struct {
+				Properties struct {
+					NetworkInterfaces []struct {
+						AssociatePublicIpAddress bool
+					}
+				}
+			}
+		}
+		r.renderStack(conf, &stack)
+		for _, name := range []string{"InstanceController", "InstanceController1", "InstanceEtcd", "InstanceEtcd1", "InstanceEtcd2"} {
+			if networkInterfaces := stack.Resources[name].Properties.NetworkInterfaces; len(networkInterfaces) != 1 || networkInterfaces[0].AssociatePublicIpAddress != public {
+				t.Errorf("expected %s to have a public IP %t for config %q, got %+v", name, public, conf, networkInterfaces)
+			}
+		}
+		if _, ok := stack.Resources["EIPController"]; ok != public {
+			t.Errorf("expected the controller EIP %t for config %q", public, conf)
+		}
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+httpProxy: http://proxy.example.com:3128
+`, `
+httpsProxy: https://proxy.example.com
+noProxy: .example.com,10.100.0.0/16
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+httpProxy: proxy.example.com:3128 # no scheme
+`, `
+httpsProxy: ftp://proxy.example.com
+`, `
+httpProxy: "http://%zz"
+`, `
+noProxy: .example.com # without a proxy
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+httpProxy: http://proxy.example.com:3128
+noProxy: .example.com
+`))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	noProxyString, err := c.NoProxyString()
+	if err != nil {
+		t.Fatalf("failed to render noProxy: %v", err)
+	}
+	noProxy := strings.Split(noProxyString, ",")
+	for _, expected := range []string{
+		".example.com",
+		"169.254.169.254",
+		c.ExternalDNSName,
+		c.ControllerIP,
+		c.EtcdIPs()[0],
+		"10.3.0.1", // kubernetes service IP
+		"kubernetes.default.svc.cluster.local",
+		".compute.internal",
+		".us-west-1.elb.amazonaws.com",
+		c.VPCCIDR,
+		c.Subnets[0].InstanceCIDR,
+		c.ServiceCIDR,
+		c.PodCIDR,
+	} {
+		found := false
+		for _, host := range noProxy {
+			if host == expected {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected noProxy %v to contain %s", noProxy, expected)
+		}
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	conf := singleAzConfigYaml + `controllerIp: 10.0.0.60
+stackTags:
+  Anything: goes
+workerPools:
+  - name: general
+    instanceTyp: m4.large
+subnet:
+  - availabilityZone: us-west-1c
+    instanceCIDR: 10.0.0.0/24
+`
+	_, err := ClusterFromBytes([]byte(conf))
+	if err == nil {
+		t.Fatalf("expected error parsing config with unknown keys")
+	}
+	for _, expected := range []string{
+		"controllerIp (line 8)",
+		"workerPools[0].instanceTyp (line 13)",
+		"subnet (line 14)",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to list %q, got: %v", expected, err)
+		}
+	}
+	for _, unexpected := range []string{"Anything", "availabilityZone"} {
+		if strings.Contains(err.Error(), unexpected) {
+			t.Errorf("expected error not to list %q, got: %v", unexpected, err)
+		}
+	}
+
+	c, err := ClusterFromBytes([]byte(conf + "allowUnknownKeys: true\n"))
+	if err != nil {
+		t.Fatalf("failed to parse config allowing unknown keys: %v", err)
+	}
+	if c.ControllerIP != "10.0.0.50" {
+		t.Errorf("expected the misspelled controllerIp to be ignored, got controllerIP %s", c.ControllerIP)
+	}
+	if warnings := c.Warnings(); !hasWarning(warnings, "ignoring unknown keys: controllerIp (line 8)") {
+		t.Errorf("expected a warning listing the ignored keys, got %v", warnings)
+	}
+}
+
+// hasWarning reports whether one of warnings contains substr
+func hasWarning(warnings []string, substr string) bool {
+	for _, warning := range warnings {
+		if strings.Contains(warning, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestVPCSize(t *testing.T) {
+	validConfigs := []string{
+		`
+availabilityZone: us-west-1a
+vpcCIDR: 10.4.3.0/26
+instanceCIDR: 10.4.3.0/27
+controllerIP: 10.4.3.10
+natMode: gateway
+publicCIDR: 10.4.3.32/27
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(minimalConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []struct {
+		conf              string
+		available, needed string
+	}{
+		{
+			conf: `
+availabilityZone: us-west-1a
+vpcCIDR: 10.4.3.0/26
+instanceCIDR: 10.4.3.0/24
+controllerIP: 10.4.3.10
+`,
+			available: "has 64 addresses",
+			needed:    "need 256",
+		},
+		{
+			conf: `
+availabilityZone: us-west-1a
+vpcCIDR: 10.4.3.0/26
+instanceCIDR: 10.4.3.0/26
+controllerIP: 10.4.3.10
+natMode: gateway
+publicCIDR: 10.4.3.64/28
+`,
+			available: "has 64 addresses",
+			needed:    "need 80",
+		},
+		{
+			conf: `
+vpcCIDR: 10.4.3.0/26
+controllerIP: 10.4.3.10
+natMode: gateway
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.3.0/27
+    publicCIDR: 10.4.3.32/28
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.4.3.48/28
+    publicCIDR: 10.4.3.64/28
+`,
+			available: "has 64 addresses",
+			needed:    "need 80",
+		},
+	}
+	for _, invalid := range invalidConfigs {
+		_, err := ClusterFromBytes([]byte(minimalConfigYaml + invalid.conf))
+		if err == nil {
+			t.Errorf("expected error parsing invalid config %q", invalid.conf)
+		} else if !strings.Contains(err.Error(), invalid.available) || !strings.Contains(err.Error(), invalid.needed) {
+			t.Errorf("expected error to report the available (%s) and needed (%s) address space, got: %v", invalid.available, invalid.needed, err)
+		}
+	}
+}
+
+func TestNATMode(t *testing.T) {
+	validConfigs := []string{
+		singleAzConfigYaml,
+		singleAzConfigYaml + `
+natMode: gateway
+publicCIDR: 10.0.128.0/24
+`,
+		singleAzConfigYaml + `
+natMode: instance
+natInstanceType: t2.small
+publicCIDR: 10.0.128.0/24
+`,
+		minimalConfigYaml + `
+natMode: gateway
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.0.0.0/24
+    publicCIDR: 10.0.128.0/24
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.0.1.0/24
+    publicCIDR: 10.0.129.0/24
+`,
+		singleAzConfigYaml + `
+apiEndpointInternal: true
+natMode: gateway
+publicCIDR: 10.0.128.0/24
+`,
+		singleAzConfigYaml + `
+apiEndpointInternal: true
+vpcId: vpc-xxx1 # routes through its own NAT
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		singleAzConfigYaml + `
+apiEndpointInternal: true # controllers without outbound access
+`,
+		singleAzConfigYaml + `
+natMode: router
+publicCIDR: 10.0.128.0/24
+`,
+		singleAzConfigYaml + `
+natMode: gateway # no publicCIDR
+`,
+		singleAzConfigYaml + `
+publicCIDR: 10.0.128.0/24 # without natMode
+`,
+		singleAzConfigYaml + `
+natMode: gateway
+publicCIDR: 10.1.128.0/24 # outside the VPC
+`,
+		singleAzConfigYaml + `
+natMode: gateway
+publicCIDR: 10.0.0.128/25 # overlaps instanceCIDR
+`,
+		singleAzConfigYaml + `
+natMode: instance
+natInstanceType: tiny
+publicCIDR: 10.0.128.0/24
+`,
+		singleAzConfigYaml + `
+natMode: gateway
+publicCIDR: 10.0.128.0/24
+vpcId: vpc-xxx1
+`,
+		minimalConfigYaml + `
+natMode: gateway
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.0.0.0/24
+    publicCIDR: 10.0.128.0/24
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.0.1.0/24
+    publicCIDR: 10.0.128.0/24 # overlaps the first public subnet
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestNetworkPlugin(t *testing.T) {
+	validConfigs := []struct {
+		conf          string
+		networkPlugin string
+	}{
+		{``, "flannel"},
+		{"\nnetworkPlugin: flannel\n", "flannel"},
+		{"\nnetworkPlugin: calico\n", "calico"},
+		{"\nnetworkPlugin: calico\nuseCalico: true\n", "calico"},
+	}
+	for _, valid := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + valid.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", valid.conf, err)
+			continue
+		}
+		if c.NetworkPlugin != valid.networkPlugin {
+			t.Errorf("expected networkPlugin %s for config %q, got %s", valid.networkPlugin, valid.conf, c.NetworkPlugin)
+		}
+	}
+
+	invalidConfigs := []string{
+		"\nnetworkPlugin: weave\n",
+		"\nnetworkPlugin: calico\npodCIDR: \"\"\n",
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml + "\nnetworkPlugin: calico\namiId: ami-0123abcd\n"))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if key := c.CalicoIPPoolKey(); key != "/calico/v1/ipam/v4/pool/10.2.0.0-16" {
+		t.Errorf("unexpected calico IP pool key %s", key)
+	}
+	config, err := c.Config()
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	if !config.UseCalico || config.K8sNetworkPlugin != "cni" {
+		t.Errorf("expected calico networking to enable the calico policy agent and the cni plugin, got useCalico=%v networkPlugin=%s", config.UseCalico, config.K8sNetworkPlugin)
+	}
+}
+
+func TestRBACEnabled(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+rbacEnabled: true
+kubernetesVersion: v1.3.4_coreos.0
+`, `
+rbacEnabled: true
+kubernetesVersion: v2.0.0_coreos.0
+`, `
+rbacEnabled: false
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+rbacEnabled: true # default kubernetesVersion predates RBAC
+`, `
+rbacEnabled: true
+kubernetesVersion: v1.2.4_coreos.cni.1
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestAdmissionControl(t *testing.T) {
+	validConfigs := []struct {
+		conf             string
+		admissionControl string
+	}{
+		{``, "NamespaceLifecycle,LimitRanger,SecurityContextDeny,ServiceAccount,ResourceQuota"},
+		{`
+admissionControl:
+  - NamespaceLifecycle
+  - LimitRanger
+  - ServiceAccount
+  - PodSecurityPolicy
+  - ResourceQuota
+`, "NamespaceLifecycle,LimitRanger,ServiceAccount,PodSecurityPolicy,ResourceQuota"},
+		{`
+admissionControl: [ServiceAccount]
+`, "ServiceAccount"},
+	}
+	for _, valid := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + valid.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", valid.conf, err)
+			continue
+		}
+		if actual := c.AdmissionControlString(); actual != valid.admissionControl {
+			t.Errorf("expected admission control %s for config %q, got %s", valid.admissionControl, valid.conf, actual)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+admissionControl: [] # drops ServiceAccount
+`, `
+admissionControl: [NamespaceLifecycle, ResourceQuota]
+`, `
+admissionControl: [ServiceAccount, ServiceAccount]
+`, `
+admissionControl: ["ServiceAccount,ResourceQuota"]
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	validConfigs := []struct {
+		conf     string
+		auditLog AuditLog
+	}{
+		{``, AuditLog{Enabled: false, Path: "/var/log/kube-apiserver/audit.log", MaxAge: 30, MaxBackups: 10, MaxSize: 100}},
+		{`
+auditLog:
+  enabled: true
+`, AuditLog{Enabled: true, Path: "/var/log/kube-apiserver/audit.log", MaxAge: 30, MaxBackups: 10, MaxSize: 100}},
+		{`
+auditLog:
+  enabled: true
+  path: /var/log/audit/apiserver.log
+  maxAge: 7
+  maxBackups: 3
+  maxSize: 50
+`, AuditLog{Enabled: true, Path: "/var/log/audit/apiserver.log", MaxAge: 7, MaxBackups: 3, MaxSize: 50}},
+		{`
+auditLog:
+  enabled: false
+  path: relative/paths/are/ignored/when/disabled
+`, AuditLog{Enabled: false, Path: "relative/paths/are/ignored/when/disabled", MaxAge: 30, MaxBackups: 10, MaxSize: 100}},
+	}
+	for _, valid := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + valid.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", valid.conf, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.AuditLog, valid.auditLog) {
+			t.Errorf("expected auditLog %+v for config %q, got %+v", valid.auditLog, valid.conf, c.AuditLog)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+auditLog:
+  enabled: true
+  path: audit.log
+`, `
+auditLog:
+  enabled: true
+  path: /audit.log
+`, `
+auditLog:
+  enabled: true
+  maxAge: 0
+`, `
+auditLog:
+  enabled: true
+  maxBackups: -1
+`, `
+auditLog:
+  enabled: true
+  maxSize: 0
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestExtraComponentFlags(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+apiServerFlags:
+  - --feature-gates=AllAlpha=true
+  - --v=4
+controllerManagerFlags:
+  - --node-monitor-grace-period=60s
+schedulerFlags:
+  - --policy-config-file=/etc/kubernetes/scheduler-policy.json
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+apiServerFlags:
+  - v=4
+`, `
+controllerManagerFlags:
+  - -v=4
+`, `
+schedulerFlags:
+  - --
+`, `
+apiServerFlags:
+  - "--v=4\n  - --insecure-bind-address=0.0.0.0"
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestWorkerCustomFilesAndUnits(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+workerCustomFiles:
+  - path: /opt/bin/node-agent
+    permissions: 0755
+    content: |
+      #!/bin/bash
+      exec /opt/node-agent/bin/agent
+workerSystemdUnits:
+  - name: node-agent.service
+    command: start
+    content: |
+      [Unit]
+      Before=kubelet.service
+
+      [Service]
+      ExecStart=/opt/bin/node-agent
+  - name: node-agent-cleanup.timer
+    enable: true
+    content: |
+      [Timer]
+      OnCalendar=daily
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+workerCustomFiles:
+  - path: opt/bin/node-agent
+    content: relative path
+`, `
+workerCustomFiles:
+  - path: /opt/bin/node-agent
+    permissions: 0100000
+`, `
+workerCustomFiles:
+  - path: /opt/bin/node-agent
+    permissions: 0758
+`, `
+workerCustomFiles:
+  - path: /opt/bin/node-agent
+    permissions: rwxr-xr-x
+`, `
+workerSystemdUnits:
+  - name: node-agent
+    command: start
+`, `
+workerSystemdUnits:
+  - name: node-agent.mount
+    command: start
+`, `
+workerSystemdUnits:
+  - name: node-agent.service
+    command: launch
+`, `
+workerSystemdUnits:
+  - name: node-agent.service
+`, `
+workerSystemdUnits:
+  - name: kubelet.service
+    command: restart
+`, `
+workerSystemdUnits:
+  - name: node-agent.service
+    command: start
+  - name: node-agent.service
+    command: restart
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+
+	for _, perm := range []string{"0644", "644", `"0644"`} {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + "workerCustomFiles:\n  - path: /etc/agent.conf\n    permissions: " + perm + "\n"))
+		if err != nil {
+			t.Errorf("failed to parse permissions %s: %v", perm, err)
+			continue
+		}
+		if mode := c.WorkerCustomFiles[0].Permissions; mode != 0644 {
+			t.Errorf("expected permissions %s to be read as octal 0644, got %#o", perm, mode)
+		}
+	}
+
+	file := CustomFile{Path: "/etc/agent.conf", Permissions: 0644, Content: "a: 1\n\nb: 2\n"}
+	if perm := file.PermissionsString(); perm != "0644" {
+		t.Errorf("expected permissions 0644, got %s", perm)
+	}
+	if lines := file.ContentArray(); !reflect.DeepEqual(lines, []string{"a: 1", "", "b: 2"}) {
+		t.Errorf("unexpected content lines %q", lines)
+	}
+}
+
+func TestIAMInstanceProfiles(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+controllerIAMInstanceProfile: kube-controller
+workerIAMInstanceProfile: kube-worker
+`, `
+controllerIAMInstanceProfile: arn:aws:iam::123456789012:instance-profile/kube-controller
+workerIAMInstanceProfile: arn:aws:iam::123456789012:instance-profile/k8s/kube-worker
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+controllerIAMInstanceProfile: kube-controller # without workerIAMInstanceProfile
+`, `
+workerIAMInstanceProfile: kube-worker # without controllerIAMInstanceProfile
+`, `
+controllerIAMInstanceProfile: kube controller
+workerIAMInstanceProfile: kube-worker
+`, `
+controllerIAMInstanceProfile: kube-controller
+workerIAMInstanceProfile: arn:aws:iam::123456789012:role/kube-worker
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+controllerIAMInstanceProfile: kube-controller
+workerIAMInstanceProfile: arn:aws:iam::123456789012:instance-profile/k8s/kube-worker
+`))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if name := c.ControllerIAMInstanceProfileName(); name != "kube-controller" {
+		t.Errorf("expected controller instance profile name kube-controller, got %s", name)
+	}
+	if name := c.WorkerIAMInstanceProfileName(); name != "kube-worker" {
+		t.Errorf("expected worker instance profile name kube-worker, got %s", name)
+	}
+}
+
+func TestIAMPolicyStatements(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+workerIAMPolicyStatements:
+  - effect: Allow
+    action:
+      - ecr:GetAuthorizationToken
+      - ecr:BatchGetImage
+    resource:
+      - "*"
+controllerIAMPolicyStatements:
+  - effect: Deny
+    action: ["s3:Delete*"]
+    resource: ["arn:aws:s3:::my-bucket/*"]
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+workerIAMPolicyStatements:
+  - action: ["ecr:BatchGetImage"] # no effect
+    resource: ["*"]
+`, `
+workerIAMPolicyStatements:
+  - effect: allow
+    action: ["ecr:BatchGetImage"]
+    resource: ["*"]
+`, `
+workerIAMPolicyStatements:
+  - effect: Allow # no action
+    resource: ["*"]
+`, `
+workerIAMPolicyStatements:
+  - effect: Allow
+    action: ["BatchGetImage"]
+    resource: ["*"]
+`, `
+controllerIAMPolicyStatements:
+  - effect: Allow
+    action: ["ecr:BatchGetImage"] # no resource
+`, `
+controllerIAMPolicyStatements:
+  - effect: Allow
+    action: ["s3:GetObject"]
+    resource: ["my-bucket/*"]
+`, `
+controllerIAMInstanceProfile: kube-controller
+workerIAMInstanceProfile: kube-worker
+workerIAMPolicyStatements:
+  - effect: Allow
+    action: ["ecr:BatchGetImage"]
+    resource: ["*"]
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+
+	statement := IAMPolicyStatement{Effect: "Allow", Action: []string{"ecr:BatchGetImage"}, Resource: []string{"*"}}
+	expected := `{"Effect":"Allow","Action":["ecr:BatchGetImage"],"Resource":["*"]}`
+	if actual, err := statement.JSON(); err != nil || actual != expected {
+		t.Errorf("expected statement JSON %s, got %s (err=%v)", expected, actual, err)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	for _, test := range []struct {
+		region, partition, dnsSuffix string
+	}{
+		{"us-west-1", "aws", "amazonaws.com"},
+		{"us-gov-west-1", "aws-us-gov", "amazonaws.com"},
+		{"cn-north-1", "aws-cn", "amazonaws.com.cn"},
+	} {
+		c := Cluster{Region: test.region}
+		if partition := c.Partition(); partition != test.partition {
+			t.Errorf("expected partition %s for region %s, got %s", test.partition, test.region, partition)
+		}
+		if dnsSuffix := c.DNSSuffix(); dnsSuffix != test.dnsSuffix {
+			t.Errorf("expected dns suffix %s for region %s, got %s", test.dnsSuffix, test.region, dnsSuffix)
+		}
+	}
+
+	govCloudConfig := `externalDNSName: test.staging.core-os.net
+keyName: test-key-name
+region: us-gov-west-1
+availabilityZone: us-gov-west-1a
+clusterName: test-cluster-name
+`
+	validConfigs := []string{
+		govCloudConfig + `kmsKeyArn: "arn:aws-us-gov:kms:us-gov-west-1:123456789012:key/xxxxxxxxxxxxxxxxxxx"
+`,
+		govCloudConfig + `kmsKeyArn: "arn:aws-us-gov:kms:us-gov-west-1:123456789012:key/xxxxxxxxxxxxxxxxxxx"
+controllerIAMInstanceProfile: arn:aws-us-gov:iam::123456789012:instance-profile/kube-controller
+workerIAMInstanceProfile: kube-worker
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		govCloudConfig + `kmsKeyArn: "arn:aws:kms:us-gov-west-1:123456789012:key/xxxxxxxxxxxxxxxxxxx"
+`,
+		strings.Replace(govCloudConfig, "us-gov-west-1", "cn-north-1", -1) + `kmsKeyArn: "arn:aws:kms:cn-north-1:123456789012:key/xxxxxxxxxxxxxxxxxxx"
+`,
+		govCloudConfig + `kmsKeyArn: "arn:aws-us-gov:kms:us-gov-west-1:123456789012:key/xxxxxxxxxxxxxxxxxxx"
+controllerIAMInstanceProfile: arn:aws:iam::123456789012:instance-profile/kube-controller
+workerIAMInstanceProfile: kube-worker
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestRegion(t *testing.T) {
+	for _, region := range []string{"us-west-1", "us-gov-west-1", "ap-south-1"} {
+		if err := validateRegion(region); err != nil {
+			t.Errorf("returned error for valid region %s: %v", region, err)
+		}
+	}
+
+	for _, region := range []string{"us-west-11", "US-WEST-1", "us-west"} {
+		err := validateRegion(region)
+		if err == nil {
+			t.Errorf("failed to catch invalid region %s", region)
+			continue
+		}
+		if !strings.Contains(err.Error(), "us-west-2") {
+			t.Errorf("expected error for region %s to list the valid regions, got: %v", region, err)
+		}
+	}
+
+	conf := strings.Replace(singleAzConfigYaml, "region: us-west-1", "region: us-west-11", 1)
+	if _, err := ClusterFromBytes([]byte(conf)); err == nil {
+		t.Errorf("expected error parsing config with region us-west-11")
+	}
+}
+
+func TestAPIELBSubnetIds(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+vpcId: vpc-xxx1
+apiELBSubnetIds:
+  - subnet-1234abcd
+  - subnet-5678ef01
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+apiELBSubnetIds: [subnet-1234abcd] # without vpcId
+`, `
+vpcId: vpc-xxx1
+apiELBSubnetIds: [sg-1234abcd]
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestVPCDomainName(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+vpcDomainName: k8s.example.com
+`, `
+vpcDomainName: internal
+`, `
+vpcDomainName: Staging-1.Example.com
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+vpcDomainName: k8s.example.com
+vpcId: vpc-xxx1 # only for the vpc kube-aws creates
+`, `
+vpcDomainName: -k8s.example.com
+`, `
+vpcDomainName: k8s..example.com
+`, `
+vpcDomainName: k8s.example.com.
+`, `
+vpcDomainName: k8s_internal.example.com
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestWorkerAutoScaling(t *testing.T) {
+	validConfigs := []struct {
+		conf             string
+		minSize, maxSize int
+	}{
+		{
+			conf:    ``,
+			minSize: 1,
+			maxSize: 1,
+		},
+		{
+			conf: `
+workerCount: 3
+`,
+			minSize: 3,
+			maxSize: 3,
+		},
+		{
+			conf: `
+workerCount: 2
+workerAutoScaling:
+  minSize: 0
+  maxSize: 5
+  targetCPUUtilization: 70
+`,
+			minSize: 0,
+			maxSize: 5,
+		},
+		{
+			conf: `
+workerCount: 5
+workerAutoScaling:
+  minSize: 5
+  maxSize: 5
+  targetCPUUtilization: 100
+`,
+			minSize: 5,
+			maxSize: 5,
+		},
+	}
+	for _, valid := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + valid.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", valid.conf, err)
+			continue
+		}
+		if c.WorkerMinSize() != valid.minSize || c.WorkerMaxSize() != valid.maxSize {
+			t.Errorf("expected worker group size between %d and %d, got %d and %d", valid.minSize, valid.maxSize, c.WorkerMinSize(), c.WorkerMaxSize())
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+workerCount: 1
+workerAutoScaling:
+  minSize: 2 # workerCount below minSize
+  maxSize: 5
+  targetCPUUtilization: 70
+`, `
+workerCount: 6
+workerAutoScaling:
+  minSize: 2
+  maxSize: 5 # workerCount above maxSize
+  targetCPUUtilization: 70
+`, `
+workerCount: 2
+workerAutoScaling:
+  minSize: -1
+  maxSize: 5
+  targetCPUUtilization: 70
+`, `
+workerCount: 2
+workerAutoScaling:
+  minSize: 1
+  maxSize: 5
+`, `
+workerCount: 2
+workerAutoScaling:
+  minSize: 1
+  maxSize: 5
+  targetCPUUtilization: 110
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestWorkerClusterAutoscaler(t *testing.T) {
+	validConfigs := []string{
+		`
+workerCount: 2
+workerClusterAutoscaler:
+  minSize: 1
+  maxSize: 10
+`, `
+workerCount: 1
+workerClusterAutoscaler:
+  minSize: 0
+  maxSize: 1
+`,
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+			continue
+		}
+		if c.WorkerMinSize() != c.WorkerClusterAutoscaler.MinSize || c.WorkerMaxSize() != c.WorkerClusterAutoscaler.MaxSize {
+			t.Errorf("expected worker group size to follow workerClusterAutoscaler %+v, got %d and %d", *c.WorkerClusterAutoscaler, c.WorkerMinSize(), c.WorkerMaxSize())
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+workerCount: 3
+workerClusterAutoscaler:
+  minSize: 3
+  maxSize: 3 # no room to scale
+`, `
+workerCount: 1
+workerClusterAutoscaler:
+  minSize: 2 # workerCount below minSize
+  maxSize: 10
+`, `
+workerCount: 2
+workerClusterAutoscaler:
+  minSize: 1
+  maxSize: 10
+workerAutoScaling:
+  minSize: 1
+  maxSize: 10
+  targetCPUUtilization: 70
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestWorkerDesiredCapacity(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	// An update must not reset the capacity a scaling policy or the cluster
+	// autoscaler has since set, so it's only pinned to workerCount without them.
+	for conf, pinned := range map[string]bool{
+		``: true,
+		`
+workerAutoScaling:
+  minSize: 1
+  maxSize: 5
+  targetCPUUtilization: 70
+`: false,
+		`
+workerClusterAutoscaler:
+  minSize: 1
+  maxSize: 10
+`: false,
+	} {
+		var stack struct {
+			Resources map[string]struct {
+				Properties map[string]json.RawMessage
+			}
+		}
+		r.renderStack(conf, &stack)
+		if _, ok := stack.Resources["AutoScaleWorker"].Properties["DesiredCapacity"]; ok != pinned {
+			t.Errorf("expected DesiredCapacity set to be %t for config %q", pinned, conf)
+		}
+	}
+}
+
+func TestStackName(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+stackName: kubernetes-staging-2
+`, `
+clusterName: test_cluster # not a valid stack name, but stackName is
+stackName: test-cluster
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+stackName: 2-kubernetes
+`, `
+stackName: kubernetes_staging
+`, `
+clusterName: test_cluster
+`, `
+stackName: ` + strings.Repeat("k", 129) + `
+`, `
+clusterName: test cluster
+stackName: test-cluster
+`, `
+clusterName: "test\"cluster"
+stackName: test-cluster
+`, `
+clusterName: .test-cluster
+stackName: test-cluster
+`, `
+clusterName: ` + strings.Repeat("k", 103) + `
+stackName: test-cluster
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestUpdateStrategy(t *testing.T) {
+	validConfigs := []struct {
+		conf     string
+		channel  string
+		strategy string
+	}{
+		{
+			conf:     ``,
+			channel:  "",
+			strategy: "off",
+		},
+		{
+			conf: `
+coreosChannel: stable
+rebootStrategy: etcd-lock
+`,
+			channel:  "stable",
+			strategy: "etcd-lock",
+		},
+		{
+			conf: `
+coreosChannel: beta
+rebootStrategy: reboot
+`,
+			channel:  "beta",
+			strategy: "reboot",
+		},
+		{
+			conf: `
+coreosChannel: alpha
+rebootStrategy: off
+`,
+			channel:  "alpha",
+			strategy: "off",
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		if c.CoreOSChannel != conf.channel {
+			t.Errorf("expected coreosChannel %q, got %q", conf.channel, c.CoreOSChannel)
+		}
+		if c.RebootStrategy != conf.strategy {
+			t.Errorf("expected rebootStrategy %q, got %q", conf.strategy, c.RebootStrategy)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+coreosChannel: edge
+`, `
+rebootStrategy: best-effort # not offered by locksmithd
+`, `
+rebootStrategy: ""
+`, `
+rebootStrategy: etcd-lock
+etcdCount: 0
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestCompressUserData(t *testing.T) {
+	// Repetitive like a real cloud-config, so it compresses far below the limit
+	cloudConfig := "#cloud-config\n" + strings.Repeat("write_files:\n  - path: /etc/kubernetes/manifests/kube-proxy.yaml\n", 1000)
+	if len(cloudConfig) <= maxUserDataSize {
+		t.Fatalf("test cloud-config should exceed the userdata limit uncompressed, got %d bytes", len(cloudConfig))
+	}
+
+	userData := &stackConfig{
+		UserDataController: cloudConfig,
+		UserDataEtcd:       cloudConfig,
+		Workers:            []stackWorkerPool{{UserDataWorker: cloudConfig}},
+	}
+	if err := userData.compressUserData(); err != nil {
+		t.Fatalf("failed to compress userdata: %v", err)
+	}
+	if len(userData.Workers[0].UserDataWorker) > maxUserDataSize {
+		t.Errorf("compressed userdata is %d bytes, over the limit of %d", len(userData.Workers[0].UserDataWorker), maxUserDataSize)
+	}
+	gzipped, err := base64.StdEncoding.DecodeString(userData.Workers[0].UserDataWorker)
+	if err != nil {
+		t.Fatalf("compressed userdata is not base64 encoded: %v", err)
+	}
+	gzr, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		t.Fatalf("compressed userdata is not gzipped: %v", err)
+	}
+	decompressed, err := ioutil.ReadAll(gzr)
+	if err != nil {
+		t.Fatalf("failed to decompress userdata: %v", err)
+	}
+	if string(decompressed) != cloudConfig {
+		t.Errorf("decompressed userdata does not match the original cloud-config")
+	}
+
+	// Random data doesn't compress, so it stays over the limit
+	incompressible := make([]byte, 16*1024)
+	if _, err := rand.Read(incompressible); err != nil {
+		t.Fatalf("failed to generate random data: %v", err)
+	}
+	userData = &stackConfig{
+		UserDataController: string(incompressible),
+		UserDataEtcd:       cloudConfig,
+		Workers: []stackWorkerPool{
+			{UserDataWorker: string(incompressible)},
+			{Name: "gpu", UserDataWorker: string(incompressible)},
+		},
+	}
+	err = userData.compressUserData()
+	if err == nil {
+		t.Fatalf("expected error compressing userdata over the limit")
+	}
+	for _, name := range []string{"worker cloud-config", "worker pool gpu cloud-config", "controller cloud-config", fmt.Sprintf("limit of %d bytes", maxUserDataSize)} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected error to contain %q, got: %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "etcd cloud-config") {
+		t.Errorf("expected error not to list the etcd cloud-config, which is under the limit: %v", err)
+	}
+}
+
+func TestVPCPeering(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+vpcPeering:
+  peerVpcId: vpc-0a1b2c3d
+  peerCIDR: 172.16.0.0/16
+`, `
+vpcPeering:
+  peerVpcId: vpc-0a1b2c3d
+  peerCIDR: 10.1.0.0/16 # adjacent to vpcCIDR 10.0.0.0/16
+  peerRouteTableIds:
+    - rtb-0a1b2c3d
+    - rtb-4e5f6a7b
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+vpcPeering:
+  peerVpcId: vpc-0a1b2c3d
+  peerCIDR: 10.0.128.0/17 # within vpcCIDR
+`, `
+vpcPeering:
+  peerVpcId: vpc-0a1b2c3d
+  peerCIDR: 10.0.0.0/8 # contains vpcCIDR
+`, `
+vpcPeering:
+  peerVpcId: vpc-0a1b2c3d
+  peerCIDR: 172.16.0.0
+`, `
+vpcPeering:
+  peerVpcId: shared-services
+  peerCIDR: 172.16.0.0/16
+`, `
+vpcPeering:
+  peerVpcId: vpc-0a1b2c3d
+  peerCIDR: 172.16.0.0/16
+  peerRouteTableIds:
+    - subnet-0a1b2c3d
+`, `
+vpcPeering:
+  peerVpcId: vpc-0a1b2c3d
+  peerCIDR: 172.16.0.0/16
+vpcId: vpc-xxx1 # only for the vpc kube-aws creates
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestSubnetIds(t *testing.T) {
+	const existingVPC = `
+vpcId: vpc-xxx1
+vpcCIDR: 10.4.0.0/16
+controllerIP: 10.4.1.10
+`
+	validConfigs := []struct {
+		conf       string
+		subnetRefs []string
+	}{
+		{
+			conf: `
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.1.0/24
+`,
+			subnetRefs: []string{`{ "Ref" : "Subnet0" }`},
+		},
+		{
+			conf: `
+subnetIds: [subnet-0a1b2c3d, subnet-4e5f6a7b]
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.1.0/24
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.4.2.0/24
+`,
+			subnetRefs: []string{`"subnet-0a1b2c3d"`, `"subnet-4e5f6a7b"`},
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(minimalConfigYaml + existingVPC + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		for i, subnetRef := range conf.subnetRefs {
+			if ref := c.SubnetRef(i); ref != subnetRef {
+				t.Errorf("expected subnet %d to be referenced as %s, got %s", i, subnetRef, ref)
+			}
+		}
+	}
+
+	invalidConfigs := []string{
+		existingVPC + `
+subnetIds: [subnet-0a1b2c3d]
+availabilityZone: us-west-1a
+instanceCIDR: 10.4.1.0/24 # subnetIds reuses subnets rather than creating one
+`, existingVPC + `
+subnetIds: [subnet-0a1b2c3d] # no subnets giving its availability zone and cidr
+availabilityZone: us-west-1a
+`, existingVPC + `
+subnetIds: [subnet-0a1b2c3d, subnet-4e5f6a7b]
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.1.0/24
+`, existingVPC + `
+subnetIds: [subnet-0a1b2c3d]
+routeTableId: rtb-0a1b2c3d
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.1.0/24
+`, existingVPC + `
+subnetIds: [sn-0a1b2c3d]
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.1.0/24
+`, `
+vpcCIDR: 10.4.0.0/16
+controllerIP: 10.4.1.10
+subnetIds: [subnet-0a1b2c3d] # only for an existing vpc
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.4.1.0/24
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(minimalConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestSSHAccessCIDRs(t *testing.T) {
+	validConfigs := []struct {
+		conf      string
+		cidrs     []string
+		worldOpen bool
+		warned    bool
+	}{
+		{
+			conf:      ``,
+			cidrs:     []string{"0.0.0.0/0"},
+			worldOpen: true,
+		},
+		{
+			conf: `
+sshAccessCIDRs:
+  - 203.0.113.0/24
+  - 10.0.0.0/8
+`,
+			cidrs:     []string{"203.0.113.0/24", "10.0.0.0/8"},
+			worldOpen: false,
+		},
+		{
+			conf: `
+sshAccessCIDRs: []
+`,
+			cidrs:     []string{},
+			worldOpen: false,
+		},
+		{
+			conf: `
+sshAccessCIDRs:
+  - 10.0.0.0/8
+  - 0.0.0.0/0
+`,
+			cidrs:     []string{"10.0.0.0/8", "0.0.0.0/0"},
+			worldOpen: true,
+			warned:    true,
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.SSHAccessCIDRs, conf.cidrs) {
+			t.Errorf("expected sshAccessCIDRs %v, got %v", conf.cidrs, c.SSHAccessCIDRs)
+		}
+		if c.SSHWorldOpen() != conf.worldOpen {
+			t.Errorf("expected SSHWorldOpen() to be %v for sshAccessCIDRs %v", conf.worldOpen, c.SSHAccessCIDRs)
+		}
+		// The default is only warned about when set explicitly
+		if warned := hasWarning(c.Warnings(), "sshAccessCIDRs"); warned != conf.warned {
+			t.Errorf("expected a warning about sshAccessCIDRs to be %v for config %q, got %v", conf.warned, conf.conf, c.Warnings())
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+sshAccessCIDRs:
+  - 203.0.113.7
+`, `
+sshAccessCIDRs:
+  - 2001:db8::/32 # security group CidrIp rules are IPv4 only
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestAPIAccessCIDRs(t *testing.T) {
+	validConfigs := []struct {
+		conf      string
+		cidrs     []string
+		worldOpen bool
+		warned    bool
+	}{
+		{
+			conf:      ``,
+			cidrs:     []string{"0.0.0.0/0"},
+			worldOpen: true,
+		},
+		{
+			conf: `
+apiAccessCIDRs:
+  - 203.0.113.0/24
+`,
+			cidrs:     []string{"203.0.113.0/24"},
+			worldOpen: false,
+		},
+		{
+			conf: `
+apiAccessCIDRs: []
+`,
+			cidrs:     []string{},
+			worldOpen: false,
+		},
+		{
+			conf: `
+apiAccessCIDRs:
+  - 0.0.0.0/0
+`,
+			cidrs:     []string{"0.0.0.0/0"},
+			worldOpen: true,
+			warned:    true,
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.APIAccessCIDRs, conf.cidrs) {
+			t.Errorf("expected apiAccessCIDRs %v, got %v", conf.cidrs, c.APIAccessCIDRs)
+		}
+		if c.APIWorldOpen() != conf.worldOpen {
+			t.Errorf("expected APIWorldOpen() to be %v for apiAccessCIDRs %v", conf.worldOpen, c.APIAccessCIDRs)
+		}
+		if warned := hasWarning(c.Warnings(), "apiAccessCIDRs"); warned != conf.warned {
+			t.Errorf("expected a warning about apiAccessCIDRs to be %v for config %q, got %v", conf.warned, conf.conf, c.Warnings())
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+apiAccessCIDRs:
+  - 203.0.113.0/33
+`, `
+apiAccessCIDRs:
+  - 2001:db8::/32 # security group CidrIp rules are IPv4 only
+`, `
+controllerCount: 2 # workers reach the public load balancer from public IPs
+apiAccessCIDRs:
+  - 203.0.113.0/24
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+
+	for _, conf := range []string{`
+controllerCount: 2
+natMode: gateway
+publicCIDR: 10.0.128.0/24
+apiAccessCIDRs:
+  - 203.0.113.0/24
+`, `
+controllerCount: 2
+apiEndpointInternal: true
+natMode: gateway
+publicCIDR: 10.0.128.0/24
+apiAccessCIDRs:
+  - 10.0.0.0/16
+`,
+	} {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+			continue
+		}
+		if warnings := c.Warnings(); hasWarning(warnings, "apiAccessCIDRs") {
+			t.Errorf("expected no warning about apiAccessCIDRs for config %q, got %v", conf, warnings)
+		}
+	}
+
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+apiEndpointInternal: true
+natMode: gateway
+publicCIDR: 10.0.128.0/24
+apiAccessCIDRs:
+  - 203.0.113.0/24
+`))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if warnings := c.Warnings(); !hasWarning(warnings, "203.0.113.0/24, which is outside vpcCIDR") {
+		t.Errorf("expected a warning about apiAccessCIDRs outside the VPC, got %v", warnings)
+	}
+
+	c, err = ClusterFromBytes([]byte(singleAzConfigYaml + `
+vpcPeering:
+  peerVpcId: vpc-0a1b2c3d
+  peerCIDR: 172.16.0.0/16
+`))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	for cidr, reachable := range map[string]bool{
+		"10.0.1.0/24":    true,  // within vpcCIDR
+		"10.0.0.0/8":     true,  // contains vpcCIDR
+		"172.16.5.0/24":  true,  // within peerCIDR
+		"203.0.113.0/24": false, // on the internet
+	} {
+		if c.reachableFromVPC(cidr) != reachable {
+			t.Errorf("expected reachableFromVPC(%s) to be %v", cidr, reachable)
+		}
+	}
+}
+
+func TestKubeletResources(t *testing.T) {
+	validConfigs := []struct {
+		conf           string
+		maxPods        int
+		kubeReserved   string
+		systemReserved string
+	}{
+		{
+			conf: ``,
+		},
+		{
+			conf: `
+maxPods: 250
+kubeReserved:
+  memory: 512Mi
+  cpu: 200m
+systemReserved:
+  cpu: "1"
+  memory: 1.5Gi
+`,
+			maxPods:        250,
+			kubeReserved:   "cpu=200m,memory=512Mi",
+			systemReserved: "cpu=1,memory=1.5Gi",
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		if c.MaxPods != conf.maxPods {
+			t.Errorf("expected maxPods %d, got %d", conf.maxPods, c.MaxPods)
+		}
+		if s := c.KubeReservedString(); s != conf.kubeReserved {
+			t.Errorf("expected --kube-reserved=%s, got %s", conf.kubeReserved, s)
+		}
+		if s := c.SystemReservedString(); s != conf.systemReserved {
+			t.Errorf("expected --system-reserved=%s, got %s", conf.systemReserved, s)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+maxPods: -1
+`, `
+kubeReserved:
+  cpu: 100 millicores
+`, `
+systemReserved:
+  memory: 1GB # 1G or 1Gi
+`, `
+kubeReserved:
+  gpu: "1" # only cpu and memory can be reserved
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestExternalDNSName(t *testing.T) {
+	validConfigs := []struct {
+		conf    string
+		dnsName string
+	}{
+		{
+			conf:    ``,
+			dnsName: "test.staging.core-os.net",
+		},
+		{
+			conf: `
+externalDNSName: k8s.example.com. # fully qualified
+`,
+			dnsName: "k8s.example.com",
+		},
+		{
+			conf: `
+externalDNSName: api
+`,
+			dnsName: "api",
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		if c.ExternalDNSName != conf.dnsName {
+			t.Errorf("expected externalDNSName %q, got %q", conf.dnsName, c.ExternalDNSName)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+externalDNSName: https://k8s.example.com
+`, `
+externalDNSName: k8s.example.com/api
+`, `
+externalDNSName: k8s.example.com:443
+`, `
+externalDNSName: -k8s.example.com
+`, `
+externalDNSName: k8s..example.com
+`, `
+externalDNSName: k8s_api.example.com
+`, `
+externalDNSName: ` + strings.Repeat("a", 64) + `.example.com
+`, `
+externalDNSName: ` + strings.Repeat("abcdefghi.", 25) + `example.com
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	validConfigs := []struct {
+		conf   string
+		images map[string]string
+	}{
+		{
+			conf: ``,
+			images: map[string]string{
+				"quay.io/coreos/hyperkube":               "quay.io/coreos/hyperkube",
+				"gcr.io/google_containers/kube2sky:1.14": "gcr.io/google_containers/kube2sky:1.14",
+			},
+		},
+		{
+			conf: `
+imageRepository: registry.example.com:5000
+`,
+			images: map[string]string{
+				"quay.io/coreos/hyperkube":               "registry.example.com:5000/coreos/hyperkube",
+				"gcr.io/google_containers/kube2sky:1.14": "registry.example.com:5000/google_containers/kube2sky:1.14",
+				"calico/k8s-policy-agent:v0.1.4":         "registry.example.com:5000/calico/k8s-policy-agent:v0.1.4",
+			},
+		},
+		{
+			conf: `
+imageRepository: 10.0.0.50/mirror
+hyperkubeImageRepo: quay.io/example/hyperkube
+`,
+			images: map[string]string{
+				"quay.io/example/hyperkube": "10.0.0.50/mirror/example/hyperkube",
+				"localhost/pause:2.0":       "10.0.0.50/mirror/pause:2.0",
+				"quay.io/coreos/awscli":     "10.0.0.50/mirror/coreos/awscli",
+			},
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		for image, expected := range conf.images {
+			if actual := c.Image(image); actual != expected {
+				t.Errorf("expected image %s to be pulled as %s, got %s", image, expected, actual)
+			}
+			if c.ImageRepository != "" {
+				expected = "docker://" + expected
+			}
+			if actual := c.RktImage(image); actual != expected {
+				t.Errorf("expected image %s to be run by rkt as %s, got %s", image, expected, actual)
+			}
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+imageRepository: https://registry.example.com
+`, `
+imageRepository: registry.example.com/
+`, `
+imageRepository: registry.example.com/Mirror
+`, `
+imageRepository: -registry.example.com
+`, `
+hyperkubeImageRepo: quay.io/coreos/hyperkube:v1.2.4_coreos.1 # the tag is kubernetesVersion
+`, `
+hyperkubeImageRepo: ""
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestDockerConfigJSON(t *testing.T) {
+	validConfigs := []string{
+		``,
+		`
+dockerConfigJSON: |
+  {"auths": {"registry.example.com:5000": {"auth": "dXNlcjpwYXNzd29yZA=="}}}
+`,
+	}
+	for _, conf := range validConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+dockerConfigJSON: |
+  {"auths": {"registry.example.com:5000": {"auth": "dXNlcjpwYXNzd29yZA=="}}
+`, `
+dockerConfigJSON: '["registry.example.com:5000"]' # must be an object
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestDNSDomain(t *testing.T) {
+	validConfigs := []struct {
+		conf      string
+		dnsDomain string
+	}{
+		{
+			conf:      ``,
+			dnsDomain: "cluster.local",
+		},
+		{
+			conf: `
+dnsDomain: staging.example.internal
+`,
+			dnsDomain: "staging.example.internal",
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		if c.DNSDomain != conf.dnsDomain {
+			t.Errorf("expected dnsDomain %s, got %s", conf.dnsDomain, c.DNSDomain)
+		}
+
+		// The apiserver is reached in-cluster by its service name
+		assets, err := c.NewTLSAssets()
+		if err != nil {
+			t.Fatalf("failed generating tls: %v", err)
+		}
+		missing, err := assets.missingAPIServerSANs([]string{"kubernetes.default.svc." + conf.dnsDomain})
+		if err != nil {
+			t.Fatalf("failed to check apiserver cert: %v", err)
+		}
+		if len(missing) > 0 {
+			t.Errorf("expected apiserver cert to be valid for %v", missing)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+dnsDomain: ""
+`, `
+dnsDomain: cluster.local.
+`, `
+dnsDomain: cluster_local
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestFlannelBackend(t *testing.T) {
+	validConfigs := []struct {
+		conf   string
+		routes int
+	}{
+		{
+			conf: ``,
+		},
+		{
+			conf: `
+flannelBackend: host-gw
+`,
+		},
+		{
+			conf: `
+flannelBackend: aws-vpc
+controllerCount: 2
+workerCount: 3
+`,
+			routes: 5,
+		},
+		{
+			conf: `
+flannelBackend: aws-vpc
+availabilityZone: ""
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.0.0.0/24
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.0.1.0/24
+`,
+			routes: 2,
+		},
+	}
+	for _, conf := range validConfigs {
+		c, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf.conf))
+		if err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf.conf, err)
+			continue
+		}
+		if routes := c.FlannelRoutes(); routes != conf.routes {
+			t.Errorf("expected flannel to add %d routes, got %d", conf.routes, routes)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+flannelBackend: udp
+`, `
+flannelBackend: host-gw
+networkPlugin: calico
+`, `
+flannelBackend: host-gw # needs a single subnet
+availabilityZone: ""
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.0.0.0/24
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.0.1.0/24
+`, `
+flannelBackend: aws-vpc # gateway NAT has a route table per subnet
+natMode: gateway
+availabilityZone: ""
+subnets:
+  - availabilityZone: us-west-1a
+    instanceCIDR: 10.0.0.0/24
+    publicCIDR: 10.0.100.0/24
+  - availabilityZone: us-west-1b
+    instanceCIDR: 10.0.1.0/24
+    publicCIDR: 10.0.101.0/24
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestWorkerPools(t *testing.T) {
+	c, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+amiId: ami-0123abcd
+workerCount: 2
+workerInstanceType: m4.large
+workerNodeLabels:
+  role: general
+workerPools:
+- name: general
+- name: gpu-large
+  count: 3
+  instanceType: p2.xlarge
+  rootVolumeSize: 100
+  rootVolumeType: gp2
+  nodeLabels: {}
+  nodeTaints:
+  - key: dedicated
+    value: gpu
+    effect: NoSchedule
+`))
+	if err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	// Settings a pool leaves unset come from the worker* settings
+	general, gpu := c.WorkerPools[0], c.WorkerPools[1]
+	if general.Count != 2 || general.InstanceType != "m4.large" || general.RootVolumeSize != 30 || general.NodeLabels["role"] != "general" {
+		t.Errorf("expected pool general to take the worker* settings, got %+v", general)
+	}
+	if gpu.Count != 3 || gpu.InstanceType != "p2.xlarge" || gpu.RootVolumeSize != 100 || len(gpu.NodeLabels) != 0 || len(gpu.NodeTaints) != 1 {
+		t.Errorf("expected pool gpu-large to keep its own settings, got %+v", gpu)
+	}
+	if c.WorkerCount != 5 || c.WorkerMaxSize() != 5 {
+		t.Errorf("expected 5 workers across the pools, got workerCount %d and max size %d", c.WorkerCount, c.WorkerMaxSize())
+	}
+
+	r := newTestRenderer(t)
+	defer r.cleanup()
+	assets := r.renderCluster(c)
+
+	var stack struct {
+		Resources map[string]struct {
+			Properties struct {
+				DesiredCapacity         string
+				InstanceType            string
+				LaunchConfigurationName map[string]string
+			}
+		}
+	}
+	if err := json.Unmarshal(assets.StackTemplate, &stack); err != nil {
+		t.Fatalf("rendered stack template is not valid json: %v", err)
+	}
+	for _, pool := range []struct {
+		suffix, count, instanceType string
+	}{
+		{"General", "2", "m4.large"},
+		{"GpuLarge", "3", "p2.xlarge"},
+	} {
+		asg, ok := stack.Resources["AutoScaleWorker"+pool.suffix]
+		if !ok {
+			t.Errorf("expected autoscaling group AutoScaleWorker%s", pool.suffix)
+			continue
+		}
+		if asg.Properties.DesiredCapacity != pool.count {
+			t.Errorf("expected AutoScaleWorker%s to have %s workers, got %s", pool.suffix, pool.count, asg.Properties.DesiredCapacity)
+		}
+		if ref := asg.Properties.LaunchConfigurationName["Ref"]; ref != "LaunchConfigurationWorker"+pool.suffix {
+			t.Errorf("expected AutoScaleWorker%s to use LaunchConfigurationWorker%s, got %s", pool.suffix, pool.suffix, ref)
+		}
+		if lc := stack.Resources["LaunchConfigurationWorker"+pool.suffix]; lc.Properties.InstanceType != pool.instanceType {
+			t.Errorf("expected LaunchConfigurationWorker%s to use %s, got %q", pool.suffix, pool.instanceType, lc.Properties.InstanceType)
+		}
+	}
+	if _, ok := stack.Resources["AutoScaleWorker"]; ok {
+		t.Errorf("expected no AutoScaleWorker with workerPools set")
+	}
+
+	if !bytes.Contains(assets.UserDataWorkers["general"], []byte("--node-labels=role=general")) {
+		t.Errorf("expected workers of pool general to be labelled role=general")
+	}
+	gpuUserData := assets.UserDataWorkers["gpu-large"]
+	if !bytes.Contains(gpuUserData, []byte("--register-with-taints=dedicated=gpu:NoSchedule")) || bytes.Contains(gpuUserData, []byte("--node-labels")) {
+		t.Errorf("expected workers of pool gpu-large to be tainted and not labelled")
+	}
+
+	invalidConfigs := []string{
+		`
+workerPools:
+- count: 1
+`, `
+workerPools:
+- name: gpu
+- name: gpu
+`, `
+workerPools:
+- name: gpu-large
+- name: gpuLarge
+`, `
+workerPools:
+- name: a-1b
+- name: a1b
+`, `
+workerPools:
+- name: gpu
+  count: -1
+`, `
+workerPools:
+- name: gpu
+  instanceType: large
+`, `
+workerPools:
+- name: gpu
+  rootVolumeType: io1
+`, `
+workerPools:
+- name: gpu
+  nodeTaints:
+  - key: dedicated
+    effect: Sometimes
+`, `
+workerCount: 2
+workerAutoScaling:
+  minSize: 1
+  maxSize: 3
+  targetCPUUtilization: 70
+workerPools:
+- name: gpu
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestInstanceTenancy(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	validConfigs := []struct {
+		conf             string
+		vpcTenancy       string
+		placementTenancy string
+	}{
+		{
+			conf:       ``,
+			vpcTenancy: "default",
+		},
+		{
+			conf: `
+instanceTenancy: dedicated
+`,
+			vpcTenancy: "dedicated",
+		},
+		{
+			conf: `
+vpcId: vpc-xxxxx
+`,
+		},
+		{
+			// An existing VPC may have default tenancy, so the nodes ask for
+			// dedicated hardware themselves
+			conf: `
+vpcId: vpc-xxxxx
+instanceTenancy: dedicated
+`,
+			placementTenancy: "dedicated",
+		},
+	}
+	for _, conf := range validConfigs {
+		var stack struct {
+			Resources map[string]struct {
+				Type       string
+				Properties struct {
+					InstanceTenancy  string
+					PlacementTenancy string
+					Tenancy          string
+				}
+			}
+		}
+		r.renderStack(conf.conf, &stack)
+		for name, resource := range stack.Resources {
+			switch resource.Type {
+			case "AWS::EC2::VPC":
+				if resource.Properties.InstanceTenancy != conf.vpcTenancy {
+					t.Errorf("expected VPC tenancy %q for config %q, got %q", conf.vpcTenancy, conf.conf, resource.Properties.InstanceTenancy)
+				}
+			case "AWS::EC2::Instance":
+				if resource.Properties.Tenancy != conf.placementTenancy {
+					t.Errorf("expected %s tenancy %q for config %q, got %q", name, conf.placementTenancy, conf.conf, resource.Properties.Tenancy)
+				}
+			case "AWS::AutoScaling::LaunchConfiguration":
+				if resource.Properties.PlacementTenancy != conf.placementTenancy {
+					t.Errorf("expected %s placement tenancy %q for config %q, got %q", name, conf.placementTenancy, conf.conf, resource.Properties.PlacementTenancy)
+				}
+			}
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+instanceTenancy: ""
+`, `
+instanceTenancy: host
+`, `
+instanceTenancy: Dedicated
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestCloudWatchLogging(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	validConfigs := []struct {
+		conf          string
+		logGroupName  string
+		retentionDays int
+	}{
+		{
+			conf: `
+cloudWatchLogging:
+  enabled: true
+`,
+			logGroupName: "/kube-aws/test-cluster-name",
+		},
+		{
+			conf: `
+stackName: test-stack
+cloudWatchLogging:
+  enabled: true
+  retentionDays: 30
+`,
+			logGroupName:  "/kube-aws/test-stack",
+			retentionDays: 30,
+		},
+		{
+			conf: `
+cloudWatchLogging:
+  enabled: true
+  retentionDays: 3653
+  logGroupName: k8s/prod#1
+`,
+			logGroupName:  "k8s/prod#1",
+			retentionDays: 3653,
+		},
+	}
+	for _, conf := range validConfigs {
+		var stack struct {
+			Resources map[string]struct {
+				Properties struct {
+					LogGroupName    string
+					RetentionInDays int
+				}
+			}
+		}
+		assets := r.renderStack(conf.conf, &stack)
+		logGroup, ok := stack.Resources["CloudWatchLogGroup"]
+		if !ok {
+			t.Errorf("expected a log group for config %q", conf.conf)
+			continue
+		}
+		if logGroup.Properties.LogGroupName != conf.logGroupName || logGroup.Properties.RetentionInDays != conf.retentionDays {
+			t.Errorf("expected log group %s keeping logs %d days, got %+v", conf.logGroupName, conf.retentionDays, logGroup.Properties)
+		}
+		if !bytes.Contains(assets.StackTemplate, []byte(`"logs:PutLogEvents"`)) {
+			t.Errorf("expected the nodes to be allowed to put log events")
+		}
+
+		awslogsGroup := []byte("--log-opt awslogs-group=" + conf.logGroupName + " ")
+		for name, userData := range map[string][]byte{
+			"controller": assets.UserDataController,
+			"worker":     assets.UserDataWorkers[""],
+			"etcd":       assets.UserDataEtcd,
+		} {
+			if !bytes.Contains(userData, awslogsGroup) {
+				t.Errorf("expected %s journals to be shipped to %s", name, conf.logGroupName)
+			}
+			if !bytes.Contains(userData, []byte("--cursor-file=/var/lib/cloudwatch-logs/cursor")) {
+				t.Errorf("expected the %s journal shipper to resume from a cursor", name)
+			}
+		}
+		if _, ok := stack.Resources["IAMInstanceProfileEtcd"]; !ok {
+			t.Errorf("expected an instance profile allowing etcd nodes to put log events")
+		}
+	}
+
+	assets := r.render("")
+	if bytes.Contains(assets.StackTemplate, []byte("CloudWatchLogGroup")) || bytes.Contains(assets.StackTemplate, []byte("IAMInstanceProfileEtcd")) ||
+		bytes.Contains(assets.UserDataWorkers[""], []byte("cloudwatch-logs.service")) || bytes.Contains(assets.UserDataEtcd, []byte("cloudwatch-logs.service")) {
+		t.Errorf("expected no CloudWatch logging unless enabled")
+	}
+
+	invalidConfigs := []string{
+		`
+cloudWatchLogging:
+  enabled: true
+  retentionDays: 10
+`, `
+cloudWatchLogging:
+  enabled: true
+  retentionDays: -1
+`, `
+cloudWatchLogging:
+  enabled: true
+  logGroupName: "kube aws"
+`, `
+cloudWatchLogging:
+  enabled: true
+  logGroupName: kube-aws:logs
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestAPIELBACMCertARN(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	certARN := "arn:aws:acm:us-west-1:123456789012:certificate/12345678-1234-1234-1234-123456789012"
+	for _, conf := range []struct {
+		conf      string
+		listeners []string
+	}{
+		{
+			conf:      ``,
+			listeners: []string{"TCP:443"},
+		},
+		{
+			conf: `
+apiELBACMCertArn: ` + certARN + `
+`,
+			listeners: []string{"TCP:443", "HTTPS:8443"},
+		},
+	} {
+		var stack struct {
+			Resources map[string]struct {
+				Properties struct {
+					Listeners []struct {
+						LoadBalancerPort string
+						Protocol         string
+						SSLCertificateId string
+					}
+				}
+			}
+		}
+		r.renderStack(conf.conf, &stack)
+		listeners := []string{}
+		for _, listener := range stack.Resources["ElbAPIServer"].Properties.Listeners {
+			listeners = append(listeners, listener.Protocol+":"+listener.LoadBalancerPort)
+			if listener.Protocol == "HTTPS" && listener.SSLCertificateId != certARN {
+				t.Errorf("expected the HTTPS listener to use %s, got %q", certARN, listener.SSLCertificateId)
+			}
+		}
+		if !reflect.DeepEqual(listeners, conf.listeners) {
+			t.Errorf("expected API load balancer listeners %v for config %q, got %v", conf.listeners, conf.conf, listeners)
+		}
+	}
+
+	invalidConfigs := []string{
+		`
+# not an ACM certificate
+apiELBACMCertArn: arn:aws:iam::123456789012:server-certificate/kube-api
+`, `
+# certificate in another region
+apiELBACMCertArn: arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012
+`, `
+# certificate in another partition
+apiELBACMCertArn: arn:aws-cn:acm:us-west-1:123456789012:certificate/12345678-1234-1234-1234-123456789012
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestManageRecordSet(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	for _, conf := range []struct {
+		conf   string
+		record bool
+	}{
+		{
+			conf: `
+createRecordSet: true
+hostedZone: staging.core-os.net
+hostedZoneId: Z1D633PJN98FT9
+`,
+			record: true,
+		},
+		{
+			// The record is checked for but left to whoever manages the zone
+			conf: `
+createRecordSet: true
+manageRecordSet: false
+hostedZone: staging.core-os.net
+hostedZoneId: Z1D633PJN98FT9
+`,
+			record: false,
+		},
+	} {
+		var stack struct {
+			Resources map[string]struct {
+				Type string
+			}
+		}
+		r.renderStack(conf.conf, &stack)
+		record := false
+		for _, resource := range stack.Resources {
+			record = record || resource.Type == "AWS::Route53::RecordSet"
+		}
+		if record != conf.record {
+			t.Errorf("expected record set %t for config %q, got %t", conf.record, conf.conf, record)
+		}
+	}
+
+	if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+createRecordSet: false
+manageRecordSet: false
+`)); err == nil {
+		t.Errorf("expected error setting manageRecordSet without createRecordSet")
+	}
+}
+
+func TestKubeProxyMode(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	validConfigs := []struct {
+		conf        string
+		mode        string
+		featureGate bool
+	}{
+		{
+			conf: "",
+			mode: "iptables",
+		},
+		{
+			conf: "kubeProxyMode: ipvs\nkubernetesVersion: v1.9.3_coreos.0\n",
+			mode: "ipvs",
+			// IPVS mode is behind a feature gate before v1.11
+			featureGate: true,
+		},
+		{
+			conf: "kubeProxyMode: ipvs\nkubernetesVersion: v1.11.0_coreos.0\n",
+			mode: "ipvs",
+		},
+	}
+	for _, conf := range validConfigs {
+		assets := r.render(conf.conf)
+		for name, userData := range map[string][]byte{
+			"controller": assets.UserDataController,
+			"worker":     assets.UserDataWorkers[""],
+		} {
+			if !bytes.Contains(userData, []byte("--proxy-mode="+conf.mode+"\n")) {
+				t.Errorf("expected %s kube-proxy in %s mode for config %q", name, conf.mode, conf.conf)
+			}
+			if featureGate := bytes.Contains(userData, []byte("--feature-gates=SupportIPVSProxyMode=true")); featureGate != conf.featureGate {
+				t.Errorf("expected %s SupportIPVSProxyMode feature gate %t for config %q, got %t", name, conf.featureGate, conf.conf, featureGate)
+			}
+			if modules := bytes.Contains(userData, []byte("/etc/modules-load.d/ip_vs.conf")); modules != (conf.mode == "ipvs") {
+				t.Errorf("expected %s IPVS kernel modules loaded %t for config %q, got %t", name, conf.mode == "ipvs", conf.conf, modules)
+			}
+		}
+	}
+
+	for _, mode := range []string{"userspace", "IPVS", "nftables"} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "kubeProxyMode: " + mode + "\n")); err == nil {
+			t.Errorf("expected error parsing invalid kubeProxyMode %s", mode)
+		}
+	}
+}
+
+func TestMetadataOptions(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	type metadataOptions struct {
+		HttpEndpoint            string
+		HttpPutResponseHopLimit int
+		HttpTokens              string
+	}
+	var stack struct {
+		Resources map[string]struct {
+			Properties struct {
+				LaunchTemplate struct {
+					LaunchTemplateId map[string]string
+				}
+				LaunchTemplateData struct {
+					MetadataOptions metadataOptions
+				}
+				MetadataOptions *metadataOptions
+			}
+		}
+	}
+
+	r.renderStack(`
+metadataOptions:
+  httpTokens: required
+  httpPutResponseHopLimit: 1
+`, &stack)
+
+	expected := metadataOptions{HttpEndpoint: "enabled", HttpPutResponseHopLimit: 1, HttpTokens: "required"}
+	launchTemplate, ok := stack.Resources["LaunchTemplateInstance"]
+	if !ok {
+		t.Fatalf("expected a launch template carrying the metadata options")
+	}
+	if launchTemplate.Properties.LaunchTemplateData.MetadataOptions != expected {
+		t.Errorf("expected launch template metadata options %+v, got %+v", expected, launchTemplate.Properties.LaunchTemplateData.MetadataOptions)
+	}
+	for _, name := range []string{"InstanceController", "InstanceEtcd"} {
+		if ref := stack.Resources[name].Properties.LaunchTemplate.LaunchTemplateId["Ref"]; ref != "LaunchTemplateInstance" {
+			t.Errorf("expected %s to be launched from LaunchTemplateInstance, got %q", name, ref)
+		}
+	}
+	if options := stack.Resources["LaunchConfigurationWorker"].Properties.MetadataOptions; options == nil || *options != expected {
+		t.Errorf("expected worker launch configuration metadata options %+v, got %+v", expected, options)
+	}
+
+	if assets := r.render(""); bytes.Contains(assets.StackTemplate, []byte("MetadataOptions")) {
+		t.Errorf("expected the EC2 metadata defaults unless metadataOptions is set")
+	}
+
+	invalidConfigs := []string{
+		`
+metadataOptions:
+  httpTokens: always
+`, `
+metadataOptions:
+  httpPutResponseHopLimit: -1
+`, `
+metadataOptions:
+  httpPutResponseHopLimit: 65
+`,
+	}
+	for _, conf := range invalidConfigs {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing invalid config %q", conf)
+		}
+	}
+}
+
+func TestUseLaunchTemplate(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	type resource struct {
+		Properties   map[string]json.RawMessage
+		Type         string
+		UpdatePolicy struct {
+			AutoScalingRollingUpdate *struct {
+				MaxBatchSize string
+			}
+		}
+	}
+	render := func(conf string) map[string]resource {
+		var stack struct {
+			Resources map[string]resource
+		}
+		r.renderStack(conf, &stack)
+		return stack.Resources
+	}
+
+	resources := render("")
+	if _, ok := resources["LaunchConfigurationWorker"]; !ok {
+		t.Errorf("expected workers to be launched from a launch configuration by default")
+	}
+	if _, ok := resources["AutoScaleWorker"].Properties["MixedInstancesPolicy"]; ok {
+		t.Errorf("expected no mixed instances policy by default")
+	}
+
+	resources = render(`
+useLaunchTemplate: true
+workerSpotPrice: "0.05"
+workerRootVolumeType: io1
+workerRootVolumeIOPS: 300
+workerPools:
+  - name: general
+    count: 2
+  - name: gpu-large
+    count: 1
+    instanceType: p2.xlarge
+`)
+	for _, pool := range []struct{ suffix, instanceType string }{
+		{"General", "m3.medium"},
+		{"GpuLarge", "p2.xlarge"},
+	} {
+		if _, ok := resources["LaunchConfigurationWorker"+pool.suffix]; ok {
+			t.Errorf("expected no launch configuration for pool %s", pool.suffix)
+		}
+		launchTemplate, ok := resources["LaunchTemplateWorker"+pool.suffix]
+		if !ok || launchTemplate.Type != "AWS::EC2::LaunchTemplate" {
+			t.Errorf("expected a launch template for pool %s", pool.suffix)
+			continue
+		}
+		var data struct {
+			BlockDeviceMappings []struct {
+				Ebs struct {
+					Iops       string
+					VolumeType string
+				}
+			}
+			InstanceType string
+			UserData     string
+		}
+		if err := json.Unmarshal(launchTemplate.Properties["LaunchTemplateData"], &data); err != nil {
+			t.Fatalf("failed to decode launch template data: %v", err)
+		}
+		if data.InstanceType != pool.instanceType || data.UserData == "" {
+			t.Errorf("expected launch template of pool %s to launch %s with user data, got %+v", pool.suffix, pool.instanceType, data)
+		}
+		if len(data.BlockDeviceMappings) != 1 || data.BlockDeviceMappings[0].Ebs.VolumeType != "io1" || data.BlockDeviceMappings[0].Ebs.Iops != "300" {
+			t.Errorf("expected the worker root volume in the launch template of pool %s, got %+v", pool.suffix, data.BlockDeviceMappings)
+		}
+
+		asg := resources["AutoScaleWorker"+pool.suffix]
+		if _, ok := asg.Properties["LaunchConfigurationName"]; ok {
+			t.Errorf("expected pool %s not to reference a launch configuration", pool.suffix)
+		}
+		var policy struct {
+			InstancesDistribution struct {
+				OnDemandPercentageAboveBaseCapacity int
+				SpotMaxPrice                        string
+			}
+			LaunchTemplate struct {
+				LaunchTemplateSpecification struct {
+					LaunchTemplateId map[string]string
+				}
+				Overrides []struct {
+					InstanceType string
+				}
+			}
+		}
+		if err := json.Unmarshal(asg.Properties["MixedInstancesPolicy"], &policy); err != nil {
+			t.Fatalf("failed to decode mixed instances policy of pool %s: %v", pool.suffix, err)
+		}
+		if ref := policy.LaunchTemplate.LaunchTemplateSpecification.LaunchTemplateId["Ref"]; ref != "LaunchTemplateWorker"+pool.suffix {
+			t.Errorf("expected pool %s to launch from LaunchTemplateWorker%s, got %q", pool.suffix, pool.suffix, ref)
+		}
+		if policy.InstancesDistribution.SpotMaxPrice != "0.05" || policy.InstancesDistribution.OnDemandPercentageAboveBaseCapacity != 0 {
+			t.Errorf("expected pool %s to launch spot instances at up to 0.05, got %+v", pool.suffix, policy.InstancesDistribution)
+		}
+		if len(policy.LaunchTemplate.Overrides) != 1 || policy.LaunchTemplate.Overrides[0].InstanceType != pool.instanceType {
+			t.Errorf("expected pool %s to override the instance type with %s, got %+v", pool.suffix, pool.instanceType, policy.LaunchTemplate.Overrides)
+		}
+	}
+
+	// Switching an existing stack must update its worker groups in place, by a
+	// rolling update, rather than replace them along with every worker at once
+	workerPools := `
+workerPools:
+  - name: general
+    count: 2
+  - name: gpu
+    count: 1
+    instanceType: p2.xlarge
+`
+	before := render(workerPools)
+	after := render("useLaunchTemplate: true\n" + workerPools)
+	for name, resource := range before {
+		if strings.HasPrefix(name, "LaunchConfigurationWorker") {
+			if _, ok := after[name]; ok {
+				t.Errorf("expected %s to be removed when switching to launch templates", name)
+			}
+			if _, ok := after["LaunchTemplateWorker"+strings.TrimPrefix(name, "LaunchConfigurationWorker")]; !ok {
+				t.Errorf("expected a launch template to replace %s", name)
+			}
+			continue
+		}
+		switched, ok := after[name]
+		if !ok || switched.Type != resource.Type {
+			t.Errorf("expected %s to be kept when switching to launch templates", name)
+		}
+	}
+	for _, suffix := range []string{"General", "Gpu"} {
+		if update := after["AutoScaleWorker"+suffix].UpdatePolicy.AutoScalingRollingUpdate; update == nil || update.MaxBatchSize != "1" {
+			t.Errorf("expected pool %s to replace its workers one at a time, got %+v", suffix, update)
+		}
+	}
+}
+
+func TestWorkerInstanceTypes(t *testing.T) {
+	r := newTestRenderer(t)
+	defer r.cleanup()
+
+	type policy struct {
+		InstancesDistribution struct {
+			OnDemandBaseCapacity                int
+			OnDemandPercentageAboveBaseCapacity int
+			SpotAllocationStrategy              string
+			SpotMaxPrice                        string
+		}
+		LaunchTemplate struct {
+			Overrides []struct {
+				InstanceType string
+			}
+		}
+	}
+	render := func(conf string) map[string]policy {
+		var stack struct {
+			Resources map[string]struct {
+				Properties struct {
+					MixedInstancesPolicy *policy
+				}
+			}
+		}
+		r.renderStack("useLaunchTemplate: true\n"+conf, &stack)
+		policies := map[string]policy{}
+		for name, resource := range stack.Resources {
+			if resource.Properties.MixedInstancesPolicy != nil {
+				policies[name] = *resource.Properties.MixedInstancesPolicy
+			}
+		}
+		return policies
+	}
+	instanceTypes := func(p policy) []string {
+		types := []string{}
+		for _, override := range p.LaunchTemplate.Overrides {
+			types = append(types, override.InstanceType)
+		}
+		return types
+	}
+
+	p := render("")["AutoScaleWorker"]
+	if types := instanceTypes(p); !reflect.DeepEqual(types, []string{"m3.medium"}) {
+		t.Errorf("expected workerInstanceType to be the only override by default, got %v", types)
+	}
+	if p.InstancesDistribution.OnDemandBaseCapacity != 0 || p.InstancesDistribution.OnDemandPercentageAboveBaseCapacity != 100 {
+		t.Errorf("expected only on-demand workers by default, got %+v", p.InstancesDistribution)
+	}
+	if p.InstancesDistribution.SpotAllocationStrategy != "capacity-optimized" {
+		t.Errorf("expected spot instances from the pools with the most capacity, got %q", p.InstancesDistribution.SpotAllocationStrategy)
+	}
+
+	p = render(`
+workerInstanceTypes: [m5.large, m5a.large, m4.large]
+workerOnDemandBaseCapacity: 1
+workerOnDemandPercentageAboveBaseCapacity: 25
+`)["AutoScaleWorker"]
+	if types := instanceTypes(p); !reflect.DeepEqual(types, []string{"m5.large", "m5a.large", "m4.large"}) {
+		t.Errorf("expected workerInstanceTypes as overrides, got %v", types)
+	}
+	if d := p.InstancesDistribution; d.OnDemandBaseCapacity != 1 || d.OnDemandPercentageAboveBaseCapacity != 25 || d.SpotMaxPrice != "" {
+		t.Errorf("expected 1 on-demand worker then 25%% on-demand, got %+v", d)
+	}
+
+	policies := render(`
+workerSpotPrice: "0.05"
+workerInstanceTypes: [m5.large, m5a.large]
+workerPools:
+  - name: inherited
+  - name: single
+    instanceType: t3.large
+  - name: amd
+    instanceTypes: [m5a.large, c5a.large]
+`)
+	for _, pool := range []struct {
+		suffix        string
+		instanceTypes []string
+	}{
+		{"Inherited", []string{"m5.large", "m5a.large"}},
+		{"Single", []string{"t3.large"}},
+		{"Amd", []string{"m5a.large", "c5a.large"}},
+	} {
+		p := policies["AutoScaleWorker"+pool.suffix]
+		if types := instanceTypes(p); !reflect.DeepEqual(types, pool.instanceTypes) {
+			t.Errorf("expected pool %s to override the instance type with %v, got %v", pool.suffix, pool.instanceTypes, types)
+		}
+		if d := p.InstancesDistribution; d.OnDemandPercentageAboveBaseCapacity != 0 || d.SpotMaxPrice != "0.05" {
+			t.Errorf("expected pool %s to launch spot instances at up to 0.05, got %+v", pool.suffix, d)
+		}
+	}
+
+	for _, conf := range []string{
+		"workerInstanceTypes: []\n",
+		"workerInstanceTypes: [m5.large, bogus]\n",
+		"workerInstanceTypes: [m5.large, m5.large]\n",
+		"workerInstanceTypes: [m5.large, m6g.large]\n",
+		"workerInstanceTypes: [a1.large]\n",
+		"workerInstanceType: c6gn.large\n",
+		"controllerInstanceType: t4g.medium\n",
+		"etcdInstanceType: m6g.medium\n",
+		"workerPools:\n  - name: arm\n    instanceType: t4g.large\n",
+		"workerOnDemandBaseCapacity: -1\n",
+		"workerOnDemandPercentageAboveBaseCapacity: 101\n",
+		"workerPools:\n  - name: arm\n    instanceTypes: [t4g.large, t3.large]\n",
+		"workerPools:\n  - name: both\n    instanceType: t3.large\n    instanceTypes: [t3a.large]\n",
+		"workerSpotPrice: \"0.05\"\nworkerOnDemandPercentageAboveBaseCapacity: 100\n",
+		"workerSpotPrice: \"0.05\"\ninstanceTenancy: dedicated\n",
+		"workerOnDemandPercentageAboveBaseCapacity: 50\ninstanceTenancy: dedicated\nvpcId: vpc-xxxxx\n",
+	} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "useLaunchTemplate: true\n" + conf)); err == nil {
+			t.Errorf("expected error parsing config %q", conf)
+		}
+	}
+
+	for _, conf := range []string{
+		"instanceTenancy: dedicated\n",
+		"workerSpotPrice: \"0.05\"\nworkerOnDemandPercentageAboveBaseCapacity: 50\n",
+	} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "useLaunchTemplate: true\n" + conf)); err != nil {
+			t.Errorf("failed to parse valid config %q: %v", conf, err)
+		}
+	}
+
+	for _, conf := range []string{
+		"workerInstanceTypes: [m5.large, m5a.large]\n",
+		"workerOnDemandBaseCapacity: 1\n",
+		"workerOnDemandPercentageAboveBaseCapacity: 50\n",
+		"workerPools:\n  - name: general\n    instanceTypes: [m5.large]\n",
+	} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + conf)); err == nil {
+			t.Errorf("expected error parsing config %q without useLaunchTemplate", conf)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Environment variables named KUBE_AWS_ followed by a cluster.yaml key, in
+// any case and with or without underscores between words, override that key.
+// KUBE_AWS_KEY_NAME and KUBE_AWS_KEYNAME both override keyName.
+const overrideEnvPrefix = "KUBE_AWS_"
+
+// OverridesFromEnv returns the overrides set by the KUBE_AWS_ variables of
+// environ, in the format of os.Environ. A variable that matches no key is an
+// error rather than being ignored, so a typo doesn't go unnoticed.
+func OverridesFromEnv(environ []string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, key := range overrideKeys() {
+		keys[strings.ToLower(key)] = key
+	}
+
+	overrides := map[string]string{}
+	for _, env := range environ {
+		if !strings.HasPrefix(env, overrideEnvPrefix) {
+			continue
+		}
+		name, value := env, ""
+		if i := strings.Index(env, "="); i >= 0 {
+			name, value = env[:i], env[i+1:]
+		}
+		normalized := strings.ToLower(strings.Replace(strings.TrimPrefix(name, overrideEnvPrefix), "_", "", -1))
+		key, ok := keys[normalized]
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s does not override any cluster.yaml key", name)
+		}
+		overrides[key] = value
+	}
+	return overrides, nil
+}
+
+// MergeOverrides merges layers of overrides, where a key set by a later
+// layer takes precedence over the same key set by an earlier one
+func MergeOverrides(layers ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, layer := range layers {
+		for key, value := range layer {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// overrideKeys are the cluster.yaml keys that can be overridden, which is
+// every top-level key
+func overrideKeys() []string {
+	t := reflect.TypeOf(Cluster{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("yaml"); key != "" && key != "-" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// applyOverrides replaces the values of the keys of overrides. String values
+// are taken as is; others are parsed as YAML, so a list is given as
+// "[a, b]" and a map as "{a: b}", and replace the whole list or map.
+func (c *Cluster) applyOverrides(overrides map[string]string) error {
+	fields := map[string]reflect.Value{}
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if key := v.Type().Field(i).Tag.Get("yaml"); key != "" && key != "-" {
+			fields[key] = v.Field(i)
+		}
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("unknown override key %q", key)
+		}
+		value := overrides[key]
+		if field.Kind() == reflect.String {
+			field.SetString(value)
+			continue
+		}
+		field.Set(reflect.Zero(field.Type()))
+		if err := yaml.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid value for override %s: %v", key, err)
+		}
+	}
+	return nil
+}
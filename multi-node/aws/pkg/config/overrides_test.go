@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverridePrecedence(t *testing.T) {
+	env, err := OverridesFromEnv([]string{
+		"HOME=/root",
+		"KUBE_AWS_KEY_NAME=env-key",
+		"KUBE_AWS_WORKERCOUNT=3",
+		"KUBE_AWS_STACK_TAGS={team: env}",
+	})
+	if err != nil {
+		t.Fatalf("failed to read overrides from environment: %v", err)
+	}
+	flags := map[string]string{
+		"keyName":        "flag-key",
+		"region":         "us-west-2",
+		"kmsKeyArn":      "arn:aws:kms:us-west-2:xxxxxxxxx:key/xxxxxxxxxxxxxxxxxxx",
+		"sshAccessCIDRs": "[10.0.0.0/8]",
+	}
+
+	c, err := ClusterFromBytesWithOverrides([]byte(singleAzConfigYaml+`
+availabilityZone: us-west-2a
+workerCount: 1
+stackTags:
+  team: yaml
+  owner: yaml
+sshAccessCIDRs: [192.168.0.0/16, 172.16.0.0/12]
+`), MergeOverrides(env, flags))
+	if err != nil {
+		t.Fatalf("failed to parse config with overrides: %v", err)
+	}
+
+	if c.KeyName != "flag-key" {
+		t.Errorf("expected flag to take precedence over environment and file, got keyName %q", c.KeyName)
+	}
+	if c.Region != "us-west-2" {
+		t.Errorf("expected flag to take precedence over file, got region %q", c.Region)
+	}
+	if c.WorkerCount != 3 {
+		t.Errorf("expected environment to take precedence over file, got workerCount %d", c.WorkerCount)
+	}
+	if c.ClusterName != "test-cluster-name" {
+		t.Errorf("expected keys without override to keep their value, got clusterName %q", c.ClusterName)
+	}
+	if !reflect.DeepEqual(c.StackTags, map[string]string{"team": "env"}) {
+		t.Errorf("expected a map override to replace the whole map, got %v", c.StackTags)
+	}
+	if !reflect.DeepEqual(c.SSHAccessCIDRs, []string{"10.0.0.0/8"}) {
+		t.Errorf("expected a list override to replace the whole list, got %v", c.SSHAccessCIDRs)
+	}
+
+	c, err = ClusterFromBytesWithOverrides([]byte(singleAzConfigYaml), map[string]string{"keyName": "0123"})
+	if err != nil {
+		t.Fatalf("failed to parse config with overrides: %v", err)
+	}
+	if c.KeyName != "0123" {
+		t.Errorf("expected a string override to be taken as is, got keyName %q", c.KeyName)
+	}
+
+	c, err = ClusterFromBytesWithOverrides([]byte(singleAzConfigYaml), map[string]string{"hostedZone": "staging.core-os.net"})
+	if err != nil {
+		t.Fatalf("failed to parse config with overrides: %v", err)
+	}
+	if c.HostedZone != "staging.core-os.net." {
+		t.Errorf("expected overrides to be normalized like the file, got hostedZone %q", c.HostedZone)
+	}
+}
+
+func TestInvalidOverrides(t *testing.T) {
+	for _, overrides := range []map[string]string{
+		{"keyname": "wrong-case"},
+		{"noSuchKey": "value"},
+		{"workerCount": "many"},
+		{"workerCount": "-1"}, // validated like the file
+		{"region": ""},
+	} {
+		if _, err := ClusterFromBytesWithOverrides([]byte(singleAzConfigYaml), overrides); err == nil {
+			t.Errorf("expected error parsing config with overrides %v", overrides)
+		}
+	}
+
+	_, err := OverridesFromEnv([]string{"KUBE_AWS_NO_SUCH_KEY=value"})
+	if err == nil {
+		t.Fatalf("expected error for an environment variable overriding no key")
+	}
+	if !strings.Contains(err.Error(), "KUBE_AWS_NO_SUCH_KEY") {
+		t.Errorf("expected error to name the environment variable, got: %v", err)
+	}
+}
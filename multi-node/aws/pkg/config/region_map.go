@@ -2,23 +2,39 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/coreosutil"
 )
 
+// regions are the AWS regions a cluster can be created in, across the aws,
+// aws-us-gov and aws-cn partitions
 var regions = []string{
 	"ap-northeast-1",
+	"ap-northeast-2",
+	"ap-south-1",
 	"ap-southeast-1",
 	"ap-southeast-2",
+	"cn-north-1",
 	"eu-central-1",
 	"eu-west-1",
 	"sa-east-1",
 	"us-east-1",
+	"us-east-2",
 	"us-gov-west-1",
 	"us-west-1",
 	"us-west-2",
 }
 
+func validateRegion(region string) error {
+	for _, r := range regions {
+		if r == region {
+			return nil
+		}
+	}
+	return fmt.Errorf("region %q is not a known AWS region. valid regions are: %s", region, strings.Join(regions, ", "))
+}
+
 var supportedChannels = []string{
 	"alpha",
 	"beta",
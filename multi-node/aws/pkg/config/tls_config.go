@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/kms"
@@ -39,46 +43,162 @@ type CompactTLSAssets struct {
 	AdminKey      string
 }
 
+// tlsCertDuration returns the validity period requested by tlsCertDurationDays,
+// or zero to keep the tlsutil defaults.
+func (c *Cluster) tlsCertDuration() time.Duration {
+	return time.Duration(c.TLSCertDurationDays) * 24 * time.Hour
+}
+
+// NewTLSAssets generates a CA and the certificates signed by it, or only the
+// certificates when caCertFile and caKeyFile give an existing CA
 func (c *Cluster) NewTLSAssets() (*RawTLSAssets, error) {
-	// Generate keys for the various components.
-	keys := make([]*rsa.PrivateKey, 4)
-	var err error
-	for i := range keys {
-		if keys[i], err = tlsutil.NewPrivateKey(); err != nil {
+	return c.newTLSAssets(c.kmsService())
+}
+
+func (c *Cluster) newTLSAssets(kmsSvc decryptService) (*RawTLSAssets, error) {
+	duration := c.tlsCertDuration()
+
+	if c.CACertFile != "" {
+		caKey, caCert, err := c.existingCA(kmsSvc)
+		if err != nil {
 			return nil, err
 		}
+		assets, err := c.newTLSAssetsSignedBy(caKey, caCert, duration)
+		if err != nil {
+			return nil, err
+		}
+		//The key of an existing CA stays encrypted in caKeyFile
+		assets.CAKey = nil
+		return assets, nil
 	}
-	caKey, apiServerKey, workerKey, adminKey := keys[0], keys[1], keys[2], keys[3]
 
+	caKey, err := tlsutil.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
 	caConfig := tlsutil.CACertConfig{
 		CommonName:   "kube-ca",
 		Organization: "kube-aws",
+		Duration:     duration,
 	}
 	caCert, err := tlsutil.NewSelfSignedCACertificate(caConfig, caKey)
 	if err != nil {
 		return nil, err
 	}
 
-	//Compute kubernetesServiceIP from serviceCIDR
-	_, serviceNet, err := net.ParseCIDR(c.ServiceCIDR)
+	return c.newTLSAssetsSignedBy(caKey, caCert, duration)
+}
+
+// RenewTLSAssets regenerates the keys and certificates in current. The
+// existing CA is kept and used to sign the new certificates, so clients
+// trusting it keep working, unless rotateCA is set.
+func (c *Cluster) RenewTLSAssets(current *RawTLSAssets, rotateCA bool) (*RawTLSAssets, error) {
+	if c.CACertFile != "" {
+		if rotateCA {
+			return nil, fmt.Errorf("the CA is the existing one in caCertFile %s, which kube-aws can't rotate. point caCertFile and caKeyFile at the new CA instead", c.CACertFile)
+		}
+		return c.NewTLSAssets()
+	}
+	if rotateCA {
+		return c.NewTLSAssets()
+	}
+
+	caKey, err := tlsutil.DecodePrivateKeyPEM(current.CAKey)
 	if err != nil {
-		return nil, fmt.Errorf("invalid serviceCIDR: %v", err)
+		return nil, fmt.Errorf("failed to read CA key: %v", err)
+	}
+	caCert, err := tlsutil.DecodeCertificatePEM(current.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+	if time.Now().After(caCert.NotAfter) {
+		return nil, fmt.Errorf(
+			"CA certificate expired on %s, the CA must be rotated too",
+			caCert.NotAfter.Format("2006-01-02"),
+		)
+	}
+
+	assets, err := c.newTLSAssetsSignedBy(caKey, caCert, c.tlsCertDuration())
+	if err != nil {
+		return nil, err
+	}
+
+	//Only the CA and its key are carried over verbatim
+	assets.CACert = current.CACert
+	assets.CAKey = current.CAKey
+
+	return assets, nil
+}
+
+// existingCA reads the CA certificate of caCertFile and decrypts its key from
+// caKeyFile with KMS, checking that they belong together and that the CA can
+// sign the certificates
+func (c *Cluster) existingCA(kmsSvc decryptService) (*rsa.PrivateKey, *x509.Certificate, error) {
+	certData, err := ioutil.ReadFile(c.CACertFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read caCertFile: %v", err)
+	}
+	caCert, err := tlsutil.DecodeCertificatePEM(certData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("caCertFile %s is not a PEM encoded certificate: %v", c.CACertFile, err)
+	}
+
+	now := time.Now()
+	if now.Before(caCert.NotBefore) {
+		return nil, nil, fmt.Errorf("CA certificate %s is not valid until %s", c.CACertFile, caCert.NotBefore.Format("2006-01-02"))
+	}
+	if now.After(caCert.NotAfter) {
+		return nil, nil, fmt.Errorf("CA certificate %s expired on %s", c.CACertFile, caCert.NotAfter.Format("2006-01-02"))
+	}
+	if !caCert.BasicConstraintsValid || !caCert.IsCA {
+		return nil, nil, fmt.Errorf("certificate %s can't sign certificates: its basic constraints don't mark it as a CA", c.CACertFile)
+	}
+	if caCert.KeyUsage != 0 && caCert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, nil, fmt.Errorf("CA certificate %s can't sign certificates: its key usage doesn't include certificate signing", c.CACertFile)
+	}
+
+	ciphertext, err := ioutil.ReadFile(c.CAKeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read caKeyFile: %v", err)
+	}
+	decryptOutput, err := kmsSvc.Decrypt(&kms.DecryptInput{CiphertextBlob: ciphertext})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt caKeyFile %s with KMS: %v", c.CAKeyFile, err)
+	}
+	caKey, err := tlsutil.DecodePrivateKeyPEM(decryptOutput.Plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("caKeyFile %s is not a PEM encoded RSA private key: %v", c.CAKeyFile, err)
+	}
+
+	caPublicKey, ok := caCert.PublicKey.(*rsa.PublicKey)
+	if !ok || caPublicKey.N.Cmp(caKey.N) != 0 || caPublicKey.E != caKey.E {
+		return nil, nil, fmt.Errorf("caKeyFile %s is not the key of CA certificate %s", c.CAKeyFile, c.CACertFile)
+	}
+
+	return caKey, caCert, nil
+}
+
+func (c *Cluster) newTLSAssetsSignedBy(caKey *rsa.PrivateKey, caCert *x509.Certificate, duration time.Duration) (*RawTLSAssets, error) {
+	// Generate keys for the various components.
+	keys := make([]*rsa.PrivateKey, 3)
+	var err error
+	for i := range keys {
+		if keys[i], err = tlsutil.NewPrivateKey(); err != nil {
+			return nil, err
+		}
+	}
+	apiServerKey, workerKey, adminKey := keys[0], keys[1], keys[2]
+
+	dnsNames, ipAddresses, err := c.apiServerSANs()
+	if err != nil {
+		return nil, err
 	}
-	kubernetesServiceIPAddr := incrementIP(serviceNet.IP)
 
 	apiServerConfig := tlsutil.ServerCertConfig{
-		CommonName: "kube-apiserver",
-		DNSNames: []string{
-			"kubernetes",
-			"kubernetes.default",
-			"kubernetes.default.svc",
-			"kubernetes.default.svc.cluster.local",
-			c.ExternalDNSName,
-		},
-		IPAddresses: []string{
-			c.ControllerIP,
-			kubernetesServiceIPAddr.String(),
-		},
+		CommonName:  "kube-apiserver",
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+		Duration:    duration,
 	}
 	apiServerCert, err := tlsutil.NewSignedServerCertificate(apiServerConfig, apiServerKey, caCert, caKey)
 	if err != nil {
@@ -91,6 +211,7 @@ func (c *Cluster) NewTLSAssets() (*RawTLSAssets, error) {
 			"*.*.compute.internal",
 			"*.ec2.internal",
 		},
+		Duration: duration,
 	}
 	workerCert, err := tlsutil.NewSignedClientCertificate(workerConfig, workerKey, caCert, caKey)
 	if err != nil {
@@ -99,6 +220,7 @@ func (c *Cluster) NewTLSAssets() (*RawTLSAssets, error) {
 
 	adminConfig := tlsutil.ClientCertConfig{
 		CommonName: "kube-admin",
+		Duration:   duration,
 	}
 	adminCert, err := tlsutil.NewSignedClientCertificate(adminConfig, adminKey, caCert, caKey)
 	if err != nil {
@@ -117,16 +239,151 @@ func (c *Cluster) NewTLSAssets() (*RawTLSAssets, error) {
 	}, nil
 }
 
+// apiServerSANs returns the DNS names and IPs the API server certificate must
+// be valid for: the names of the kubernetes service, externalDNSName, the
+// controller IPs, the kubernetes service IP and apiServerAdditionalSANs
+func (c *Cluster) apiServerSANs() (dnsNames, ipAddresses []string, err error) {
+	//Compute kubernetesServiceIP from serviceCIDR
+	_, serviceNet, err := net.ParseCIDR(c.ServiceCIDR)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid serviceCIDR: %v", err)
+	}
+	kubernetesServiceIPAddr := incrementIP(serviceNet.IP)
+
+	dnsNames = []string{
+		"kubernetes",
+		"kubernetes.default",
+		"kubernetes.default.svc",
+		"kubernetes.default.svc." + c.DNSDomain,
+		c.ExternalDNSName,
+	}
+	ipAddresses = append(
+		c.ControllerIPs(),
+		kubernetesServiceIPAddr.String(),
+	)
+	for _, san := range c.APIServerAdditionalSANs {
+		if net.ParseIP(san) != nil {
+			ipAddresses = append(ipAddresses, san)
+		} else {
+			dnsNames = append(dnsNames, san)
+		}
+	}
+	return dnsNames, ipAddresses, nil
+}
+
+// missingAPIServerSANs returns the hosts the API server certificate of r is
+// not valid for
+func (r *RawTLSAssets) missingAPIServerSANs(hosts []string) ([]string, error) {
+	cert, err := tlsutil.DecodeCertificatePEM(r.APIServerCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apiserver certificate: %v", err)
+	}
+	missing := []string{}
+	for _, host := range hosts {
+		if err := cert.VerifyHostname(host); err != nil {
+			missing = append(missing, host)
+		}
+	}
+	return missing, nil
+}
+
+// TLSAssetsWarnings returns the problems with the TLS assets r, which may
+// predate changes to the cluster config: names and IPs clients reach the API by
+// that the apiserver certificate is not valid for, and a CA expiring before
+// the certificates it signed
+func (c *Cluster) TLSAssetsWarnings(r *RawTLSAssets) ([]string, error) {
+	var warnings []string
+
+	dnsNames, ipAddresses, err := c.apiServerSANs()
+	if err != nil {
+		return nil, err
+	}
+	missing, err := r.missingAPIServerSANs(append(dnsNames, ipAddresses...))
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"the apiserver certificate is not valid for %s. Clients connecting by those will fail TLS verification. Regenerate the certificates with \"kube-aws rotate-certs\".",
+			strings.Join(missing, ", "),
+		))
+	}
+
+	caCert, err := tlsutil.DecodeCertificatePEM(r.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+	apiServerCert, err := tlsutil.DecodeCertificatePEM(r.APIServerCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apiserver certificate: %v", err)
+	}
+	// A new CA is generated a moment before the certificates it signs for the
+	// same duration, so only warn about certificates outliving it by a day
+	if apiServerCert.NotAfter.Sub(caCert.NotAfter) > 24*time.Hour {
+		rotate := "Rotate the CA too with \"kube-aws rotate-certs --rotate-ca\""
+		if c.CACertFile != "" {
+			rotate = "Point caCertFile and caKeyFile at a CA valid for longer"
+		}
+		warnings = append(warnings, fmt.Sprintf(
+			"the CA certificate expires on %s, before the certificates it signed, which stop verifying then. %s.",
+			caCert.NotAfter.Format("2006-01-02"),
+			rotate,
+		))
+	}
+
+	return warnings, nil
+}
+
+// TLSFingerprint is the SHA-256 fingerprint of one of the certificates of a cluster
+type TLSFingerprint struct {
+	Name   string
+	SHA256 string
+}
+
+func (f TLSFingerprint) String() string {
+	return fmt.Sprintf("%s.pem %s", f.Name, f.SHA256)
+}
+
+// TLSFingerprintsFile records the fingerprints of the certificates in an
+// asset directory, which tell which certificates a cluster was deployed with
+const TLSFingerprintsFile = "fingerprints.txt"
+
+// Fingerprints returns the SHA-256 fingerprints of the CA certificate and of
+// each certificate signed by it
+func (r *RawTLSAssets) Fingerprints() ([]TLSFingerprint, error) {
+	certs := []struct {
+		name string
+		cert []byte
+	}{
+		{"ca", r.CACert},
+		{"apiserver", r.APIServerCert},
+		{"worker", r.WorkerCert},
+		{"admin", r.AdminCert},
+	}
+	fingerprints := make([]TLSFingerprint, len(certs))
+	for i, cert := range certs {
+		parsed, err := tlsutil.DecodeCertificatePEM(cert.cert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s certificate: %v", cert.name, err)
+		}
+		fingerprints[i] = TLSFingerprint{cert.name, tlsutil.Fingerprint(parsed)}
+	}
+	return fingerprints, nil
+}
+
 func ReadTLSAssets(dirname string) (*RawTLSAssets, error) {
 	r := new(RawTLSAssets)
 	files := []struct {
 		name      string
 		cert, key *[]byte
+		// keyOptional is set for the CA, whose key isn't stored with the
+		// assets when it is an existing CA
+		keyOptional bool
 	}{
-		{"ca", &r.CACert, &r.CAKey},
-		{"apiserver", &r.APIServerCert, &r.APIServerKey},
-		{"worker", &r.WorkerCert, &r.WorkerKey},
-		{"admin", &r.AdminCert, &r.AdminKey},
+		{"ca", &r.CACert, &r.CAKey, true},
+		{"apiserver", &r.APIServerCert, &r.APIServerKey, false},
+		{"worker", &r.WorkerCert, &r.WorkerKey, false},
+		{"admin", &r.AdminCert, &r.AdminKey, false},
 	}
 	for _, file := range files {
 		certPath := filepath.Join(dirname, file.name+".pem")
@@ -138,6 +395,9 @@ func ReadTLSAssets(dirname string) (*RawTLSAssets, error) {
 		}
 		*file.cert = certData
 		keyData, err := ioutil.ReadFile(keyPath)
+		if os.IsNotExist(err) && file.keyOptional {
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -162,11 +422,28 @@ func (r *RawTLSAssets) WriteToDir(dirname string) error {
 		if err := ioutil.WriteFile(certPath, asset.cert, 0600); err != nil {
 			return err
 		}
+		if asset.key == nil {
+			continue
+		}
 		if err := ioutil.WriteFile(keyPath, asset.key, 0600); err != nil {
 			return err
 		}
 	}
-	return nil
+	return r.WriteFingerprints(dirname)
+}
+
+// WriteFingerprints records the fingerprints of the certificates in
+// TLSFingerprintsFile of dirname
+func (r *RawTLSAssets) WriteFingerprints(dirname string) error {
+	fingerprints, err := r.Fingerprints()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, fingerprint := range fingerprints {
+		fmt.Fprintln(&buf, fingerprint)
+	}
+	return ioutil.WriteFile(filepath.Join(dirname, TLSFingerprintsFile), buf.Bytes(), 0644)
 }
 
 func compressData(d []byte) (string, error) {
@@ -185,39 +462,48 @@ type encryptService interface {
 	Encrypt(*kms.EncryptInput) (*kms.EncryptOutput, error)
 }
 
-func (r *RawTLSAssets) compact(cfg *Config, kmsSvc encryptService) (*CompactTLSAssets, error) {
-	var err error
-	compact := func(data []byte) string {
-		if err != nil {
-			return ""
-		}
+type decryptService interface {
+	Decrypt(*kms.DecryptInput) (*kms.DecryptOutput, error)
+}
 
-		encryptInput := kms.EncryptInput{
-			KeyId:     aws.String(cfg.KMSKeyARN),
-			Plaintext: data,
-		}
+// compactSecret encrypts data with the cluster's KMS key, then gzips and
+// base64 encodes the ciphertext for embedding in userdata.
+func compactSecret(cfg *Config, kmsSvc encryptService, name string, data []byte) (string, error) {
+	encryptInput := kms.EncryptInput{
+		KeyId:     aws.String(cfg.KMSKeyARN),
+		Plaintext: data,
+	}
 
-		var encryptOutput *kms.EncryptOutput
-		if encryptOutput, err = kmsSvc.Encrypt(&encryptInput); err != nil {
+	encryptOutput, err := kmsSvc.Encrypt(&encryptInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt %s with KMS key %s: %v", name, cfg.KMSKeyARN, err)
+	}
+	return compressData(encryptOutput.CiphertextBlob)
+}
+
+// compact encrypts each TLS asset with the cluster's KMS key, then gzips and
+// base64 encodes the ciphertext for embedding in userdata. Nodes decrypt the
+// assets with KMS at boot, so they are never stored in plaintext in the stack.
+func (r *RawTLSAssets) compact(cfg *Config, kmsSvc encryptService) (*CompactTLSAssets, error) {
+	var err error
+	compact := func(name string, data []byte) string {
+		if err != nil || data == nil {
 			return ""
 		}
-		data = encryptOutput.CiphertextBlob
 
 		var out string
-		if out, err = compressData(data); err != nil {
-			return ""
-		}
+		out, err = compactSecret(cfg, kmsSvc, name, data)
 		return out
 	}
 	compactAssets := CompactTLSAssets{
-		CACert:        compact(r.CACert),
-		CAKey:         compact(r.CAKey),
-		APIServerCert: compact(r.APIServerCert),
-		APIServerKey:  compact(r.APIServerKey),
-		WorkerCert:    compact(r.WorkerCert),
-		WorkerKey:     compact(r.WorkerKey),
-		AdminCert:     compact(r.AdminCert),
-		AdminKey:      compact(r.AdminKey),
+		CACert:        compact("ca.pem", r.CACert),
+		CAKey:         compact("ca-key.pem", r.CAKey),
+		APIServerCert: compact("apiserver.pem", r.APIServerCert),
+		APIServerKey:  compact("apiserver-key.pem", r.APIServerKey),
+		WorkerCert:    compact("worker.pem", r.WorkerCert),
+		WorkerKey:     compact("worker-key.pem", r.WorkerKey),
+		AdminCert:     compact("admin.pem", r.AdminCert),
+		AdminKey:      compact("admin-key.pem", r.AdminKey),
 	}
 	if err != nil {
 		return nil, err
@@ -3,9 +3,20 @@ package config
 import (
 	"testing"
 
+	"bytes"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/coreos/coreos-kubernetes/multi-node/aws/pkg/tlsutil"
 )
 
 func genTLSAssets(t *testing.T) *RawTLSAssets {
@@ -93,3 +104,397 @@ func TestTLSGeneration(t *testing.T) {
 		}
 	}
 }
+
+func TestTLSCertDuration(t *testing.T) {
+	day := 24 * time.Hour
+	for _, tc := range []struct {
+		confBody                 string
+		caDuration, certDuration time.Duration
+	}{
+		{"", tlsutil.Duration365d, tlsutil.Duration90d},
+		{"tlsCertDurationDays: 730", 730 * day, 730 * day},
+	} {
+		cluster, err := ClusterFromBytes([]byte(singleAzConfigYaml + tc.confBody))
+		if err != nil {
+			t.Fatalf("failed generating config: %v", err)
+		}
+		assets, err := cluster.NewTLSAssets()
+		if err != nil {
+			t.Fatalf("failed generating tls: %v", err)
+		}
+
+		certs := map[string][]byte{
+			"ca":        assets.CACert,
+			"apiserver": assets.APIServerCert,
+			"worker":    assets.WorkerCert,
+			"admin":     assets.AdminCert,
+		}
+		for name, certBytes := range certs {
+			block, _ := pem.Decode(certBytes)
+			if block == nil {
+				t.Fatalf("failed decoding pem block from %s", name)
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("failed to parse cert %s: %v", name, err)
+			}
+
+			expected := tc.certDuration
+			if name == "ca" {
+				expected = tc.caDuration
+			}
+			//Allow some slack for the time spent generating keys
+			if actual := cert.NotAfter.Sub(cert.NotBefore); actual < expected || actual > expected+time.Minute {
+				t.Errorf("%q: expected %s to be valid for %s, got %s", tc.confBody, name, expected, actual)
+			}
+		}
+	}
+}
+
+func TestRenewTLSAssets(t *testing.T) {
+	cluster, err := ClusterFromBytes([]byte(singleAzConfigYaml))
+	if err != nil {
+		t.Fatalf("failed generating config: %v", err)
+	}
+	current := genTLSAssets(t)
+
+	renewed, err := cluster.RenewTLSAssets(current, false)
+	if err != nil {
+		t.Fatalf("failed renewing tls: %v", err)
+	}
+	if !bytes.Equal(renewed.CACert, current.CACert) || !bytes.Equal(renewed.CAKey, current.CAKey) {
+		t.Errorf("expected CA to be preserved")
+	}
+	if bytes.Equal(renewed.APIServerKey, current.APIServerKey) {
+		t.Errorf("expected apiserver key to be regenerated")
+	}
+
+	caCert, err := tlsutil.DecodeCertificatePEM(current.CACert)
+	if err != nil {
+		t.Fatalf("failed to parse ca cert: %v", err)
+	}
+	for name, certBytes := range map[string][]byte{
+		"apiserver": renewed.APIServerCert,
+		"worker":    renewed.WorkerCert,
+		"admin":     renewed.AdminCert,
+	} {
+		cert, err := tlsutil.DecodeCertificatePEM(certBytes)
+		if err != nil {
+			t.Fatalf("failed to parse cert %s: %v", name, err)
+		}
+		if err := cert.CheckSignatureFrom(caCert); err != nil {
+			t.Errorf("expected renewed %s cert to be signed by the existing CA: %v", name, err)
+		}
+	}
+
+	rotated, err := cluster.RenewTLSAssets(current, true)
+	if err != nil {
+		t.Fatalf("failed renewing tls: %v", err)
+	}
+	if bytes.Equal(rotated.CAKey, current.CAKey) {
+		t.Errorf("expected CA to be rotated")
+	}
+}
+
+type dummyDecryptService struct{}
+
+func (d *dummyDecryptService) Decrypt(input *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	output := kms.DecryptOutput{
+		Plaintext: input.CiphertextBlob,
+	}
+	return &output, nil
+}
+
+func TestExistingCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kube-aws-ca")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	newKey := func() *rsa.PrivateKey {
+		key, err := tlsutil.NewPrivateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		return key
+	}
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	newCA := func(key *rsa.PrivateKey, duration time.Duration) *x509.Certificate {
+		cert, err := tlsutil.NewSelfSignedCACertificate(tlsutil.CACertConfig{
+			CommonName:   "existing-ca",
+			Organization: "kube-aws",
+			Duration:     duration,
+		}, key)
+		if err != nil {
+			t.Fatalf("failed to generate ca: %v", err)
+		}
+		return cert
+	}
+
+	caKey := newKey()
+	caCert := newCA(caKey, tlsutil.Duration365d)
+	otherKey := newKey()
+	expiredCert := newCA(caKey, -time.Hour)
+	leafCert, err := tlsutil.NewSignedClientCertificate(tlsutil.ClientCertConfig{CommonName: "leaf"}, otherKey, caCert, caKey)
+	if err != nil {
+		t.Fatalf("failed to generate leaf cert: %v", err)
+	}
+
+	// The dummy decrypt service returns its input, so the "encrypted" key is plain PEM
+	caKeyFile := writeFile("ca-key.pem.enc", tlsutil.EncodePrivateKeyPEM(caKey))
+	caCertFile := writeFile("ca.pem", tlsutil.EncodeCertificatePEM(caCert))
+
+	invalidConfigs := []struct {
+		name, certFile, keyFile, errContains string
+	}{
+		{
+			name:        "mismatched key",
+			certFile:    caCertFile,
+			keyFile:     writeFile("other-key.pem.enc", tlsutil.EncodePrivateKeyPEM(otherKey)),
+			errContains: "is not the key of CA certificate",
+		},
+		{
+			name:        "expired CA",
+			certFile:    writeFile("expired.pem", tlsutil.EncodeCertificatePEM(expiredCert)),
+			keyFile:     caKeyFile,
+			errContains: "expired",
+		},
+		{
+			name:        "not a CA",
+			certFile:    writeFile("leaf.pem", tlsutil.EncodeCertificatePEM(leafCert)),
+			keyFile:     writeFile("leaf-key.pem.enc", tlsutil.EncodePrivateKeyPEM(otherKey)),
+			errContains: "don't mark it as a CA",
+		},
+	}
+
+	for _, invalid := range invalidConfigs {
+		cluster, err := ClusterFromBytes([]byte(singleAzConfigYaml + "caCertFile: " + invalid.certFile + "\ncaKeyFile: " + invalid.keyFile + "\n"))
+		if err != nil {
+			t.Fatalf("failed generating config: %v", err)
+		}
+		_, err = cluster.newTLSAssets(&dummyDecryptService{})
+		if err == nil {
+			t.Errorf("%s: expected an error", invalid.name)
+		} else if !strings.Contains(err.Error(), invalid.errContains) {
+			t.Errorf("%s: expected error containing %q, got: %v", invalid.name, invalid.errContains, err)
+		}
+	}
+
+	cluster, err := ClusterFromBytes([]byte(singleAzConfigYaml + "caCertFile: " + caCertFile + "\ncaKeyFile: " + caKeyFile + "\n"))
+	if err != nil {
+		t.Fatalf("failed generating config: %v", err)
+	}
+	assets, err := cluster.newTLSAssets(&dummyDecryptService{})
+	if err != nil {
+		t.Fatalf("failed generating tls with existing CA: %v", err)
+	}
+	if !bytes.Equal(assets.CACert, tlsutil.EncodeCertificatePEM(caCert)) {
+		t.Errorf("expected the existing CA certificate to be used")
+	}
+	if assets.CAKey != nil {
+		t.Errorf("expected the existing CA key not to be part of the assets")
+	}
+	cert, err := tlsutil.DecodeCertificatePEM(assets.APIServerCert)
+	if err != nil {
+		t.Fatalf("failed to parse apiserver cert: %v", err)
+	}
+	if err := cert.CheckSignatureFrom(caCert); err != nil {
+		t.Errorf("expected apiserver cert to be signed by the existing CA: %v", err)
+	}
+
+	assetsDir := filepath.Join(dir, "credentials")
+	if err := os.Mkdir(assetsDir, 0700); err != nil {
+		t.Fatalf("failed to create assets dir: %v", err)
+	}
+	if err := assets.WriteToDir(assetsDir); err != nil {
+		t.Fatalf("failed to write assets: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(assetsDir, "ca-key.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected ca-key.pem not to be written, got: %v", err)
+	}
+	if _, err := ReadTLSAssets(assetsDir); err != nil {
+		t.Errorf("failed to read assets without ca-key.pem: %v", err)
+	}
+
+	if _, err := cluster.RenewTLSAssets(assets, true); err == nil {
+		t.Errorf("expected rotating an existing CA to fail")
+	}
+
+	if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "caCertFile: " + caCertFile + "\n")); err == nil {
+		t.Errorf("expected caCertFile without caKeyFile to be rejected")
+	}
+}
+
+func TestTLSFingerprints(t *testing.T) {
+	cluster, err := ClusterFromBytes([]byte(singleAzConfigYaml))
+	if err != nil {
+		t.Fatalf("failed generating config: %v", err)
+	}
+	current := genTLSAssets(t)
+
+	fingerprints, err := current.Fingerprints()
+	if err != nil {
+		t.Fatalf("failed fingerprinting tls: %v", err)
+	}
+	for i, cert := range [][]byte{current.CACert, current.APIServerCert, current.WorkerCert, current.AdminCert} {
+		block, _ := pem.Decode(cert)
+		sum := sha256.Sum256(block.Bytes)
+		expected := strings.ToUpper(hex.EncodeToString(sum[:]))
+		if got := strings.Replace(fingerprints[i].SHA256, ":", "", -1); got != expected {
+			t.Errorf("expected %s fingerprint to be the SHA-256 of its DER encoding %s, got %s", fingerprints[i].Name, expected, got)
+		}
+	}
+
+	// Renewing keeps the CA, so only its fingerprint stays the same
+	renewed, err := cluster.RenewTLSAssets(current, false)
+	if err != nil {
+		t.Fatalf("failed renewing tls: %v", err)
+	}
+	renewedFingerprints, err := renewed.Fingerprints()
+	if err != nil {
+		t.Fatalf("failed fingerprinting tls: %v", err)
+	}
+	for i := range fingerprints {
+		if unchanged := fingerprints[i] == renewedFingerprints[i]; unchanged != (fingerprints[i].Name == "ca") {
+			t.Errorf("expected only the ca fingerprint to survive renewal, %s changed from %s to %s", fingerprints[i].Name, fingerprints[i].SHA256, renewedFingerprints[i].SHA256)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "kube-aws-tls")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := current.WriteToDir(dir); err != nil {
+		t.Fatalf("failed to write tls assets: %v", err)
+	}
+	recorded, err := ioutil.ReadFile(filepath.Join(dir, TLSFingerprintsFile))
+	if err != nil {
+		t.Fatalf("failed to read recorded fingerprints: %v", err)
+	}
+	for _, fingerprint := range fingerprints {
+		if !bytes.Contains(recorded, []byte(fingerprint.String()+"\n")) {
+			t.Errorf("expected %s to record %s", TLSFingerprintsFile, fingerprint)
+		}
+	}
+}
+
+func TestAPIServerCertControllerIPs(t *testing.T) {
+	cluster, err := ClusterFromBytes([]byte(singleAzConfigYaml + "controllerCount: 3\n"))
+	if err != nil {
+		t.Fatalf("failed generating config: %v", err)
+	}
+	assets, err := cluster.NewTLSAssets()
+	if err != nil {
+		t.Fatalf("failed generating tls: %v", err)
+	}
+	cert, err := tlsutil.DecodeCertificatePEM(assets.APIServerCert)
+	if err != nil {
+		t.Fatalf("failed to parse apiserver cert: %v", err)
+	}
+
+	for _, controllerIP := range cluster.ControllerIPs() {
+		found := false
+		for _, ip := range cert.IPAddresses {
+			if ip.String() == controllerIP {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected apiserver cert to be valid for controller IP %s, got %v", controllerIP, cert.IPAddresses)
+		}
+	}
+}
+
+func TestAPIServerAdditionalSANs(t *testing.T) {
+	cluster, err := ClusterFromBytes([]byte(singleAzConfigYaml + `
+apiServerAdditionalSANs:
+  - internal-kube-api.example.com
+  - 10.0.0.100
+`))
+	if err != nil {
+		t.Fatalf("failed generating config: %v", err)
+	}
+	assets, err := cluster.NewTLSAssets()
+	if err != nil {
+		t.Fatalf("failed generating tls: %v", err)
+	}
+
+	missing, err := assets.missingAPIServerSANs([]string{
+		cluster.ExternalDNSName,
+		cluster.ControllerIP,
+		"10.3.0.1", // kubernetes service IP
+		"internal-kube-api.example.com",
+		"10.0.0.100",
+	})
+	if err != nil {
+		t.Fatalf("failed to check apiserver cert: %v", err)
+	}
+	if len(missing) > 0 {
+		t.Errorf("expected apiserver cert to be valid for %v", missing)
+	}
+
+	missing, err = assets.missingAPIServerSANs([]string{"test-elb.us-west-1.elb.amazonaws.com", "10.0.0.101"})
+	if err != nil {
+		t.Fatalf("failed to check apiserver cert: %v", err)
+	}
+	if len(missing) != 2 {
+		t.Errorf("expected apiserver cert not to be valid for names outside its SANs, missing %v", missing)
+	}
+
+	for _, san := range []string{"https://kube.example.com", "kube.example.com:443", "-kube.example.com"} {
+		if _, err := ClusterFromBytes([]byte(singleAzConfigYaml + "apiServerAdditionalSANs: [\"" + san + "\"]\n")); err == nil {
+			t.Errorf("expected error for invalid apiServerAdditionalSANs entry %q", san)
+		}
+	}
+}
+
+func TestTLSAssetsWarnings(t *testing.T) {
+	cluster, err := ClusterFromBytes([]byte(singleAzConfigYaml + "tlsCertDurationDays: 730\n"))
+	if err != nil {
+		t.Fatalf("failed generating config: %v", err)
+	}
+	current, err := cluster.NewTLSAssets()
+	if err != nil {
+		t.Fatalf("failed generating tls: %v", err)
+	}
+	warnings, err := cluster.TLSAssetsWarnings(current)
+	if err != nil {
+		t.Fatalf("failed to check tls: %v", err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("expected no warnings for freshly generated assets, got %v", warnings)
+	}
+
+	// The SANs of the certificate predate apiServerAdditionalSANs
+	changed, err := ClusterFromBytes([]byte(singleAzConfigYaml + "tlsCertDurationDays: 730\napiServerAdditionalSANs: [internal-kube-api.example.com]\n"))
+	if err != nil {
+		t.Fatalf("failed generating config: %v", err)
+	}
+	if warnings, err = changed.TLSAssetsWarnings(current); err != nil {
+		t.Fatalf("failed to check tls: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "not valid for internal-kube-api.example.com") {
+		t.Errorf("expected a warning about the missing SAN, got %v", warnings)
+	}
+
+	// Renewing for longer than the default year of the CA outlives it
+	current = genTLSAssets(t)
+	renewed, err := cluster.RenewTLSAssets(current, false)
+	if err != nil {
+		t.Fatalf("failed renewing tls: %v", err)
+	}
+	if warnings, err = cluster.TLSAssetsWarnings(renewed); err != nil {
+		t.Fatalf("failed to check tls: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "before the certificates it signed") {
+		t.Errorf("expected a warning about the CA expiring first, got %v", warnings)
+	}
+}
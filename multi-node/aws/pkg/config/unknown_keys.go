@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// unknownKeys returns the keys of data, a cluster.yaml, that match no
+// setting and so would be silently ignored, e.g. controllerIp for
+// controllerIP. Each is given by its path, with its line where found.
+func unknownKeys(data []byte) ([]string, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	w := keyWalker{lines: strings.Split(string(data), "\n")}
+	w.walk("", doc, reflect.TypeOf(Cluster{}))
+	return w.unknown, nil
+}
+
+// keyWalker walks a YAML document alongside the type it is decoded into
+type keyWalker struct {
+	lines []string
+	// next is the line to look for the next key from, as keys are walked in
+	// the order of the document
+	next    int
+	unknown []string
+}
+
+// line is the number of the line the next key, key, is on, or 0 if it can't
+// be found, e.g. because it is in a flow mapping
+func (w *keyWalker) line(key string) int {
+	for i := w.next; i < len(w.lines); i++ {
+		l := strings.TrimLeft(w.lines[i], " ")
+		for strings.HasPrefix(l, "- ") {
+			l = strings.TrimLeft(l[2:], " ")
+		}
+		for _, quote := range []string{"", `"`, "'"} {
+			quoted := quote + key + quote
+			if strings.HasPrefix(l, quoted) && strings.HasPrefix(strings.TrimLeft(l[len(quoted):], " "), ":") {
+				w.next = i + 1
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+func (w *keyWalker) walk(path string, value interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		var fields map[string]reflect.Type
+		if t.Kind() == reflect.Struct {
+			fields = structFieldKeys(t)
+		}
+		for _, item := range value {
+			key := fmt.Sprint(item.Key)
+			line := w.line(key)
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+
+			valueType := anyType
+			switch t.Kind() {
+			case reflect.Struct:
+				fieldType, ok := fields[key]
+				if !ok {
+					if line > 0 {
+						w.unknown = append(w.unknown, fmt.Sprintf("%s (line %d)", keyPath, line))
+					} else {
+						w.unknown = append(w.unknown, keyPath)
+					}
+				} else {
+					valueType = fieldType
+				}
+			case reflect.Map:
+				valueType = t.Elem()
+			}
+			// The keys below an unknown key are walked too, so that the
+			// lines of the keys after it are found
+			w.walk(keyPath, item.Value, valueType)
+		}
+	case []interface{}:
+		elemType := anyType
+		if t.Kind() == reflect.Slice {
+			elemType = t.Elem()
+		}
+		for i, elem := range value {
+			w.walk(fmt.Sprintf("%s[%d]", path, i), elem, elemType)
+		}
+	}
+}
+
+// structFieldKeys maps the keys yaml decodes into the fields of struct type
+// t to the types of the fields
+func structFieldKeys(t reflect.Type) map[string]reflect.Type {
+	keys := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if key != "-" {
+			keys[key] = field.Type
+		}
+	}
+	return keys
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 )
 
 func EncodePrivateKeyPEM(key *rsa.PrivateKey) []byte {
@@ -21,3 +22,19 @@ func EncodeCertificatePEM(cert *x509.Certificate) []byte {
 	}
 	return pem.EncodeToMemory(&block)
 }
+
+func DecodePrivateKeyPEM(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "RSA PRIVATE KEY" {
+		return nil, errors.New("failed to decode RSA PRIVATE KEY pem block")
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func DecodeCertificatePEM(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("failed to decode CERTIFICATE pem block")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -3,11 +3,14 @@ package tlsutil
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"fmt"
 	"math"
 	"math/big"
 	"net"
+	"strings"
 	"time"
 )
 
@@ -19,21 +22,32 @@ var (
 type CACertConfig struct {
 	CommonName   string
 	Organization string
+	// Duration is the validity period of the certificate. Defaults to Duration365d when zero.
+	Duration time.Duration
 }
 
 type ServerCertConfig struct {
 	CommonName  string
 	DNSNames    []string
 	IPAddresses []string
+	// Duration is the validity period of the certificate. Defaults to Duration90d when zero.
+	Duration time.Duration
 }
 
 type ClientCertConfig struct {
 	CommonName  string
 	DNSNames    []string
 	IPAddresses []string
+	// Duration is the validity period of the certificate. Defaults to Duration90d when zero.
+	Duration time.Duration
 }
 
 func NewSelfSignedCACertificate(cfg CACertConfig, key *rsa.PrivateKey) (*x509.Certificate, error) {
+	duration := cfg.Duration
+	if duration == 0 {
+		duration = Duration365d
+	}
+
 	now := time.Now()
 	tmpl := x509.Certificate{
 		SerialNumber: new(big.Int).SetInt64(0),
@@ -42,7 +56,7 @@ func NewSelfSignedCACertificate(cfg CACertConfig, key *rsa.PrivateKey) (*x509.Ce
 			Organization: []string{cfg.Organization},
 		},
 		NotBefore:             now.UTC(),
-		NotAfter:              now.Add(Duration365d).UTC(),
+		NotAfter:              now.Add(duration).UTC(),
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
 		BasicConstraintsValid: true,
 		IsCA: true,
@@ -66,6 +80,11 @@ func NewSignedServerCertificate(cfg ServerCertConfig, key *rsa.PrivateKey, caCer
 		return nil, err
 	}
 
+	duration := cfg.Duration
+	if duration == 0 {
+		duration = Duration90d
+	}
+
 	certTmpl := x509.Certificate{
 		Subject: pkix.Name{
 			CommonName:   cfg.CommonName,
@@ -75,7 +94,7 @@ func NewSignedServerCertificate(cfg ServerCertConfig, key *rsa.PrivateKey, caCer
 		IPAddresses:  ips,
 		SerialNumber: serial,
 		NotBefore:    caCert.NotBefore,
-		NotAfter:     time.Now().Add(Duration90d).UTC(),
+		NotAfter:     time.Now().Add(duration).UTC(),
 		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 	}
@@ -97,6 +116,11 @@ func NewSignedClientCertificate(cfg ClientCertConfig, key *rsa.PrivateKey, caCer
 		return nil, err
 	}
 
+	duration := cfg.Duration
+	if duration == 0 {
+		duration = Duration90d
+	}
+
 	certTmpl := x509.Certificate{
 		Subject: pkix.Name{
 			CommonName:   cfg.CommonName,
@@ -106,7 +130,7 @@ func NewSignedClientCertificate(cfg ClientCertConfig, key *rsa.PrivateKey, caCer
 		IPAddresses:  ips,
 		SerialNumber: serial,
 		NotBefore:    caCert.NotBefore,
-		NotAfter:     time.Now().Add(Duration90d).UTC(),
+		NotAfter:     time.Now().Add(duration).UTC(),
 		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 	}
@@ -116,3 +140,14 @@ func NewSignedClientCertificate(cfg ClientCertConfig, key *rsa.PrivateKey, caCer
 	}
 	return x509.ParseCertificate(certDERBytes)
 }
+
+// Fingerprint is the SHA-256 digest of the DER encoding of cert, in the colon
+// separated hex openssl x509 -noout -fingerprint -sha256 prints
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	pairs := make([]string, len(sum))
+	for i, b := range sum {
+		pairs[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(pairs, ":")
+}